@@ -0,0 +1,223 @@
+package ordmap
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// MarshalCanonicalJSON returns the canonical JSON representation of the map, as defined by the
+// JSON Canonicalization Scheme (JCS, RFC 8785).
+//
+// Unlike `MarshalJSON()`, the output does not depend on the insertion order: keys are sorted.
+func (m Map[K, V]) MarshalCanonicalJSON() ([]byte, error) {
+	return CanonicalJSON(m)
+}
+
+// MarshalCanonicalJSON returns the canonical JSON representation of the value, as defined by the
+// JSON Canonicalization Scheme (JCS, RFC 8785).
+//
+// Unlike `MarshalJSON()`, the output does not depend on the insertion order: keys are sorted.
+func (x Any) MarshalCanonicalJSON() ([]byte, error) {
+	return CanonicalJSON(x)
+}
+
+// CanonicalJSON returns the canonical JSON representation of 'v', as defined by the
+// JSON Canonicalization Scheme (JCS, RFC 8785):
+//   - object keys are sorted by their UTF-16 code units,
+//   - numbers are formatted as IEEE 754 doubles, using the ECMAScript rules,
+//   - strings use the minimal escaping,
+//   - no whitespace is emitted.
+//
+// 'v' is first marshaled using `encoding/json`, so any value accepted by `json.Marshal()` can be used.
+func CanonicalJSON(v any) ([]byte, error) {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(bs))
+	dec.UseNumber()
+	var generic any
+	err = dec.Decode(&generic)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	err = writeCanonicalJSON(&buf, generic)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// CanonicalSHA256 returns the SHA-256 digest of the canonical JSON representation of 'v'
+// (see `CanonicalJSON()`).
+func CanonicalSHA256(v any) ([sha256.Size]byte, error) {
+	bs, err := CanonicalJSON(v)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(bs), nil
+}
+
+func writeCanonicalJSON(buf *bytes.Buffer, v any) error {
+	switch x := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		if x {
+			buf.WriteString("true")
+		} else {
+			buf.WriteString("false")
+		}
+	case json.Number:
+		f, err := strconv.ParseFloat(string(x), 64)
+		if err != nil {
+			return fmt.Errorf("error when canonicalizing number %s: %w", x, err)
+		}
+		var tmp [32]byte
+		bs, err := appendCanonicalNumber(tmp[:0], f)
+		if err != nil {
+			return err
+		}
+		buf.Write(bs)
+	case string:
+		writeCanonicalString(buf, x)
+	case []any:
+		buf.WriteByte('[')
+		for i, elt := range x {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			err := writeCanonicalJSON(buf, elt)
+			if err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]any:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return lessUTF16(keys[i], keys[j])
+		})
+
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeCanonicalString(buf, k)
+			buf.WriteByte(':')
+			err := writeCanonicalJSON(buf, x[k])
+			if err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("error when canonicalizing: unexpected type %T", v)
+	}
+	return nil
+}
+
+// appendCanonicalNumber
+//
+// formats 'f' following the ECMAScript `Number.prototype.toString()` algorithm, as required by RFC 8785.
+func appendCanonicalNumber(b []byte, f float64) ([]byte, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, fmt.Errorf("error when canonicalizing number: %v is not a valid JSON number", f)
+	}
+	if f == 0 { // also covers -0
+		return append(b, '0'), nil
+	}
+
+	format := byte('f')
+	if abs := math.Abs(f); abs < 1e-6 || abs >= 1e21 {
+		format = 'e'
+	}
+	b = strconv.AppendFloat(b, f, format, -1, 64)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		n := len(b)
+		if n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b, nil
+}
+
+func writeCanonicalString(buf *bytes.Buffer, s string) {
+	const hex = "0123456789abcdef"
+
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hex[r>>4])
+				buf.WriteByte(hex[r&0xF])
+				continue
+			}
+			buf.WriteRune(r)
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// lessUTF16
+//
+// compares two strings by their UTF-16 code units, as required by RFC 8785 for sorting keys.
+func lessUTF16(a, b string) bool {
+	for a != "" && b != "" {
+		ra, na := utf8.DecodeRuneInString(a)
+		rb, nb := utf8.DecodeRuneInString(b)
+		a, b = a[na:], b[nb:]
+		if ra == rb {
+			continue
+		}
+
+		ua := utf16Units(ra)
+		ub := utf16Units(rb)
+		for i := 0; i < len(ua) && i < len(ub); i++ {
+			if ua[i] != ub[i] {
+				return ua[i] < ub[i]
+			}
+		}
+		return len(ua) < len(ub)
+	}
+	return a == "" && b != ""
+}
+
+func utf16Units(r rune) []uint16 {
+	if r1, r2 := utf16.EncodeRune(r); r1 != utf8.RuneError {
+		return []uint16{uint16(r1), uint16(r2)}
+	}
+	return []uint16{uint16(r)}
+}
@@ -0,0 +1,79 @@
+package ordmap
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalJSON(t *testing.T) {
+	type testCase struct{ input, expected string }
+	table := []testCase{
+		{`null`, `null`},
+		{`{ "b" : 1, "a" : [true, false, null] }`, `{"a":[true,false,null],"b":1}`},
+		{`{"z":{"y":1,"x":2},"a":"</script>"}`, `{"a":"</script>","z":{"x":2,"y":1}}`},
+		// example from RFC 8785, section 3.2.2:
+		{
+			`{"numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+			  "string": "€$\u000F\u000aA'B\u0022\u005c\\\u0022\/",
+			  "literals": [null, true, false]}`,
+			`{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`,
+		},
+		// sorting by UTF-16 code units, example from RFC 8785, section 3.2.3:
+		{
+			`{"€": "Euro Sign", "\r": "Carriage Return", "דּ": "Hebrew Letter Dalet With Dagesh",
+			  "1": "One", "😀": "Emoji: Grinning Face", "\u0080": "Control", "ö": "Latin Small Letter O With Diaeresis"}`,
+			`{"\r":"Carriage Return","1":"One","` + "\u0080" + `":"Control","ö":"Latin Small Letter O With Diaeresis","€":"Euro Sign","😀":"Emoji: Grinning Face","דּ":"Hebrew Letter Dalet With Dagesh"}`,
+		},
+		{`[-0, 1e21, 1e-7, 123e-9, 100, 9007199254740993]`, `[0,1e+21,1e-7,1.23e-7,100,9007199254740992]`},
+	}
+
+	for i, tc := range table {
+		x := mustAny(t, tc.input)
+
+		got, err := x.MarshalCanonicalJSON()
+		require.NoError(t, err, "test %d", i)
+		assert.Equal(t, tc.expected, string(got), "test %d", i)
+	}
+}
+
+func TestCanonicalJSON_IndependentOfOrder(t *testing.T) {
+	var m1, m2 Map[string, any]
+	m1.Set("a", 1)
+	m1.Set("b", []int{1, 2})
+	m2.Set("b", []int{1, 2})
+	m2.Set("a", 1.0)
+
+	bs1, err := m1.MarshalCanonicalJSON()
+	require.NoError(t, err)
+	bs2, err := m2.MarshalCanonicalJSON()
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":1,"b":[1,2]}`, string(bs1))
+	assert.Equal(t, string(bs1), string(bs2))
+
+	// the regular json encoding still follows the insertion order:
+	bs, err := json.Marshal(m2)
+	require.NoError(t, err)
+	assert.Equal(t, `{"b":[1,2],"a":1}`, string(bs))
+
+	d1, err := CanonicalSHA256(m1)
+	require.NoError(t, err)
+	d2, err := CanonicalSHA256(&m2)
+	require.NoError(t, err)
+	assert.Equal(t, d1, d2)
+	expected := sha256.Sum256([]byte(`{"a":1,"b":[1,2]}`))
+	assert.Equal(t, hex.EncodeToString(expected[:]), hex.EncodeToString(d1[:]))
+}
+
+func TestCanonicalJSON_Errors(t *testing.T) {
+	_, err := CanonicalJSON(math.Inf(1))
+	assert.Error(t, err)
+
+	_, err = CanonicalJSON(json.Number("1e400"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,125 @@
+package ordmap
+
+import (
+	"fmt"
+)
+
+// CBORMarshaler is implemented by types which can encode themselves as a single CBOR data item.
+type CBORMarshaler interface {
+	MarshalCBOR() ([]byte, error)
+}
+
+// CBORUnmarshaler is implemented by types which can decode themselves from a single CBOR data item.
+type CBORUnmarshaler interface {
+	UnmarshalCBOR(data []byte) error
+}
+
+// CBOROptions controls how `MarshalCBORWith()` encodes values.
+type CBOROptions struct {
+	// Deterministic writes the entries of maps sorted by the bytewise order of their encoded keys,
+	// as described by the "core deterministic encoding" of RFC 8949 (section 4.2.1), instead of
+	// the insertion order. Maps with two keys which have the same encoding are an error.
+	Deterministic bool
+}
+
+// CBORTag is a tagged data item which has no specific representation in go.
+type CBORTag struct {
+	Number  uint64
+	Content any
+}
+
+// CBORSimple is a simple value other than false, true, null and undefined.
+type CBORSimple uint8
+
+// CBORByteString is the type of byte string keys decoded from a CBOR map, since []byte values
+// can not be used as keys. It is encoded back as a byte string.
+type CBORByteString string
+
+// UnmarshalCBOR decodes the CBOR data item 'data' and stores the result in 'v'.
+//
+// Maps are decoded as `*Map[any, any]` values which keep the order of their entries, and whose
+// keys can be of any type but arrays and maps. Values are decoded as:
+//   - unsigned and negative integers: int64, uint64 above math.MaxInt64, and *big.Int below math.MinInt64,
+//   - floats: float64,
+//   - byte strings: []byte (`CBORByteString` for map keys), text strings: string,
+//   - arrays: []any,
+//   - null and undefined: nil,
+//   - tags 0 and 1 (date/time): time.Time, tags 2 and 3 (bignums): *big.Int,
+//     tag 55799 (self-described CBOR): the content of the tag, other tags: `CBORTag`,
+//   - other simple values: `CBORSimple`.
+//
+// If 'v' implements `CBORUnmarshaler`, its UnmarshalCBOR method is called with 'data',
+// otherwise the decoded value is stored in 'v' with `Any.DecodeInto()`.
+func UnmarshalCBOR(data []byte, v any) error {
+	if u, ok := v.(CBORUnmarshaler); ok {
+		return u.UnmarshalCBOR(data)
+	}
+	x, err := parseCBOR(data)
+	if err != nil {
+		return err
+	}
+	return Any{v: x}.DecodeInto(v)
+}
+
+// MarshalCBOR returns the CBOR encoding of 'v'.
+//
+// Maps are written in the order of their entries; structs are written as maps with their fields
+// in order of declaration (see `FromStruct()`). Integers and floats use the shortest encoding which
+// represents them exactly, time.Time values are written as an epoch-based date/time (tag 1) when
+// they have no fractional seconds and as a standard date/time string (tag 0) otherwise, and
+// *big.Int values which do not fit in 64 bits are written as bignums (tags 2 and 3).
+func MarshalCBOR(v any) ([]byte, error) {
+	return MarshalCBORWith(v, CBOROptions{})
+}
+
+// MarshalCBORWith is the same as `MarshalCBOR()`, with options.
+func MarshalCBORWith(v any, opts CBOROptions) ([]byte, error) {
+	e := &cborEncoder{opts: opts}
+	err := e.encode("", v)
+	if err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+func (x *Any) UnmarshalCBOR(data []byte) error {
+	v, err := parseCBOR(data)
+	if err != nil {
+		return err
+	}
+	x.v = v
+	return nil
+}
+
+func (x Any) MarshalCBOR() ([]byte, error) {
+	return MarshalCBOR(x.v)
+}
+
+// UnmarshalCBOR replaces the content of 'm' with the decoded map, or clears 'm' if the data item is null.
+// 'm' is left untouched if an error occurs. Use `UnmarshalMerge()` to merge the map into the existing content.
+func (m *Map[K, V]) UnmarshalCBOR(data []byte) error {
+	return m.unmarshalCBOR(data, false)
+}
+
+func (t *MergeTarget[K, V]) UnmarshalCBOR(data []byte) error {
+	return t.m.unmarshalCBOR(data, true)
+}
+
+func (m *Map[K, V]) unmarshalCBOR(data []byte, merge bool) error {
+	v, err := parseCBOR(data)
+	if err != nil {
+		return err
+	}
+	var res Map[K, V]
+	err = Any{v: v}.DecodeInto(&res)
+	if err != nil {
+		return fmt.Errorf("error when decoding map: %w", err)
+	}
+	m.applyDecoded(&res, merge)
+	return nil
+}
+
+// MarshalCBOR writes 'm' as a CBOR map, with its entries in insertion order.
+func (m Map[K, V]) MarshalCBOR() ([]byte, error) {
+	return MarshalCBOR(&m)
+}
@@ -0,0 +1,371 @@
+package ordmap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"time"
+	"unicode/utf8"
+)
+
+// maxCBORDepth is the maximum nesting depth of arrays, maps and tags accepted by the decoder.
+const maxCBORDepth = 1000
+
+// errCBORBreak is returned by 'decode' when it reads the "break" stop code of an indefinite length item.
+var errCBORBreak = errors.New("unexpected break stop code")
+
+type cborDecoder struct {
+	data  []byte
+	pos   int
+	depth int
+}
+
+// parseCBOR decodes 'data', which must hold exactly one data item.
+func parseCBOR(data []byte) (any, error) {
+	d := &cborDecoder{data: data}
+	v, err := d.decode()
+	if err == nil && d.pos < len(d.data) {
+		err = errors.New("extra trailing data")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error when decoding cbor at offset %d: %w", d.pos, err)
+	}
+	return v, nil
+}
+
+func (d *cborDecoder) readByte() (byte, error) {
+	if d.pos >= len(d.data) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	b := d.data[d.pos]
+	d.pos++
+	return b, nil
+}
+
+func (d *cborDecoder) readBytes(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	bs := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return bs, nil
+}
+
+// readHead
+//
+// reads the initial byte of a data item and its argument. 'indefinite' is true if the
+// additional information is 31 (indefinite length, or the break stop code for major type 7).
+func (d *cborDecoder) readHead() (major byte, info byte, arg uint64, indefinite bool, err error) {
+	b, err := d.readByte()
+	if err != nil {
+		return 0, 0, 0, false, err
+	}
+	major, info = b>>5, b&0x1f
+
+	switch {
+	case info < 24:
+		return major, info, uint64(info), false, nil
+	case info <= 27:
+		bs, err := d.readBytes(1 << (info - 24))
+		if err != nil {
+			return 0, 0, 0, false, err
+		}
+		for _, b := range bs {
+			arg = arg<<8 | uint64(b)
+		}
+		return major, info, arg, false, nil
+	case info == 31:
+		if major == 0 || major == 1 || major == 6 {
+			return 0, 0, 0, false, fmt.Errorf("invalid indefinite length for major type %d", major)
+		}
+		return major, info, 0, true, nil
+	}
+	return 0, 0, 0, false, fmt.Errorf("reserved additional information %d", info)
+}
+
+func (d *cborDecoder) decode() (any, error) {
+	major, info, arg, indefinite, err := d.readHead()
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return arg, nil
+		}
+		return int64(arg), nil
+
+	case 1:
+		if arg > math.MaxInt64 {
+			n := new(big.Int).SetUint64(arg)
+			return n.Neg(n.Add(n, big.NewInt(1))), nil
+		}
+		return -1 - int64(arg), nil
+
+	case 2, 3:
+		bs, err := d.decodeString(major, arg, indefinite)
+		if err != nil {
+			return nil, err
+		}
+		if major == 2 {
+			return bs, nil
+		}
+		if !utf8.Valid(bs) {
+			return nil, errors.New("text string is not valid UTF-8")
+		}
+		return string(bs), nil
+
+	case 4:
+		return d.nested(func() (any, error) { return d.decodeArray(arg, indefinite) })
+	case 5:
+		return d.nested(func() (any, error) { return d.decodeMap(arg, indefinite) })
+	case 6:
+		return d.nested(func() (any, error) { return d.decodeTag(arg) })
+	}
+
+	// major type 7: simple values and floats
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil
+	case 24:
+		if arg < 32 {
+			return nil, fmt.Errorf("invalid simple value %d", arg)
+		}
+		return CBORSimple(arg), nil
+	case 25:
+		return float16ToFloat64(uint16(arg)), nil
+	case 26:
+		return float64(math.Float32frombits(uint32(arg))), nil
+	case 27:
+		return math.Float64frombits(arg), nil
+	case 31:
+		return nil, errCBORBreak
+	}
+	return CBORSimple(arg), nil
+}
+
+func (d *cborDecoder) nested(fn func() (any, error)) (any, error) {
+	d.depth++
+	if d.depth > maxCBORDepth {
+		return nil, fmt.Errorf("maximum nesting depth of %d exceeded", maxCBORDepth)
+	}
+	v, err := fn()
+	d.depth--
+	return v, err
+}
+
+// decodeString
+//
+// reads the content of a byte string or text string, concatenating the chunks of indefinite length strings.
+func (d *cborDecoder) decodeString(major byte, length uint64, indefinite bool) ([]byte, error) {
+	if !indefinite {
+		bs, err := d.readBytes(length)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{}, bs...), nil
+	}
+
+	res := []byte{}
+	for {
+		chunkMajor, info, arg, chunkIndefinite, err := d.readHead()
+		if err != nil {
+			return nil, err
+		}
+		if chunkMajor == 7 && info == 31 {
+			return res, nil
+		}
+		if chunkMajor != major || chunkIndefinite {
+			return nil, fmt.Errorf("invalid chunk of major type %d in an indefinite length string", chunkMajor)
+		}
+		bs, err := d.readBytes(arg)
+		if err != nil {
+			return nil, err
+		}
+		if major == 3 && !utf8.Valid(bs) {
+			return nil, errors.New("text string is not valid UTF-8")
+		}
+		res = append(res, bs...)
+	}
+}
+
+func (d *cborDecoder) decodeArray(length uint64, indefinite bool) (any, error) {
+	if indefinite {
+		res := []any{}
+		for {
+			v, err := d.decode()
+			if err == errCBORBreak {
+				return res, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, v)
+		}
+	}
+
+	// each item takes at least one byte:
+	if length > uint64(len(d.data)-d.pos) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	res := make([]any, length)
+	for i := range res {
+		v, err := d.decode()
+		if err != nil {
+			return nil, unexpectedBreak(err)
+		}
+		res[i] = v
+	}
+	return res, nil
+}
+
+func (d *cborDecoder) decodeMap(length uint64, indefinite bool) (any, error) {
+	if !indefinite && length > uint64(len(d.data)-d.pos)/2 {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	res := &Map[any, any]{}
+	for i := uint64(0); indefinite || i < length; i++ {
+		keyPos := d.pos
+		k, err := d.decode()
+		if indefinite && err == errCBORBreak {
+			break
+		}
+		if err != nil {
+			return nil, unexpectedBreak(err)
+		}
+		k, err = cborMapKey(k)
+		if err != nil {
+			d.pos = keyPos
+			return nil, err
+		}
+		if _, ok := res.Get2(k); ok {
+			d.pos = keyPos
+			return nil, fmt.Errorf("duplicate map key %v", k)
+		}
+
+		v, err := d.decode()
+		if err != nil {
+			return nil, unexpectedBreak(err)
+		}
+		res.Set(k, v)
+	}
+	return res, nil
+}
+
+// cborMapKey
+//
+// converts a decoded value into a value which can be used as a key of a go map.
+func cborMapKey(k any) (any, error) {
+	switch x := k.(type) {
+	case []byte:
+		return CBORByteString(x), nil
+	case *big.Int:
+		// *big.Int keys would be compared by pointer, not by value:
+		return nil, errors.New("unsupported map key: bignum")
+	case float64:
+		if math.IsNaN(x) {
+			return nil, errors.New("unsupported map key: NaN")
+		}
+	case []any, *Map[any, any]:
+		return nil, fmt.Errorf("unsupported map key of type %T", k)
+	case CBORTag:
+		content, err := cborMapKey(x.Content)
+		if err != nil {
+			return nil, err
+		}
+		x.Content = content
+		return x, nil
+	}
+	return k, nil
+}
+
+func unexpectedBreak(err error) error {
+	if err == errCBORBreak {
+		return errors.New("unexpected break stop code in a definite length item")
+	}
+	return err
+}
+
+func (d *cborDecoder) decodeTag(number uint64) (any, error) {
+	contentPos := d.pos
+	content, err := d.decode()
+	if err != nil {
+		return nil, unexpectedBreak(err)
+	}
+
+	switch number {
+	case 0:
+		s, ok := content.(string)
+		if !ok {
+			d.pos = contentPos
+			return nil, fmt.Errorf("invalid content of type %T for tag 0: expected a text string", content)
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			d.pos = contentPos
+			return nil, fmt.Errorf("invalid content for tag 0: %w", err)
+		}
+		return t, nil
+
+	case 1:
+		switch x := content.(type) {
+		case int64:
+			return time.Unix(x, 0).UTC(), nil
+		case float64:
+			if math.IsNaN(x) || math.IsInf(x, 0) {
+				break
+			}
+			sec, frac := math.Modf(x)
+			return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
+		}
+		d.pos = contentPos
+		return nil, fmt.Errorf("invalid content of type %T for tag 1: expected a number", content)
+
+	case 2, 3:
+		bs, ok := content.([]byte)
+		if !ok {
+			d.pos = contentPos
+			return nil, fmt.Errorf("invalid content of type %T for tag %d: expected a byte string", content, number)
+		}
+		n := new(big.Int).SetBytes(bs)
+		if number == 3 {
+			n.Neg(n.Add(n, big.NewInt(1)))
+		}
+		return n, nil
+
+	case 55799:
+		return content, nil
+	}
+
+	return CBORTag{Number: number, Content: content}, nil
+}
+
+func float16ToFloat64(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant != 0 {
+			return math.NaN()
+		}
+		f = math.Inf(1)
+	default:
+		f = math.Ldexp(mant+0x400, exp-25)
+	}
+	if h&0x8000 != 0 {
+		return -f
+	}
+	return f
+}
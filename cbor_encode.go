@@ -0,0 +1,460 @@
+package ordmap
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"time"
+	"unicode/utf8"
+)
+
+type cborEncoder struct {
+	buf  []byte
+	opts CBOROptions
+}
+
+// writeHead writes the initial byte of a data item of type 'major', with the shortest encoding of 'arg'.
+func (e *cborEncoder) writeHead(major byte, arg uint64) {
+	major <<= 5
+	switch {
+	case arg < 24:
+		e.buf = append(e.buf, major|byte(arg))
+	case arg <= math.MaxUint8:
+		e.buf = append(e.buf, major|24, byte(arg))
+	case arg <= math.MaxUint16:
+		e.buf = append(e.buf, major|25, byte(arg>>8), byte(arg))
+	case arg <= math.MaxUint32:
+		e.buf = append(e.buf, major|26, byte(arg>>24), byte(arg>>16), byte(arg>>8), byte(arg))
+	default:
+		e.buf = append(e.buf, major|27, byte(arg>>56), byte(arg>>48), byte(arg>>40), byte(arg>>32),
+			byte(arg>>24), byte(arg>>16), byte(arg>>8), byte(arg))
+	}
+}
+
+func (e *cborEncoder) writeInt(n int64) {
+	if n < 0 {
+		e.writeHead(1, uint64(-1-n))
+		return
+	}
+	e.writeHead(0, uint64(n))
+}
+
+func (e *cborEncoder) writeBigInt(n *big.Int) {
+	if n.IsInt64() {
+		e.writeInt(n.Int64())
+		return
+	}
+	if n.IsUint64() {
+		e.writeHead(0, n.Uint64())
+		return
+	}
+	if n.Sign() > 0 {
+		e.writeHead(6, 2)
+		bs := n.Bytes()
+		e.writeHead(2, uint64(len(bs)))
+		e.buf = append(e.buf, bs...)
+		return
+	}
+	// negative bignums hold -1 - n:
+	m := new(big.Int).Neg(n)
+	m.Sub(m, big.NewInt(1))
+	if m.IsUint64() {
+		e.writeHead(1, m.Uint64())
+		return
+	}
+	e.writeHead(6, 3)
+	bs := m.Bytes()
+	e.writeHead(2, uint64(len(bs)))
+	e.buf = append(e.buf, bs...)
+}
+
+// writeFloat writes 'f' with the shortest of the half, single and double precision encodings
+// which represents it exactly.
+func (e *cborEncoder) writeFloat(f float64) {
+	if math.IsNaN(f) {
+		e.buf = append(e.buf, 0xf9, 0x7e, 0x00)
+		return
+	}
+	f32 := float32(f)
+	if float64(f32) != f {
+		bits := math.Float64bits(f)
+		e.buf = append(e.buf, 0xfb)
+		e.buf = append(e.buf, byte(bits>>56), byte(bits>>48), byte(bits>>40), byte(bits>>32),
+			byte(bits>>24), byte(bits>>16), byte(bits>>8), byte(bits))
+		return
+	}
+	if h, ok := float32ToFloat16(f32); ok {
+		e.buf = append(e.buf, 0xf9, byte(h>>8), byte(h))
+		return
+	}
+	bits := math.Float32bits(f32)
+	e.buf = append(e.buf, 0xfa, byte(bits>>24), byte(bits>>16), byte(bits>>8), byte(bits))
+}
+
+// float32ToFloat16
+//
+// returns the half precision representation of 'f', or false if 'f' can not be represented exactly.
+func float32ToFloat16(f float32) (uint16, bool) {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int(bits>>23) & 0xff
+	mant := bits & 0x7fffff
+
+	switch {
+	case exp == 0xff:
+		// infinities (NaN values are handled by the caller)
+		return sign | 0x7c00, mant == 0
+	case exp == 0 && mant == 0:
+		return sign, true
+	case exp == 0:
+		// float32 subnormals are too small for a float16
+		return 0, false
+	}
+
+	e := exp - 127
+	switch {
+	case e >= -14 && e <= 15:
+		if mant&0x1fff != 0 {
+			return 0, false
+		}
+		return sign | uint16(e+15)<<10 | uint16(mant>>13), true
+	case e >= -24 && e < -14:
+		// float16 subnormals: the value is m * 2^-24
+		full := mant | 0x800000
+		shift := uint(-e - 1)
+		if full&(1<<shift-1) != 0 {
+			return 0, false
+		}
+		return sign | uint16(full>>shift), true
+	}
+	return 0, false
+}
+
+func (e *cborEncoder) encode(where string, v any) error {
+	for {
+		switch x := v.(type) {
+		case nil:
+			e.buf = append(e.buf, 0xf6)
+			return nil
+
+		case Any:
+			v = x.v
+			continue
+		case *Any:
+			if x == nil {
+				v = nil
+				continue
+			}
+			v = x.v
+			continue
+		case *YAMLAnchor:
+			v = x.Value
+			continue
+		case *YAMLScalar:
+			v = x.Value
+			continue
+
+		case bool:
+			if x {
+				e.buf = append(e.buf, 0xf5)
+			} else {
+				e.buf = append(e.buf, 0xf4)
+			}
+			return nil
+		case int:
+			e.writeInt(int64(x))
+			return nil
+		case int8:
+			e.writeInt(int64(x))
+			return nil
+		case int16:
+			e.writeInt(int64(x))
+			return nil
+		case int32:
+			e.writeInt(int64(x))
+			return nil
+		case int64:
+			e.writeInt(x)
+			return nil
+		case uint:
+			e.writeHead(0, uint64(x))
+			return nil
+		case uint8:
+			e.writeHead(0, uint64(x))
+			return nil
+		case uint16:
+			e.writeHead(0, uint64(x))
+			return nil
+		case uint32:
+			e.writeHead(0, uint64(x))
+			return nil
+		case uint64:
+			e.writeHead(0, x)
+			return nil
+		case float32:
+			e.writeFloat(float64(x))
+			return nil
+		case float64:
+			e.writeFloat(x)
+			return nil
+		case json.Number:
+			if i, err := x.Int64(); err == nil {
+				e.writeInt(i)
+				return nil
+			}
+			f, err := x.Float64()
+			if err != nil {
+				return fmt.Errorf("error when encoding cbor %s: %w", where, err)
+			}
+			e.writeFloat(f)
+			return nil
+		case *big.Int:
+			if x == nil {
+				v = nil
+				continue
+			}
+			e.writeBigInt(x)
+			return nil
+		case big.Int:
+			e.writeBigInt(&x)
+			return nil
+
+		case string:
+			if !utf8.ValidString(x) {
+				return fmt.Errorf("error when encoding cbor %s: string is not valid UTF-8", where)
+			}
+			e.writeHead(3, uint64(len(x)))
+			e.buf = append(e.buf, x...)
+			return nil
+		case []byte:
+			e.writeHead(2, uint64(len(x)))
+			e.buf = append(e.buf, x...)
+			return nil
+		case CBORByteString:
+			e.writeHead(2, uint64(len(x)))
+			e.buf = append(e.buf, x...)
+			return nil
+
+		case time.Time:
+			if x.Nanosecond() == 0 {
+				e.writeHead(6, 1)
+				e.writeInt(x.Unix())
+				return nil
+			}
+			e.writeHead(6, 0)
+			v = x.Format(time.RFC3339Nano)
+			continue
+
+		case CBORTag:
+			e.writeHead(6, x.Number)
+			v = x.Content
+			where = fmt.Sprintf("%s(tag %d)", where, x.Number)
+			continue
+		case CBORSimple:
+			if x >= 24 && x < 32 {
+				return fmt.Errorf("error when encoding cbor %s: invalid simple value %d", where, x)
+			}
+			e.writeHead(7, uint64(x))
+			return nil
+
+		case []any:
+			e.writeHead(4, uint64(len(x)))
+			for i, elt := range x {
+				err := e.encode(fmt.Sprintf("%s[%d]", where, i), elt)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		case anyMap:
+			if reflect.ValueOf(x).IsNil() {
+				v = nil
+				continue
+			}
+			return e.encodeMap(where, x, e.opts.Deterministic)
+		}
+
+		rv := reflect.ValueOf(v)
+		// Map values are encoded as maps, so that options apply to them:
+		if rv.Kind() != reflect.Pointer && reflect.PointerTo(rv.Type()).Implements(anyMapType) {
+			p := reflect.New(rv.Type())
+			p.Elem().Set(rv)
+			v = p.Interface()
+			continue
+		}
+
+		switch x := v.(type) {
+		case CBORMarshaler:
+			if rv.Kind() == reflect.Pointer && rv.IsNil() {
+				v = nil
+				continue
+			}
+			bs, err := x.MarshalCBOR()
+			if err != nil {
+				return fmt.Errorf("error when encoding cbor %s: %w", where, err)
+			}
+			if _, err := parseCBOR(bs); err != nil {
+				return fmt.Errorf("error when encoding cbor %s: invalid output of MarshalCBOR: %w", where, err)
+			}
+			e.buf = append(e.buf, bs...)
+			return nil
+		case json.Marshaler:
+			if rv.Kind() == reflect.Pointer && rv.IsNil() {
+				v = nil
+				continue
+			}
+			bs, err := x.MarshalJSON()
+			if err != nil {
+				return fmt.Errorf("error when encoding cbor %s: %w", where, err)
+			}
+			var res Any
+			err = json.Unmarshal(bs, &res)
+			if err != nil {
+				return fmt.Errorf("error when encoding cbor %s: %w", where, err)
+			}
+			v = res.v
+			continue
+		case encoding.TextMarshaler:
+			if rv.Kind() == reflect.Pointer && rv.IsNil() {
+				v = nil
+				continue
+			}
+			bs, err := x.MarshalText()
+			if err != nil {
+				return fmt.Errorf("error when encoding cbor %s: %w", where, err)
+			}
+			v = string(bs)
+			continue
+		}
+
+		// other go values: named scalar types, structs, go maps and slices
+		switch rv.Kind() {
+		case reflect.String:
+			v = rv.String()
+			continue
+		case reflect.Bool:
+			v = rv.Bool()
+			continue
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			e.writeInt(rv.Int())
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			e.writeHead(0, rv.Uint())
+			return nil
+		case reflect.Float32, reflect.Float64:
+			e.writeFloat(rv.Float())
+			return nil
+		}
+
+		switch rv.Kind() {
+		case reflect.Pointer, reflect.Interface:
+			if rv.IsNil() {
+				v = nil
+				continue
+			}
+			v = rv.Elem().Interface()
+			continue
+
+		case reflect.Struct:
+			res := &Map[string, any]{}
+			for _, f := range cachedStructFields(rv.Type()).list {
+				fv, ok := fieldByIndex(rv, f.index)
+				if !ok || (f.omitEmpty && isEmptyValue(fv)) {
+					continue
+				}
+				res.Set(f.name, fv.Interface())
+			}
+			return e.encodeMap(where, res, e.opts.Deterministic)
+
+		case reflect.Map:
+			if rv.IsNil() {
+				v = nil
+				continue
+			}
+			// go maps have no order, their entries are always sorted:
+			res := &Map[any, any]{}
+			iter := rv.MapRange()
+			for iter.Next() {
+				res.Set(iter.Key().Interface(), iter.Value().Interface())
+			}
+			return e.encodeMap(where, res, true)
+
+		case reflect.Slice, reflect.Array:
+			if rv.Kind() == reflect.Slice && rv.IsNil() {
+				v = nil
+				continue
+			}
+			if rv.Type().Elem().Kind() == reflect.Uint8 {
+				bs := make([]byte, rv.Len())
+				reflect.Copy(reflect.ValueOf(bs), rv)
+				v = bs
+				continue
+			}
+			e.writeHead(4, uint64(rv.Len()))
+			for i := 0; i < rv.Len(); i++ {
+				err := e.encode(fmt.Sprintf("%s[%d]", where, i), rv.Index(i).Interface())
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		return fmt.Errorf("error when encoding cbor %s: unsupported type %T", where, v)
+	}
+}
+
+// encodeMap
+//
+// writes the entries of 'm' in insertion order, or sorted by their encoded keys if 'sorted' is true.
+func (e *cborEncoder) encodeMap(where string, m anyMap, sorted bool) error {
+	e.writeHead(5, uint64(m.Len()))
+	if !sorted {
+		return m.eachAny(func(k, v any) error {
+			err := e.encode(fmt.Sprintf("%s (key %v)", where, k), k)
+			if err != nil {
+				return err
+			}
+			return e.encode(fmt.Sprintf("%s.%v", where, k), v)
+		})
+	}
+
+	type entry struct {
+		key, value []byte
+	}
+	entries := make([]entry, 0, m.Len())
+	err := m.eachAny(func(k, v any) error {
+		sub := &cborEncoder{opts: e.opts}
+		err := sub.encode(fmt.Sprintf("%s (key %v)", where, k), k)
+		if err != nil {
+			return err
+		}
+		keyLen := len(sub.buf)
+		err = sub.encode(fmt.Sprintf("%s.%v", where, k), v)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry{key: sub.buf[:keyLen], value: sub.buf[keyLen:]})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].key, entries[j].key) < 0
+	})
+	for i, entry := range entries {
+		if i > 0 && bytes.Equal(entries[i-1].key, entry.key) {
+			return fmt.Errorf("error when encoding cbor %s: duplicate encoded key %x", where, entry.key)
+		}
+		e.buf = append(e.buf, entry.key...)
+		e.buf = append(e.buf, entry.value...)
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package ordmap
+
+import (
+	"encoding/hex"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	bs, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return bs
+}
+
+func bigInt(s string) *big.Int {
+	n, _ := new(big.Int).SetString(s, 10)
+	return n
+}
+
+// examples from RFC 8949, appendix A
+func TestCBOR_RFCExamples(t *testing.T) {
+	table := []struct {
+		hex   string
+		value any
+	}{
+		{"00", int64(0)},
+		{"17", int64(23)},
+		{"1818", int64(24)},
+		{"1903e8", int64(1000)},
+		{"1a000f4240", int64(1000000)},
+		{"1b000000e8d4a51000", int64(1000000000000)},
+		{"1bffffffffffffffff", uint64(math.MaxUint64)},
+		{"c249010000000000000000", bigInt("18446744073709551616")},
+		{"3bffffffffffffffff", bigInt("-18446744073709551616")},
+		{"c349010000000000000000", bigInt("-18446744073709551617")},
+		{"20", int64(-1)},
+		{"3903e7", int64(-1000)},
+		{"f90000", 0.0},
+		{"f93c00", 1.0},
+		{"fb3ff199999999999a", 1.1},
+		{"f93e00", 1.5},
+		{"f97bff", 65504.0},
+		{"fa47c35000", 100000.0},
+		{"fa7f7fffff", 3.4028234663852886e+38},
+		{"fb7e37e43c8800759c", 1.0e+300},
+		{"f90001", 5.960464477539063e-8},
+		{"f90400", 0.00006103515625},
+		{"f9c400", -4.0},
+		{"fbc010666666666666", -4.1},
+		{"f97c00", math.Inf(1)},
+		{"f9fc00", math.Inf(-1)},
+		{"f4", false},
+		{"f5", true},
+		{"f6", nil},
+		{"f0", CBORSimple(16)},
+		{"f8ff", CBORSimple(255)},
+		{"c11a514b67b0", time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC)},
+		{"d74401020304", CBORTag{Number: 23, Content: []byte{1, 2, 3, 4}}},
+		{"40", []byte{}},
+		{"4401020304", []byte{1, 2, 3, 4}},
+		{"60", ""},
+		{"6449455446", "IETF"},
+		{"62225c", "\"\\"},
+		{"63e6b0b4", "水"},
+		{"80", []any{}},
+		{"83010203", []any{int64(1), int64(2), int64(3)}},
+		{"8301820203820405", []any{int64(1), []any{int64(2), int64(3)}, []any{int64(4), int64(5)}}},
+	}
+
+	for _, tc := range table {
+		var x Any
+		err := UnmarshalCBOR(mustHex(t, tc.hex), &x)
+		require.NoError(t, err, tc.hex)
+		assert.Equal(t, tc.value, x.V(), tc.hex)
+
+		out, err := MarshalCBOR(tc.value)
+		require.NoError(t, err, tc.hex)
+		assert.Equal(t, tc.hex, hex.EncodeToString(out))
+	}
+
+	// values which are decoded but not written back the same way:
+	decodeOnly := []struct {
+		hex   string
+		value any
+	}{
+		{"f7", nil},
+		{"c074323031332d30332d32315432303a30343a30305a", time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC)},
+		{"c1fb41d452d9ec200000", time.Date(2013, 3, 21, 20, 4, 0, 500000000, time.UTC)},
+		{"5f42010243030405ff", []byte{1, 2, 3, 4, 5}},
+		{"7f657374726561646d696e67ff", "streaming"},
+		{"9fff", []any{}},
+		{"9f018202039f0405ffff", []any{int64(1), []any{int64(2), int64(3)}, []any{int64(4), int64(5)}}},
+		{"d9d9f76449455446", "IETF"},
+	}
+	for _, tc := range decodeOnly {
+		var x Any
+		err := UnmarshalCBOR(mustHex(t, tc.hex), &x)
+		require.NoError(t, err, tc.hex)
+		assert.Equal(t, tc.value, x.V(), tc.hex)
+	}
+
+	var nan Any
+	require.NoError(t, UnmarshalCBOR(mustHex(t, "f97e00"), &nan))
+	assert.True(t, math.IsNaN(nan.V().(float64)))
+	out, err := MarshalCBOR(math.NaN())
+	require.NoError(t, err)
+	assert.Equal(t, "f97e00", hex.EncodeToString(out))
+}
+
+func TestCBOR_Maps(t *testing.T) {
+	// {1: 2, 3: 4}
+	var x Any
+	err := UnmarshalCBOR(mustHex(t, "a201020304"), &x)
+	require.NoError(t, err)
+	m := x.V().(*Map[any, any])
+	assert.Equal(t, []any{int64(1), int64(3)}, m.Keys())
+
+	// {"a": 1, "b": [2, 3]}, written with an indefinite length
+	err = UnmarshalCBOR(mustHex(t, "bf61610161629f0203ffff"), &x)
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":1,"b":[2,3]}`, jsonMarshalString(t, x))
+
+	// keys keep their order and their type:
+	src := &Map[any, any]{}
+	src.Set("z", 1)
+	src.Set(int64(10), "ten")
+	src.Set(CBORByteString("\x01"), true)
+	src.Set(false, nil)
+	src.Set("a", &Map[string, any]{})
+
+	out, err := MarshalCBOR(src)
+	require.NoError(t, err)
+	assert.Equal(t, "a561"+"7a01"+"0a6374656e"+"4101f5"+"f4f6"+"6161a0", hex.EncodeToString(out))
+
+	err = UnmarshalCBOR(out, &x)
+	require.NoError(t, err)
+	assert.Equal(t, []any{"z", int64(10), CBORByteString("\x01"), false, "a"}, x.V().(*Map[any, any]).Keys())
+
+	// deterministic encoding sorts entries by their encoded keys:
+	out, err = MarshalCBORWith(src, CBOROptions{Deterministic: true})
+	require.NoError(t, err)
+	assert.Equal(t, "a5"+"0a6374656e"+"4101f5"+"6161a0"+"617a01"+"f4f6", hex.EncodeToString(out))
+
+	dup := &Map[any, any]{}
+	dup.Set(1, "int")
+	dup.Set(int64(1), "int64")
+	_, err = MarshalCBORWith(dup, CBOROptions{Deterministic: true})
+	assert.ErrorContains(t, err, "duplicate")
+
+	// go maps are always sorted:
+	out, err = MarshalCBOR(map[int]string{3: "c", 1: "a", -1: "z"})
+	require.NoError(t, err)
+	assert.Equal(t, "a3"+"016161"+"036163"+"20617a", hex.EncodeToString(out))
+}
+
+func TestCBOR_MapAndStruct(t *testing.T) {
+	type Device struct {
+		Name     string            `json:"name"`
+		Serial   []byte            `json:"serial"`
+		Limits   Map[int, float64] `json:"limits"`
+		Seen     time.Time         `json:"seen"`
+		Comment  string            `json:"comment,omitempty"`
+		Internal bool              `json:"-"`
+	}
+
+	limits := Map[int, float64]{}
+	limits.Set(20, 1.5)
+	limits.Set(-3, 0.25)
+	dev := Device{
+		Name:     "d1",
+		Serial:   []byte{0xca, 0xfe},
+		Limits:   limits,
+		Seen:     time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Internal: true,
+	}
+
+	out, err := MarshalCBOR(dev)
+	require.NoError(t, err)
+
+	var x Any
+	require.NoError(t, UnmarshalCBOR(out, &x))
+	m := x.V().(*Map[any, any])
+	assert.Equal(t, []any{"name", "serial", "limits", "seen"}, m.Keys())
+	assert.Equal(t, []any{int64(20), int64(-3)}, m.Get("limits").(*Map[any, any]).Keys())
+
+	var back Device
+	require.NoError(t, UnmarshalCBOR(out, &back))
+	dev.Internal = false
+	assert.Equal(t, dev, back)
+
+	// Map implements the CBOR hooks:
+	var lm Map[int, float64]
+	lm.Set(1, 1)
+	require.NoError(t, lm.UnmarshalCBOR(mustHex(t, "a214f93e0022f93400")))
+	assert.Equal(t, []int{20, -3}, lm.Keys())
+	assert.Equal(t, 1.5, lm.Get(20))
+
+	out, err = lm.MarshalCBOR()
+	require.NoError(t, err)
+	assert.Equal(t, "a214f93e0022f93400", hex.EncodeToString(out))
+
+	err = UnmarshalCBOR(mustHex(t, "a1"+"0a"+"f93c00"), UnmarshalMerge(&lm))
+	require.NoError(t, err)
+	assert.Equal(t, []int{20, -3, 10}, lm.Keys())
+
+	err = lm.UnmarshalCBOR(mustHex(t, "a16178f5"))
+	assert.Error(t, err)
+	assert.Equal(t, 3, lm.Len(), "map is left untouched on error")
+
+	require.NoError(t, lm.UnmarshalCBOR(mustHex(t, "f6")))
+	assert.Equal(t, 0, lm.Len())
+}
+
+func TestCBOR_Errors(t *testing.T) {
+	table := []string{
+		"",
+		"18",
+		"1c",
+		"1f",
+		"3f",
+		"62616263",
+		"5f4101610200ff",
+		"62c328",
+		"830102",
+		"8301ff03",
+		"a10102a1",
+		"a201020103",
+		"a1810102",
+		"c06161",
+		"c1f97e00",
+		"c26161",
+		"f818",
+		"ff",
+		"0000",
+		"9b7fffffffffffffff",
+	}
+	for _, input := range table {
+		var x Any
+		err := UnmarshalCBOR(mustHex(t, input), &x)
+		assert.Error(t, err, "input: %s", input)
+	}
+
+	deep := make([]byte, maxCBORDepth+1)
+	for i := range deep {
+		deep[i] = 0x81
+	}
+	var x Any
+	err := UnmarshalCBOR(append(deep, 0x00), &x)
+	assert.ErrorContains(t, err, "nesting depth")
+
+	_, err = MarshalCBOR("\xff")
+	assert.Error(t, err)
+	_, err = MarshalCBOR(func() {})
+	assert.Error(t, err)
+}
@@ -0,0 +1,310 @@
+package ordmap
+
+import (
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// CSVOptions controls how `WriteCSV()` and `ReadCSVWith()` write and read rows.
+type CSVOptions struct {
+	// Comma is the field delimiter, ',' if zero. Use '\t' for TSV.
+	Comma rune
+
+	// Columns, if set, is the list of columns to write, in order. Otherwise the columns are the
+	// keys of the first row, or the keys of all rows if HeaderUnion is set.
+	Columns []string
+
+	// HeaderUnion makes the columns the union of the keys of all rows, in first-seen order.
+	// Without it, a row which has a key which is not a column is an error.
+	HeaderUnion bool
+
+	// NoHeader skips the header line when writing, and tells that there is no header line
+	// when reading: columns are then named by Columns, or "1", "2", ... by default.
+	NoHeader bool
+
+	// Missing is the text of cells for keys which are missing from a row when writing, and
+	// the value given to missing trailing cells of short rows when reading.
+	Missing string
+
+	// Format, if set, converts the value of the column 'column' to the text of a cell, in place
+	// of the default formatting (see `WriteCSV()`). It is not called for missing keys.
+	Format func(column string, v any) (string, error)
+
+	// UseCRLF ends lines with \r\n instead of \n when writing.
+	UseCRLF bool
+}
+
+// WriteCSV writes 'rows' to 'w' as CSV, with a header line and one line per row.
+//
+// Columns follow the order of the keys of the first row, or the union of the keys of all rows
+// in first-seen order with `CSVOptions.HeaderUnion` (see `CSVOptions` for the other options).
+//
+// Values are formatted as: strings as is, nil as an empty cell, numbers without exponent,
+// booleans as "true" and "false", timestamps in RFC 3339 format, `encoding.TextMarshaler`
+// values with their MarshalText method, and other values (objects and arrays) as JSON.
+// A nil row is written as an empty record (all its cells are missing), and is skipped when
+// looking for the first row.
+func WriteCSV[V any](w io.Writer, rows []*Map[string, V], opts CSVOptions) error {
+	columns := opts.Columns
+	if columns == nil {
+		seen := map[string]bool{}
+		first := true
+		for _, row := range rows {
+			if row == nil {
+				continue
+			}
+			if !first && !opts.HeaderUnion {
+				break
+			}
+			first = false
+			for _, k := range row.Keys() {
+				if !seen[k] {
+					seen[k] = true
+					columns = append(columns, k)
+				}
+			}
+		}
+	}
+	known := make(map[string]bool, len(columns))
+	for _, c := range columns {
+		if known[c] {
+			return fmt.Errorf("error when writing csv: duplicate column %q", c)
+		}
+		known[c] = true
+	}
+
+	cw := csv.NewWriter(w)
+	if opts.Comma != 0 {
+		cw.Comma = opts.Comma
+	}
+	cw.UseCRLF = opts.UseCRLF
+
+	if !opts.NoHeader {
+		err := cw.Write(columns)
+		if err != nil {
+			return fmt.Errorf("error when writing csv header: %w", err)
+		}
+	}
+
+	record := make([]string, len(columns))
+	for i, row := range rows {
+		if row == nil {
+			row = &Map[string, V]{}
+		}
+		for _, k := range row.Keys() {
+			if !known[k] {
+				return fmt.Errorf("error when writing csv row %d: key %q is not a column", i, k)
+			}
+		}
+		for j, c := range columns {
+			v, ok := row.Get2(c)
+			if !ok {
+				record[j] = opts.Missing
+				continue
+			}
+			var s string
+			var err error
+			if opts.Format != nil {
+				s, err = opts.Format(c, v)
+			} else {
+				s, err = csvCell(v)
+			}
+			if err != nil {
+				return fmt.Errorf("error when writing csv row %d, column %q: %w", i, c, err)
+			}
+			record[j] = s
+		}
+		err := cw.Write(record)
+		if err != nil {
+			return fmt.Errorf("error when writing csv row %d: %w", i, err)
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvCell formats 'v' as the text of a cell.
+func csvCell(v any) (string, error) {
+	switch x := v.(type) {
+	case nil:
+		return "", nil
+	case Any:
+		return csvCell(x.v)
+	case *Any:
+		if x == nil {
+			return "", nil
+		}
+		return csvCell(x.v)
+	case *YAMLAnchor:
+		return csvCell(x.Value)
+	case *YAMLScalar:
+		return csvCell(x.Value)
+	case string:
+		return x, nil
+	case []byte:
+		return string(x), nil
+	case bool:
+		return strconv.FormatBool(x), nil
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64), nil
+	case float32:
+		return strconv.FormatFloat(float64(x), 'f', -1, 32), nil
+	case json.Number:
+		return string(x), nil
+	case time.Time:
+		return x.Format(time.RFC3339Nano), nil
+	case encoding.TextMarshaler:
+		bs, err := x.MarshalText()
+		return string(bs), err
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 64), nil
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return "", nil
+		}
+	}
+
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(bs), nil
+}
+
+// ReadCSV reads CSV data from 'r', where the first line is the header, and returns one Map
+// per line with the columns as keys, in the order of the header.
+func ReadCSV(r io.Reader) ([]*Map[string, string], error) {
+	return ReadCSVWith(r, CSVOptions{})
+}
+
+// ReadCSVWith is the same as `ReadCSV()`, with options (see `CSVOptions`).
+func ReadCSVWith(r io.Reader, opts CSVOptions) ([]*Map[string, string], error) {
+	cr := NewCSVReaderWith(r, opts)
+	var res []*Map[string, string]
+	for {
+		row, err := cr.Next()
+		if err == io.EOF {
+			return res, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, row)
+	}
+}
+
+// CSVReader reads the rows of CSV data one at a time.
+type CSVReader struct {
+	r       *csv.Reader
+	opts    CSVOptions
+	columns []string
+	rows    int
+}
+
+// NewCSVReader returns a CSVReader which reads from 'r', where the first line is the header.
+func NewCSVReader(r io.Reader) *CSVReader {
+	return NewCSVReaderWith(r, CSVOptions{})
+}
+
+// NewCSVReaderWith is the same as `NewCSVReader()`, with options (see `CSVOptions`).
+func NewCSVReaderWith(r io.Reader, opts CSVOptions) *CSVReader {
+	cr := csv.NewReader(r)
+	if opts.Comma != 0 {
+		cr.Comma = opts.Comma
+	}
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+	res := &CSVReader{r: cr, opts: opts}
+	if opts.NoHeader {
+		res.columns = opts.Columns
+	}
+	return res
+}
+
+// Columns returns the columns, reading the header line if it has not been read yet.
+// It returns io.EOF if the data is empty.
+func (cr *CSVReader) Columns() ([]string, error) {
+	if cr.columns != nil || cr.opts.NoHeader {
+		return cr.columns, nil
+	}
+
+	record, err := cr.r.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("error when reading csv header: %w", err)
+	}
+
+	columns := make([]string, len(record))
+	seen := make(map[string]bool, len(record))
+	for i, c := range record {
+		if seen[c] {
+			return nil, fmt.Errorf("error when reading csv header: duplicate column %q", c)
+		}
+		seen[c] = true
+		columns[i] = c
+	}
+	cr.columns = columns
+	return columns, nil
+}
+
+// Next reads the next row. Short rows get `CSVOptions.Missing` for their missing trailing cells,
+// rows with more cells than columns are an error.
+//
+// Next returns io.EOF when there are no more rows.
+func (cr *CSVReader) Next() (*Map[string, string], error) {
+	columns, err := cr.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := cr.r.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("error when reading csv row %d: %w", cr.rows, err)
+	}
+	cr.rows++
+
+	if columns == nil {
+		// no header: columns are numbered from the first row
+		for i := range record {
+			columns = append(columns, strconv.Itoa(i+1))
+		}
+		cr.columns = columns
+	}
+	if len(record) > len(columns) {
+		return nil, fmt.Errorf("error when reading csv row %d: %d cells for %d columns", cr.rows-1, len(record), len(columns))
+	}
+
+	row := &Map[string, string]{}
+	for i, c := range columns {
+		if i < len(record) {
+			row.Set(c, record[i])
+		} else {
+			row.Set(c, cr.opts.Missing)
+		}
+	}
+	return row, nil
+}
@@ -0,0 +1,151 @@
+package ordmap
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteCSV(t *testing.T) {
+	row := func(kvs ...any) *Map[string, any] {
+		m := &Map[string, any]{}
+		for i := 0; i < len(kvs); i += 2 {
+			m.Set(kvs[i].(string), kvs[i+1])
+		}
+		return m
+	}
+	rows := []*Map[string, any]{
+		row("date", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), "amount", 1250.5, "label", "rent, jan"),
+		row("amount", 1e21, "date", nil, "label", `say "hi"`),
+		row("label", "tags", "tags", []any{"a", 1}),
+	}
+
+	// columns follow the first row, a new key is an error:
+	var buf bytes.Buffer
+	err := WriteCSV(&buf, rows, CSVOptions{})
+	assert.ErrorContains(t, err, `row 2: key "tags" is not a column`)
+
+	buf.Reset()
+	err = WriteCSV(&buf, rows, CSVOptions{HeaderUnion: true, Missing: "n/a"})
+	require.NoError(t, err)
+	assert.Equal(t, `date,amount,label,tags
+2024-01-02T00:00:00Z,1250.5,"rent, jan",n/a
+,1000000000000000000000,"say ""hi""",n/a
+n/a,n/a,tags,"[""a"",1]"
+`, buf.String())
+
+	// TSV with explicit columns and formatting:
+	buf.Reset()
+	err = WriteCSV(&buf, rows[:2], CSVOptions{
+		Comma:   '\t',
+		Columns: []string{"label", "amount", "date"},
+		Format: func(column string, v any) (string, error) {
+			if f, ok := v.(float64); ok {
+				return fmt.Sprintf("%.2f", f), nil
+			}
+			return csvCell(v)
+		},
+		UseCRLF: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "label\tamount\tdate\r\n"+
+		"rent, jan\t1250.50\t2024-01-02T00:00:00Z\r\n"+
+		"\"say \"\"hi\"\"\"\t1000000000000000000000.00\t\r\n", buf.String())
+
+	// typed rows, without header:
+	m := &Map[string, int]{}
+	m.Set("b", 2)
+	m.Set("a", -1)
+	buf.Reset()
+	require.NoError(t, WriteCSV(&buf, []*Map[string, int]{m}, CSVOptions{NoHeader: true}))
+	assert.Equal(t, "2,-1\n", buf.String())
+
+	// nil rows are empty records:
+	buf.Reset()
+	err = WriteCSV(&buf, []*Map[string, any]{nil, rows[0], nil}, CSVOptions{Missing: "-"})
+	require.NoError(t, err)
+	assert.Equal(t, `date,amount,label
+-,-,-
+2024-01-02T00:00:00Z,1250.5,"rent, jan"
+-,-,-
+`, buf.String())
+
+	// no rows:
+	buf.Reset()
+	require.NoError(t, WriteCSV(&buf, []*Map[string, int]{}, CSVOptions{NoHeader: true}))
+	assert.Equal(t, "", buf.String())
+
+	err = WriteCSV(&buf, rows, CSVOptions{Columns: []string{"a", "a"}})
+	assert.ErrorContains(t, err, "duplicate column")
+
+	err = WriteCSV(&buf, rows[2:], CSVOptions{Format: func(string, any) (string, error) {
+		return "", fmt.Errorf("nope")
+	}})
+	assert.ErrorContains(t, err, `row 0, column "label": nope`)
+}
+
+func TestReadCSV(t *testing.T) {
+	rows, err := ReadCSV(strings.NewReader("z,a,m\n1,\"x, y\",3\n4,5\n"))
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, []string{"z", "a", "m"}, rows[0].Keys())
+	assert.Equal(t, "x, y", rows[0].Get("a"))
+	assert.Equal(t, []string{"4", "5", ""}, csvValues(rows[1]))
+
+	rows, err = ReadCSVWith(strings.NewReader("1\t2\n3\n"), CSVOptions{Comma: '\t', NoHeader: true, Missing: "-"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, rows[1].Keys())
+	assert.Equal(t, []string{"3", "-"}, csvValues(rows[1]))
+
+	rows, err = ReadCSVWith(strings.NewReader("1,2\n"), CSVOptions{NoHeader: true, Columns: []string{"x", "y"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"x", "y"}, rows[0].Keys())
+
+	rows, err = ReadCSV(strings.NewReader(""))
+	require.NoError(t, err)
+	assert.Empty(t, rows)
+
+	for _, input := range []string{
+		"a,a\n1,2\n",
+		"a,b\n1,2,3\n",
+		"a,b\n\"1,2\n",
+	} {
+		_, err = ReadCSV(strings.NewReader(input))
+		assert.Error(t, err, input)
+	}
+}
+
+func TestCSVReader(t *testing.T) {
+	in := &Map[string, any]{}
+	in.Set("name", "x")
+	in.Set("qty", 2)
+	var buf bytes.Buffer
+	require.NoError(t, WriteCSV(&buf, []*Map[string, any]{in, in}, CSVOptions{}))
+
+	cr := NewCSVReader(&buf)
+	columns, err := cr.Columns()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name", "qty"}, columns)
+
+	for i := 0; i < 2; i++ {
+		row, err := cr.Next()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"x", "2"}, csvValues(row))
+	}
+	_, err = cr.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func csvValues(m *Map[string, string]) []string {
+	var res []string
+	for _, k := range m.Keys() {
+		res = append(res, m.Get(k))
+	}
+	return res
+}
@@ -0,0 +1,394 @@
+package ordmap
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// EnvOptions controls how `UnmarshalEnvWith()` decodes .env files.
+type EnvOptions struct {
+	// Interpolate expands the references to variables ($NAME, ${NAME} and ${NAME:-default})
+	// in unquoted and double-quoted values. Single-quoted values are never expanded.
+	//
+	// Variables are looked up in the entries above the reference, then with Lookup.
+	// Unknown variables expand to the empty string.
+	Interpolate bool
+
+	// Lookup, if set, looks up the variables which are not defined in the file,
+	// e.g. `os.LookupEnv`.
+	Lookup func(name string) (string, bool)
+}
+
+// UnmarshalEnv parses 'data' in the .env format, and returns its entries in the order of the file.
+// With repeated keys, the last value wins, at the position of the first occurrence.
+//
+// Each line is either blank, a comment starting with '#', or an entry "KEY=value", optionally
+// prefixed with "export ". Values can be:
+//   - unquoted: the rest of the line without surrounding whitespace, up to a '#' which follows
+//     whitespace (a comment);
+//   - single-quoted: taken literally, without escapes;
+//   - double-quoted: with the escapes \n, \r, \t, \", \\ and \$.
+//
+// Quoted values can span several lines. Values are not interpolated, see `UnmarshalEnvWith()`.
+func UnmarshalEnv(data []byte) (*Map[string, string], error) {
+	return UnmarshalEnvWith(data, EnvOptions{})
+}
+
+// UnmarshalEnvWith is the same as `UnmarshalEnv()`, with options (see `EnvOptions`).
+func UnmarshalEnvWith(data []byte, opts EnvOptions) (*Map[string, string], error) {
+	d, err := ParseEnvDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	if !opts.Interpolate {
+		return d.Map(), nil
+	}
+
+	res := &Map[string, string]{}
+	lookup := func(name string) (string, bool) {
+		if v, ok := res.Get2(name); ok {
+			return v, true
+		}
+		if opts.Lookup != nil {
+			return opts.Lookup(name)
+		}
+		return "", false
+	}
+	for _, e := range d.doc.entries {
+		v, err := decodeEnvValue(e.rawValue, e.quote, lookup)
+		if err != nil {
+			return nil, fmt.Errorf("error when decoding env value of %q: %w", e.key, err)
+		}
+		res.Set(e.key, v)
+	}
+	return res, nil
+}
+
+// MarshalEnv writes the entries of 'm' in the .env format, one "KEY=value" line per entry.
+// Values are written unquoted when they only contain safe characters, and quoted otherwise,
+// so that they are never interpolated.
+func MarshalEnv(m *Map[string, string]) ([]byte, error) {
+	var sb strings.Builder
+	for _, k := range m.Keys() {
+		if !isEnvKey(k) {
+			return nil, fmt.Errorf("error when encoding env: invalid key %q", k)
+		}
+		v, _ := renderEnvValue(m.Get(k), 0)
+		sb.WriteString(k)
+		sb.WriteByte('=')
+		sb.WriteString(v)
+		sb.WriteByte('\n')
+	}
+	return []byte(sb.String()), nil
+}
+
+// EnvDocument is an editable .env file.
+//
+// It keeps the original text of the file: `Bytes()` returns the original file when it has not
+// been edited, and otherwise only rewrites the lines of the edited entries, keeping their
+// "export " prefix, their trailing comment and, when possible, their quoting. Comment and blank
+// lines stay in place, new entries are appended at the end of the file.
+//
+// Values of the document are not interpolated.
+type EnvDocument struct {
+	doc lineDocument
+}
+
+// ParseEnvDocument parses 'data' into an editable EnvDocument, see `UnmarshalEnv()` for the syntax.
+func ParseEnvDocument(data []byte) (*EnvDocument, error) {
+	src := string(data)
+	d := &EnvDocument{doc: lineDocument{
+		eol:         detectEOL(src),
+		renderKey:   func(key string) string { return key },
+		renderValue: renderEnvValue,
+	}}
+
+	var before []string
+	lineno := 1
+	for len(src) > 0 {
+		line := src
+		if i := strings.IndexByte(src, '\n'); i >= 0 {
+			line = src[:i+1]
+		}
+		trimmed := strings.TrimLeft(line, " \t")
+		if strings.TrimSpace(trimmed) == "" || trimmed[0] == '#' {
+			before = append(before, line)
+			src = src[len(line):]
+			lineno++
+			continue
+		}
+
+		e, n, err := parseEnvEntry(src)
+		if err != nil {
+			return nil, fmt.Errorf("error when parsing env line %d: %w", lineno, err)
+		}
+		e.before = before
+		before = nil
+		d.doc.entries = append(d.doc.entries, e)
+		lineno += strings.Count(src[:n], "\n")
+		src = src[n:]
+	}
+	d.doc.trailer = before
+	return d, nil
+}
+
+// Get returns the value of the key 'key', the last one if the key is repeated.
+func (d *EnvDocument) Get(key string) (string, bool) {
+	return d.doc.get(key)
+}
+
+// Set sets the value of the key 'key'. An existing entry is rewritten in place, the last one if
+// the key is repeated. A new entry is appended at the end of the document.
+func (d *EnvDocument) Set(key, value string) error {
+	if !isEnvKey(key) {
+		return fmt.Errorf("error when setting env value: invalid key %q", key)
+	}
+	d.doc.set(key, value)
+	return nil
+}
+
+// Delete removes all the entries with the key 'key', along with the comment lines right above
+// them, and returns false if there was none.
+func (d *EnvDocument) Delete(key string) bool {
+	return d.doc.delete(key)
+}
+
+// Keys returns the keys of the document in the order of their first occurrence.
+func (d *EnvDocument) Keys() []string {
+	return d.doc.keys()
+}
+
+// Map returns the entries of the document, see `UnmarshalEnv()`.
+func (d *EnvDocument) Map() *Map[string, string] {
+	return d.doc.toMap()
+}
+
+// Bytes returns the text of the document.
+func (d *EnvDocument) Bytes() []byte {
+	return d.doc.bytes()
+}
+
+// parseEnvEntry parses the entry at the start of 'src', and returns it with its length.
+func parseEnvEntry(src string) (*lineEntry, int, error) {
+	e := &lineEntry{}
+	i := 0
+	skipBlanks := func() {
+		for i < len(src) && (src[i] == ' ' || src[i] == '\t') {
+			i++
+		}
+	}
+
+	skipBlanks()
+	if rest := src[i:]; strings.HasPrefix(rest, "export") && len(rest) > 6 && (rest[6] == ' ' || rest[6] == '\t') {
+		i += 6
+		skipBlanks()
+	}
+	e.prefix = src[:i]
+
+	k := i
+	for i < len(src) && isEnvKeyChar(src[i], i == k) {
+		i++
+	}
+	e.rawKey = src[k:i]
+	e.key = e.rawKey
+	if e.key == "" {
+		return nil, 0, fmt.Errorf("invalid key at %q", firstLine(src[k:]))
+	}
+
+	s := i
+	skipBlanks()
+	if i == len(src) || src[i] != '=' {
+		return nil, 0, fmt.Errorf("missing '=' after key %q", e.key)
+	}
+	i++
+	skipBlanks()
+	e.sep = src[s:i]
+
+	v := i
+	switch {
+	case i < len(src) && src[i] == '\'':
+		end := strings.IndexByte(src[i+1:], '\'')
+		if end < 0 {
+			return nil, 0, fmt.Errorf("unterminated single-quoted value of %q", e.key)
+		}
+		e.quote = '\''
+		i += end + 2
+	case i < len(src) && src[i] == '"':
+		i++
+		for i < len(src) && src[i] != '"' {
+			if src[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(src) {
+			return nil, 0, fmt.Errorf("unterminated double-quoted value of %q", e.key)
+		}
+		e.quote = '"'
+		i++
+	default:
+		for i < len(src) && src[i] != '\n' && src[i] != '\r' {
+			if src[i] == '#' && i > v && (src[i-1] == ' ' || src[i-1] == '\t') {
+				break
+			}
+			i++
+		}
+		for i > v && (src[i-1] == ' ' || src[i-1] == '\t') {
+			i--
+		}
+	}
+	e.rawValue = src[v:i]
+
+	// only whitespace and a comment can follow the value
+	end := strings.IndexByte(src[i:], '\n')
+	if end < 0 {
+		end = len(src)
+	} else {
+		end += i + 1
+	}
+	rest, eol := cutEOL(src[i:end])
+	if t := strings.TrimLeft(rest, " \t"); t != "" && t[0] != '#' {
+		return nil, 0, fmt.Errorf("unexpected %q after the value of %q", t, e.key)
+	}
+	e.suffix, e.eol = rest, eol
+	e.raw = src[:end]
+
+	var err error
+	e.value, err = decodeEnvValue(e.rawValue, e.quote, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	return e, end, nil
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexAny(s, "\r\n"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+func isEnvKeyChar(c byte, first bool) bool {
+	switch {
+	case c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+		return true
+	case c == '.' || c == '-' || '0' <= c && c <= '9':
+		return !first
+	}
+	return false
+}
+
+func isEnvKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if !isEnvKeyChar(key[i], i == 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeEnvValue decodes the value 'raw', written with the quotes 'quote'. Variables are expanded
+// with 'lookup' if it is not nil.
+func decodeEnvValue(raw string, quote byte, lookup func(string) (string, bool)) (string, error) {
+	switch quote {
+	case '\'':
+		return raw[1 : len(raw)-1], nil
+	case '"':
+		raw = raw[1 : len(raw)-1]
+	}
+	if lookup == nil && (quote == 0 || !strings.Contains(raw, `\`)) {
+		return raw, nil
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		switch {
+		case c == '\\' && quote == '"' && i+1 < len(raw):
+			i++
+			switch raw[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case '"', '\\', '$':
+				sb.WriteByte(raw[i])
+			default:
+				sb.WriteByte('\\')
+				sb.WriteByte(raw[i])
+			}
+		case c == '$' && lookup != nil:
+			v, n, err := expandEnvVar(raw[i:], lookup)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(v)
+			i += n - 1
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String(), nil
+}
+
+// expandEnvVar expands the reference to a variable at the start of 's', and returns its value
+// and the length of the reference. A '$' which does not start a reference is kept as is.
+func expandEnvVar(s string, lookup func(string) (string, bool)) (string, int, error) {
+	if len(s) > 1 && s[1] == '{' {
+		end := strings.IndexByte(s, '}')
+		if end < 0 {
+			return "", 0, errors.New("unterminated ${ reference")
+		}
+		name, def, hasDefault := strings.Cut(s[2:end], ":-")
+		if !isEnvKey(name) {
+			return "", 0, fmt.Errorf("invalid reference %q", s[:end+1])
+		}
+		v, _ := lookup(name)
+		if v == "" && hasDefault {
+			v = def
+		}
+		return v, end + 1, nil
+	}
+
+	n := 1
+	for n < len(s) && isEnvKeyChar(s[n], n == 1) && s[n] != '.' && s[n] != '-' {
+		n++
+	}
+	if n == 1 {
+		return "$", 1, nil
+	}
+	v, _ := lookup(s[1:n])
+	return v, n, nil
+}
+
+// renderEnvValue renders 'value' so that it is read back as is, without interpolation.
+// The quoting 'quote' of the previous value is kept when possible.
+func renderEnvValue(value string, quote byte) (string, byte) {
+	safe := value != ""
+	literal := !strings.ContainsAny(value, "'\n\r")
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if !(isEnvKeyChar(c, false) || strings.IndexByte("./:@%+,=", c) >= 0) {
+			safe = false
+		}
+	}
+
+	switch {
+	case quote == '"':
+	case quote == '\'' && literal:
+		return "'" + value + "'", '\''
+	case quote == 0 && (safe || value == ""):
+		return value, 0
+	case literal:
+		return "'" + value + "'", '\''
+	}
+
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "\n", `\n`, "\r", `\r`)
+	return `"` + r.Replace(value) + `"`, '"'
+}
@@ -0,0 +1,138 @@
+package ordmap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnmarshalEnv(t *testing.T) {
+	input := `# database
+export DB_HOST=localhost
+DB_PORT = 5432   # default port
+DB_URL="postgres://${DB_HOST}:$DB_PORT/app\n"
+RAW='$DB_HOST \n'
+MULTI="line 1
+line 2"
+EMPTY=
+HASH=a#b
+DEFAULT=${UNSET:-fallback}
+HOME_DIR=$HOME/x
+PRICE="\$5"
+`
+	m, err := UnmarshalEnv([]byte(input))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"DB_HOST", "DB_PORT", "DB_URL", "RAW", "MULTI", "EMPTY", "HASH", "DEFAULT", "HOME_DIR", "PRICE"}, m.Keys())
+	assert.Equal(t, "5432", m.Get("DB_PORT"))
+	assert.Equal(t, "postgres://${DB_HOST}:$DB_PORT/app\n", m.Get("DB_URL"))
+	assert.Equal(t, `$DB_HOST \n`, m.Get("RAW"))
+	assert.Equal(t, "line 1\nline 2", m.Get("MULTI"))
+	assert.Equal(t, "", m.Get("EMPTY"))
+	assert.Equal(t, "a#b", m.Get("HASH"))
+	assert.Equal(t, "$5", m.Get("PRICE"))
+
+	m, err = UnmarshalEnvWith([]byte(input), EnvOptions{
+		Interpolate: true,
+		Lookup: func(name string) (string, bool) {
+			if name == "HOME" {
+				return "/home/me", true
+			}
+			return "", false
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "postgres://localhost:5432/app\n", m.Get("DB_URL"))
+	assert.Equal(t, `$DB_HOST \n`, m.Get("RAW"), "single-quoted values are not interpolated")
+	assert.Equal(t, "fallback", m.Get("DEFAULT"))
+	assert.Equal(t, "/home/me/x", m.Get("HOME_DIR"))
+	assert.Equal(t, "$5", m.Get("PRICE"))
+
+	for _, input := range []string{
+		"NOVALUE\n",
+		"1KEY=x\n",
+		"A='x\n",
+		"A=\"x\n",
+		"A='x' y\n",
+		"=x\n",
+	} {
+		_, err := UnmarshalEnv([]byte(input))
+		assert.Error(t, err, input)
+	}
+
+	_, err = UnmarshalEnvWith([]byte("A=${B\n"), EnvOptions{Interpolate: true})
+	assert.ErrorContains(t, err, "unterminated")
+
+	_, err = UnmarshalEnv([]byte("A=1\nB='x\ny'\nC\n"))
+	assert.ErrorContains(t, err, "line 4")
+}
+
+func TestMarshalEnv(t *testing.T) {
+	m := &Map[string, string]{}
+	m.Set("PLAIN", "a/b:c")
+	m.Set("SPACES", "a b")
+	m.Set("DOLLAR", "$HOME")
+	m.Set("QUOTE", "it's\n$x")
+	m.Set("EMPTY", "")
+
+	out, err := MarshalEnv(m)
+	require.NoError(t, err)
+	assert.Equal(t, `PLAIN=a/b:c
+SPACES='a b'
+DOLLAR='$HOME'
+QUOTE="it's\n\$x"
+EMPTY=
+`, string(out))
+
+	back, err := UnmarshalEnvWith(out, EnvOptions{Interpolate: true})
+	require.NoError(t, err)
+	assert.Equal(t, m, back)
+
+	m.Set("bad key", "x")
+	_, err = MarshalEnv(m)
+	assert.Error(t, err)
+}
+
+func TestEnvDocument(t *testing.T) {
+	input := `# service
+export PORT=8080 # web
+
+# credentials
+USER='admin'
+PASS="secret"
+TOKEN=abc`
+
+	d, err := ParseEnvDocument([]byte(input))
+	require.NoError(t, err)
+	assert.Equal(t, input, string(d.Bytes()), "unedited documents are kept as is")
+
+	require.NoError(t, d.Set("PORT", "9090"))
+	require.NoError(t, d.Set("USER", "root user"))
+	require.NoError(t, d.Set("PASS", "new"))
+	require.NoError(t, d.Set("TOKEN", "a b"))
+	require.NoError(t, d.Set("ADDED", "1"))
+	assert.Error(t, d.Set("bad key", "1"))
+	assert.Equal(t, `# service
+export PORT=9090 # web
+
+# credentials
+USER='root user'
+PASS="new"
+TOKEN='a b'
+ADDED=1
+`, string(d.Bytes()))
+
+	assert.True(t, d.Delete("USER"))
+	assert.Equal(t, `# service
+export PORT=9090 # web
+
+PASS="new"
+TOKEN='a b'
+ADDED=1
+`, string(d.Bytes()))
+
+	assert.Equal(t, []string{"PORT", "PASS", "TOKEN", "ADDED"}, d.Keys())
+	v, ok := d.Get("PASS")
+	assert.True(t, ok)
+	assert.Equal(t, "new", v)
+}
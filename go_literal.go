@@ -0,0 +1,435 @@
+package ordmap
+
+import (
+	"fmt"
+	"go/format"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GoLiteral returns a gofmt-formatted Go expression which reconstructs 'v', for example to turn
+// a decoded document into a test fixture:
+//
+//	ordmap.FromPairs([]ordmap.Pair[string, any]{
+//		{Key: "name", Value: "demo"},
+//		{Key: "tags", Value: []any{"a", int64(1)}},
+//	}...)
+//
+// Maps are written with `FromPairs()`, in the order of their keys, and Any values with `NewAny()`.
+// Identifiers of this package are qualified with "ordmap.".
+//
+// Numbers keep their type: a conversion is written when the type of the value is not the
+// default type of the literal (int, float64) and is not implied by the context. Go maps are
+// written with sorted keys, structs with their non-zero exported fields, and time.Time values
+// with `time.Date()`. Values which can not be written as an expression, such as functions,
+// channels, or structs with unexported fields, are an error.
+func GoLiteral(v any) (string, error) {
+	var sb strings.Builder
+	err := writeGoLiteral(&sb, "", reflect.ValueOf(v), anyIfaceType)
+	if err != nil {
+		return "", err
+	}
+	src, err := format.Source([]byte(sb.String()))
+	if err != nil {
+		return "", fmt.Errorf("error when formatting go literal: %w", err)
+	}
+	return string(src), nil
+}
+
+// GoString implements `fmt.GoStringer`, so that "%#v" prints the Go expression returned by
+// `GoLiteral()` instead of the internals of the Map, including for Map values held in struct
+// fields: `*ordmap.FromPairs(...)`. Errors are written as a comment.
+func (m Map[K, V]) GoString() string {
+	return goString(m)
+}
+
+// mapFields has the fields of a Map, without its methods.
+type mapFields[K comparable, V any] Map[K, V]
+
+// Format implements `fmt.Formatter`, so that "%#v" prints a *Map as the *Map expression returned
+// by `GoLiteral()`: `ordmap.FromPairs(...)`. Other verbs print the fields of the Map.
+func (m *Map[K, V]) Format(f fmt.State, verb rune) {
+	if verb == 'v' && f.Flag('#') {
+		io.WriteString(f, goString(m))
+		return
+	}
+	fmt.Fprintf(f, fmt.FormatString(f, verb), (*mapFields[K, V])(m))
+}
+
+// GoString implements `fmt.GoStringer`, see `GoLiteral()`. A *Any is printed as an Any.
+func (x Any) GoString() string {
+	return goString(x)
+}
+
+func goString(v any) string {
+	s, err := GoLiteral(v)
+	if err != nil {
+		return "/* " + err.Error() + " */"
+	}
+	return s
+}
+
+var (
+	anyIfaceType = reflect.TypeOf((*any)(nil)).Elem()
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// isMapType tells if 't' is an instance of Map, and returns its key and value types.
+func isMapType(t reflect.Type) (reflect.Type, reflect.Type, bool) {
+	if t.Kind() != reflect.Struct || t.PkgPath() != anyType.PkgPath() || !strings.HasPrefix(t.Name(), "Map[") {
+		return nil, nil, false
+	}
+	mt := t.Field(0).Type
+	return mt.Key(), mt.Elem(), true
+}
+
+// goTypeExpr returns the Go source of the type 't'.
+func goTypeExpr(t reflect.Type) (string, error) {
+	if k, v, ok := isMapType(t); ok {
+		ks, err := goTypeExpr(k)
+		if err != nil {
+			return "", err
+		}
+		vs, err := goTypeExpr(v)
+		if err != nil {
+			return "", err
+		}
+		return "ordmap.Map[" + ks + ", " + vs + "]", nil
+	}
+
+	if t.Name() != "" {
+		if strings.Contains(t.Name(), "[") {
+			return "", fmt.Errorf("unsupported generic type %v", t)
+		}
+		return t.String(), nil
+	}
+	switch t.Kind() {
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			return "any", nil
+		}
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		elem, err := goTypeExpr(t.Elem())
+		if err != nil {
+			return "", err
+		}
+		switch t.Kind() {
+		case reflect.Pointer:
+			return "*" + elem, nil
+		case reflect.Slice:
+			return "[]" + elem, nil
+		}
+		return "[" + strconv.Itoa(t.Len()) + "]" + elem, nil
+	case reflect.Map:
+		k, err := goTypeExpr(t.Key())
+		if err != nil {
+			return "", err
+		}
+		v, err := goTypeExpr(t.Elem())
+		if err != nil {
+			return "", err
+		}
+		return "map[" + k + "]" + v, nil
+	}
+	return "", fmt.Errorf("unsupported type %v", t)
+}
+
+// writeGoLiteral writes the expression of 'rv', where a value of the type 'static' is expected.
+func writeGoLiteral(sb *strings.Builder, where string, rv reflect.Value, static reflect.Type) error {
+	if !rv.IsValid() {
+		sb.WriteString("nil")
+		return nil
+	}
+	t := rv.Type()
+	if t.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			sb.WriteString("nil")
+			return nil
+		}
+		return writeGoLiteral(sb, where, rv.Elem(), static)
+	}
+
+	typeExpr, err := goTypeExpr(t)
+	if err != nil {
+		return fmt.Errorf("error when writing go literal %s: %w", where, err)
+	}
+	// exact tells if the type of the value is implied by the context
+	exact := t == static
+
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		lit, defaultType := goBasicLiteral(rv)
+		if exact || t.Name() == defaultType && t.PkgPath() == "" {
+			sb.WriteString(lit)
+		} else {
+			sb.WriteString(typeExpr + "(" + lit + ")")
+		}
+		return nil
+
+	case reflect.Pointer:
+		if rv.IsNil() {
+			if exact {
+				sb.WriteString("nil")
+			} else {
+				sb.WriteString("(" + typeExpr + ")(nil)")
+			}
+			return nil
+		}
+		elem := rv.Elem()
+		if _, _, ok := isMapType(elem.Type()); ok {
+			return writeGoMap(sb, where, elem)
+		}
+		if elem.Kind() == reflect.Struct {
+			sb.WriteByte('&')
+			return writeGoLiteral(sb, where, elem, elem.Type())
+		}
+		return fmt.Errorf("error when writing go literal %s: unsupported pointer type %v", where, t)
+
+	case reflect.Slice:
+		if rv.IsNil() {
+			if exact {
+				sb.WriteString("nil")
+			} else {
+				sb.WriteString(typeExpr + "(nil)")
+			}
+			return nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 && t.Elem().PkgPath() == "" {
+			if t.Name() == "" {
+				typeExpr = "[]byte"
+			}
+			sb.WriteString(typeExpr + "(" + strconv.Quote(string(rv.Bytes())) + ")")
+			return nil
+		}
+		fallthrough
+	case reflect.Array:
+		sb.WriteString(typeExpr + "{")
+		if rv.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		for i := 0; i < rv.Len(); i++ {
+			err := writeGoLiteral(sb, fmt.Sprintf("%s[%d]", where, i), rv.Index(i), t.Elem())
+			if err != nil {
+				return err
+			}
+			sb.WriteString(",\n")
+		}
+		sb.WriteString("}")
+		return nil
+
+	case reflect.Map:
+		if rv.IsNil() {
+			if exact {
+				sb.WriteString("nil")
+			} else {
+				sb.WriteString(typeExpr + "(nil)")
+			}
+			return nil
+		}
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return goLiteralLess(keys[i], keys[j]) })
+		sb.WriteString(typeExpr + "{")
+		if len(keys) > 0 {
+			sb.WriteString("\n")
+		}
+		for _, k := range keys {
+			err := writeGoLiteral(sb, where, k, t.Key())
+			if err != nil {
+				return err
+			}
+			sb.WriteString(": ")
+			err = writeGoLiteral(sb, fmt.Sprintf("%s[%v]", where, k), rv.MapIndex(k), t.Elem())
+			if err != nil {
+				return err
+			}
+			sb.WriteString(",\n")
+		}
+		sb.WriteString("}")
+		return nil
+
+	case reflect.Struct:
+		if _, _, ok := isMapType(t); ok {
+			sb.WriteByte('*')
+			return writeGoMap(sb, where, rv)
+		}
+		if t == anyType {
+			sb.WriteString("ordmap.NewAny(")
+			err := writeGoLiteral(sb, where, reflect.ValueOf(rv.Interface().(Any).v), anyIfaceType)
+			if err != nil {
+				return err
+			}
+			sb.WriteString(")")
+			return nil
+		}
+		if t == timeType {
+			sb.WriteString(goTimeLiteral(rv.Interface().(time.Time)))
+			return nil
+		}
+
+		sb.WriteString(typeExpr + "{")
+		first := true
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			fv := rv.Field(i)
+			if fv.IsZero() {
+				continue
+			}
+			if !f.IsExported() {
+				return fmt.Errorf("error when writing go literal %s: unexported field %s of %v", where, f.Name, t)
+			}
+			if first {
+				sb.WriteString("\n")
+				first = false
+			}
+			sb.WriteString(f.Name + ": ")
+			err := writeGoLiteral(sb, where+"."+f.Name, fv, f.Type)
+			if err != nil {
+				return err
+			}
+			sb.WriteString(",\n")
+		}
+		sb.WriteString("}")
+		return nil
+	}
+	return fmt.Errorf("error when writing go literal %s: unsupported type %v", where, t)
+}
+
+type goLiteralMap interface {
+	goLiteralEntries() ([]reflect.Value, []reflect.Value)
+}
+
+// goLiteralEntries returns the keys and values of 'm', in order, with their static types.
+func (m *Map[K, V]) goLiteralEntries() ([]reflect.Value, []reflect.Value) {
+	keys := make([]reflect.Value, len(m.keys))
+	values := make([]reflect.Value, len(m.keys))
+	for i, k := range m.keys {
+		k := k
+		v := m.m[k]
+		keys[i] = reflect.ValueOf(&k).Elem()
+		values[i] = reflect.ValueOf(&v).Elem()
+	}
+	return keys, values
+}
+
+// writeGoMap writes the Map 'rv' (a Map value) as a call to FromPairs.
+func writeGoMap(sb *strings.Builder, where string, rv reflect.Value) error {
+	kt, vt, _ := isMapType(rv.Type())
+	ks, err := goTypeExpr(kt)
+	if err != nil {
+		return fmt.Errorf("error when writing go literal %s: %w", where, err)
+	}
+	vs, err := goTypeExpr(vt)
+	if err != nil {
+		return fmt.Errorf("error when writing go literal %s: %w", where, err)
+	}
+
+	// the entries are read through a method: values read from the unexported fields of the Map
+	// could not be converted back to interfaces
+	var ptr reflect.Value
+	if rv.CanAddr() {
+		ptr = rv.Addr()
+	} else {
+		ptr = reflect.New(rv.Type())
+		ptr.Elem().Set(rv)
+	}
+	keys, values := ptr.Interface().(goLiteralMap).goLiteralEntries()
+	if len(keys) == 0 {
+		sb.WriteString("ordmap.FromPairs[" + ks + ", " + vs + "]()")
+		return nil
+	}
+	sb.WriteString("ordmap.FromPairs([]ordmap.Pair[" + ks + ", " + vs + "]{\n")
+	for i, k := range keys {
+		sb.WriteString("{Key: ")
+		err := writeGoLiteral(sb, where, k, kt)
+		if err != nil {
+			return err
+		}
+		sb.WriteString(", Value: ")
+		err = writeGoLiteral(sb, fmt.Sprintf("%s.%v", where, k), values[i], vt)
+		if err != nil {
+			return err
+		}
+		sb.WriteString("},\n")
+	}
+	sb.WriteString("}...)")
+	return nil
+}
+
+// goBasicLiteral returns the literal of a boolean, string or number, and its default type.
+func goBasicLiteral(rv reflect.Value) (string, string) {
+	switch rv.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), "bool"
+	case reflect.String:
+		return strconv.Quote(rv.String()), "string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), "int"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(rv.Uint(), 10), "int"
+	}
+
+	f := rv.Float()
+	bits := 64
+	if rv.Kind() == reflect.Float32 {
+		bits = 32
+	}
+	switch {
+	case math.IsNaN(f):
+		return "math.NaN()", "float64"
+	case math.IsInf(f, 1):
+		return "math.Inf(1)", "float64"
+	case math.IsInf(f, -1):
+		return "math.Inf(-1)", "float64"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, bits)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s, "float64"
+}
+
+func goTimeLiteral(t time.Time) string {
+	loc := "time.UTC"
+	switch {
+	case t.Location() == time.UTC:
+	case t.Location() == time.Local:
+		loc = "time.Local"
+	default:
+		name, offset := t.Zone()
+		loc = fmt.Sprintf("time.FixedZone(%q, %d)", name, offset)
+	}
+	return fmt.Sprintf("time.Date(%d, time.%v, %d, %d, %d, %d, %d, %s)",
+		t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+}
+
+// goLiteralLess orders the keys of go maps: numbers and strings by value, other keys by their
+// formatted value.
+func goLiteralLess(a, b reflect.Value) bool {
+	for a.Kind() == reflect.Interface && !a.IsNil() {
+		a = a.Elem()
+	}
+	for b.Kind() == reflect.Interface && !b.IsNil() {
+		b = b.Elem()
+	}
+	if a.Kind() == b.Kind() {
+		switch a.Kind() {
+		case reflect.String:
+			return a.String() < b.String()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return a.Int() < b.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return a.Uint() < b.Uint()
+		case reflect.Float32, reflect.Float64:
+			return a.Float() < b.Float()
+		}
+	}
+	return fmt.Sprint(a) < fmt.Sprint(b)
+}
@@ -0,0 +1,126 @@
+package ordmap
+
+import (
+	"fmt"
+	"go/parser"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoLiteral(t *testing.T) {
+	x := mustAny(t, `{"name": "demo", "tags": ["a", 1.5, true, null], "empty": {}}`)
+	src, err := GoLiteral(x.V())
+	require.NoError(t, err)
+	assert.Equal(t, `ordmap.FromPairs([]ordmap.Pair[string, any]{
+	{Key: "name", Value: "demo"},
+	{Key: "tags", Value: []any{
+		"a",
+		1.5,
+		true,
+		nil,
+	}},
+	{Key: "empty", Value: ordmap.FromPairs[string, any]()},
+}...)`, src)
+
+	// "%#v" prints an expression of the type of the value for a *Map and a Map, and the same
+	// expression for an Any:
+	m := x.V().(*Map[string, any])
+	assert.Equal(t, src, fmt.Sprintf("%#v", m))
+	assert.Equal(t, "*"+src, fmt.Sprintf("%#v", *m))
+	assert.Equal(t, "(*ordmap.Map[string, any])(nil)", fmt.Sprintf("%#v", (*Map[string, any])(nil)))
+	assert.Equal(t, "ordmap.NewAny("+src+")", fmt.Sprintf("%#v", x))
+	assert.Equal(t, "ordmap.NewAny("+src+")", fmt.Sprintf("%#v", &x))
+}
+
+func TestGoString_StructField(t *testing.T) {
+	type Entry struct {
+		Name  string
+		Attrs Map[string, int]
+		Index *Map[string, int]
+	}
+	e := Entry{
+		Name:  "e",
+		Attrs: *FromPairs(Pair[string, int]{Key: "a", Value: 1}),
+		Index: FromPairs(Pair[string, int]{Key: "b", Value: 2}),
+	}
+
+	// fmt prints the fields of the struct, and calls GoString on the Map value and Format on
+	// the *Map value:
+	out := fmt.Sprintf("%#v", e)
+	assert.Equal(t, "ordmap.Entry{Name:\"e\", "+
+		"Attrs:*ordmap.FromPairs([]ordmap.Pair[string, int]{\n\t{Key: \"a\", Value: 1},\n}...), "+
+		"Index:ordmap.FromPairs([]ordmap.Pair[string, int]{\n\t{Key: \"b\", Value: 2},\n}...)}", out)
+	assert.NotContains(t, out, "keys")
+	assert.Equal(t, out, fmt.Sprintf("%#v", &e)[1:])
+
+	// other verbs print the fields of the Map:
+	assert.Equal(t, "&{map[b:2] [b]}", fmt.Sprintf("%v", e.Index))
+	assert.Equal(t, "{map[a:1] [a]}", fmt.Sprintf("%v", e.Attrs))
+}
+
+func TestGoLiteral_Types(t *testing.T) {
+	type Item struct {
+		Name  string
+		Count int
+		Data  []byte
+		Sub   *Map[int, float32]
+		When  time.Time
+		Extra map[string]any
+	}
+
+	tests := []struct {
+		v        any
+		expected string
+	}{
+		{nil, "nil"},
+		{3, "3"},
+		{int64(3), "int64(3)"},
+		{uint8(3), "uint8(3)"},
+		{2.0, "2.0"},
+		{float32(0.5), "float32(0.5)"},
+		{math.Inf(-1), "math.Inf(-1)"},
+		{"a\"b", `"a\"b"`},
+		{[]byte("hi\n"), `[]byte("hi\n")`},
+		{[]int(nil), "[]int(nil)"},
+		{[]any{int64(1), "x"}, "[]any{\n\tint64(1),\n\t\"x\",\n}"},
+		{map[string]int{"b": 2, "a": 1}, "map[string]int{\n\t\"a\": 1,\n\t\"b\": 2,\n}"},
+		{(*Map[string, any])(nil), "(*ordmap.Map[string, any])(nil)"},
+		{FromPairs[int, string](), "ordmap.FromPairs[int, string]()"},
+		{*FromPairs(Pair[string, bool]{Key: "t", Value: true}), "*ordmap.FromPairs([]ordmap.Pair[string, bool]{\n\t{Key: \"t\", Value: true},\n}...)"},
+		{NewAny(int64(2)), "ordmap.NewAny(int64(2))"},
+		{time.Date(2024, 5, 6, 7, 8, 9, 10, time.UTC), "time.Date(2024, time.May, 6, 7, 8, 9, 10, time.UTC)"},
+		{time.Date(2024, 5, 6, 7, 8, 9, 0, time.FixedZone("CET", 3600)), `time.Date(2024, time.May, 6, 7, 8, 9, 0, time.FixedZone("CET", 3600))`},
+		{
+			&Item{Name: "a", Data: []byte{1}, Sub: FromPairs(Pair[int, float32]{Key: 2, Value: 1})},
+			"&ordmap.Item{\n\tName: \"a\",\n\tData: []byte(\"\\x01\"),\n\tSub: ordmap.FromPairs([]ordmap.Pair[int, float32]{\n\t\t{Key: 2, Value: 1.0},\n\t}...),\n}",
+		},
+	}
+	for _, test := range tests {
+		src, err := GoLiteral(test.v)
+		require.NoError(t, err, "%T", test.v)
+		assert.Equal(t, test.expected, src)
+		_, err = parser.ParseExpr(src)
+		assert.NoError(t, err, src)
+	}
+}
+
+func TestGoLiteral_Errors(t *testing.T) {
+	inputs := []any{
+		make(chan int),
+		func() {},
+		[]any{time.Now},
+		FromPairs(Pair[string, any]{Key: "a", Value: struct{ x int }{1}}),
+		MergeTarget[string, int]{m: &Map[string, int]{}},
+	}
+	for _, input := range inputs {
+		_, err := GoLiteral(input)
+		assert.Error(t, err, "%T", input)
+	}
+
+	m := FromPairs(Pair[string, any]{Key: "f", Value: func() {}})
+	assert.Contains(t, fmt.Sprintf("%#v", m), "/* error when writing go literal .f:")
+}
@@ -0,0 +1,561 @@
+package ordmap
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// INIOptions controls how `UnmarshalINIWith()` decodes INI files.
+type INIOptions struct {
+	// MultiValue collects the values of a key which is repeated in a section into a []any,
+	// as git does for multi-valued variables. Without it, the last value wins.
+	MultiValue bool
+}
+
+// UnmarshalINI parses the INI file 'data' and stores the result in 'v'.
+//
+// The file is decoded as a `*Map[string, any]` of sections, in the order of the file, where each
+// section is a `*Map[string, any]` of string values, in the order of the file. Keys which appear
+// before the first section header are stored in the section "". Repeated sections are merged.
+//
+// The syntax covers Windows-style and git-style files:
+//   - comment lines start with ';' or '#', and so do inline comments when they follow whitespace;
+//   - section headers are "[name]", or "[name "subsection"]" for git subsections, which are stored
+//     under the key `name "subsection"` (see `INISection()`);
+//   - entries are "key = value", or "key" alone for an empty value;
+//   - a value which is enclosed in double quotes is unquoted, with the escapes \", \\, \n and \t.
+//
+// The decoded value is stored in 'v' with `Any.DecodeIntoWith()` and `DecodeOptions.WeaklyTyped`,
+// so that a `Map[string, *Map[string, string]]` or a struct with numeric fields can be used.
+func UnmarshalINI(data []byte, v any) error {
+	return UnmarshalINIWith(data, v, INIOptions{})
+}
+
+// UnmarshalINIWith is the same as `UnmarshalINI()`, with options (see `INIOptions`).
+func UnmarshalINIWith(data []byte, v any, opts INIOptions) error {
+	d, err := ParseINIDocument(data)
+	if err != nil {
+		return err
+	}
+
+	res := &Map[string, any]{}
+	for _, s := range d.sections {
+		if s.name == "" && len(s.doc.entries) == 0 {
+			continue
+		}
+		section, ok := res.Get(s.name).(*Map[string, any])
+		if !ok {
+			section = &Map[string, any]{}
+			res.Set(s.name, section)
+		}
+		for _, e := range s.doc.entries {
+			prev, exists := section.Get2(e.key)
+			if !exists || !opts.MultiValue {
+				section.Set(e.key, e.value)
+				continue
+			}
+			if values, ok := prev.([]any); ok {
+				section.Set(e.key, append(values, e.value))
+			} else {
+				section.Set(e.key, []any{prev, e.value})
+			}
+		}
+	}
+
+	return Any{v: res}.DecodeIntoWith(v, DecodeOptions{WeaklyTyped: true})
+}
+
+// MarshalINI returns the INI encoding of 'v', which must represent a Map of sections: an Any
+// holding an object, a Map, a struct or a go map, where each section is itself a Map.
+//
+// Sections and keys are written in the order of the Maps. Entries of 'v' which are not Maps,
+// and the entries of the section "", are written first, before any section header. Arrays are
+// written as repeated keys, and values are formatted the same way as by `WriteCSV()`.
+func MarshalINI(v any) ([]byte, error) {
+	root, err := toAnyValue("", reflect.ValueOf(v))
+	if err != nil {
+		return nil, err
+	}
+	m, ok := root.(*Map[string, any])
+	if !ok {
+		return nil, fmt.Errorf("error when encoding ini: expected a map of sections, got %T", root)
+	}
+
+	var sb strings.Builder
+	var sections []string
+	var global []*Map[string, any]
+	plain := &Map[string, any]{}
+	for _, k := range m.Keys() {
+		if section, ok := m.Get(k).(*Map[string, any]); ok {
+			if k == "" {
+				global = append(global, section)
+			} else {
+				sections = append(sections, k)
+			}
+			continue
+		}
+		plain.Set(k, m.Get(k))
+	}
+	global = append([]*Map[string, any]{plain}, global...)
+
+	for _, section := range global {
+		err = writeINIEntries(&sb, "", section)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, k := range sections {
+		if name, ok := parseINIHeader("[" + k + "]"); !ok || name != k {
+			return nil, fmt.Errorf("error when encoding ini: invalid section name %q", k)
+		}
+		if sb.Len() > 0 {
+			sb.WriteByte('\n')
+		}
+		sb.WriteString("[" + k + "]\n")
+		err = writeINIEntries(&sb, k, m.Get(k).(*Map[string, any]))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return []byte(sb.String()), nil
+}
+
+func writeINIEntries(sb *strings.Builder, section string, m *Map[string, any]) error {
+	for _, k := range m.Keys() {
+		if !isINIKey(k) {
+			return fmt.Errorf("error when encoding ini section %q: invalid key %q", section, k)
+		}
+		values, ok := m.Get(k).([]any)
+		if !ok {
+			values = []any{m.Get(k)}
+		}
+		for _, v := range values {
+			// values are formatted as the cells of `WriteCSV()`
+			s, err := csvCell(v)
+			if err != nil {
+				return fmt.Errorf("error when encoding ini value %q of section %q: %w", k, section, err)
+			}
+			text, _ := renderINIValue(s, 0)
+			if text == "" {
+				sb.WriteString(k + " =\n")
+				continue
+			}
+			sb.WriteString(k + " = " + text + "\n")
+		}
+	}
+	return nil
+}
+
+// INISection returns the name under which the git-style subsection 'sub' of the section 'name'
+// ("[name "sub"]") is decoded: `name "sub"`, where '"' and '\' are escaped in 'sub'.
+func INISection(name, sub string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return name + ` "` + r.Replace(sub) + `"`
+}
+
+// INIDocument is an editable INI file.
+//
+// It keeps the original text of the file: `Bytes()` returns the original file when it has not
+// been edited, and otherwise only rewrites the lines of the edited entries, keeping their inline
+// comments. Comment and blank lines stay in place, new entries are added at the end of their
+// section (before its trailing blank lines), and new sections at the end of the file.
+type INIDocument struct {
+	// sections[0] is the section "" of the entries which precede the first header
+	sections []*iniSection
+	eol      string
+}
+
+type iniSection struct {
+	name string
+	// before holds the comment lines right above the header, header is the header line
+	before []string
+	header string
+	doc    lineDocument
+}
+
+// ParseINIDocument parses 'data' into an editable INIDocument, see `UnmarshalINI()` for the syntax.
+func ParseINIDocument(data []byte) (*INIDocument, error) {
+	src := string(data)
+	d := &INIDocument{eol: detectEOL(src)}
+	current := d.newSection("")
+	d.sections = append(d.sections, current)
+
+	var pending []string
+	for i, line := range splitLines(src) {
+		content, eol := cutEOL(line)
+		trimmed := strings.TrimLeft(content, " \t")
+		if trimmed == "" || trimmed[0] == ';' || trimmed[0] == '#' {
+			pending = append(pending, line)
+			continue
+		}
+
+		if trimmed[0] == '[' {
+			name, ok := parseINIHeader(trimmed)
+			if !ok {
+				return nil, fmt.Errorf("error when parsing ini line %d: invalid section header %q", i+1, trimmed)
+			}
+			// the comments right above the header belong to the section
+			n := len(pending)
+			for n > 0 && strings.TrimSpace(pending[n-1]) != "" {
+				n--
+			}
+			current.doc.trailer = pending[:n:n]
+			current = d.newSection(name)
+			current.before = pending[n:]
+			current.header = line
+			d.sections = append(d.sections, current)
+			pending = nil
+			continue
+		}
+
+		e, err := parseINIEntry(content)
+		if err != nil {
+			return nil, fmt.Errorf("error when parsing ini line %d: %w", i+1, err)
+		}
+		e.before = pending
+		e.raw, e.eol = line, eol
+		current.doc.entries = append(current.doc.entries, e)
+		pending = nil
+	}
+	current.doc.trailer = pending
+	return d, nil
+}
+
+func (d *INIDocument) newSection(name string) *iniSection {
+	return &iniSection{name: name, doc: lineDocument{
+		eol:         d.eol,
+		sep:         " = ",
+		placeAfter:  true,
+		renderKey:   func(key string) string { return key },
+		renderValue: renderINIValue,
+	}}
+}
+
+// Sections returns the names of the sections in the order of their first occurrence. The section
+// "" comes first if there are entries before the first header.
+func (d *INIDocument) Sections() []string {
+	var res []string
+	seen := map[string]bool{}
+	for _, s := range d.sections {
+		if seen[s.name] || s.name == "" && len(s.doc.entries) == 0 {
+			continue
+		}
+		seen[s.name] = true
+		res = append(res, s.name)
+	}
+	return res
+}
+
+// Keys returns the keys of 'section' in the order of their first occurrence.
+func (d *INIDocument) Keys(section string) []string {
+	return d.sectionMap(section).Keys()
+}
+
+// Get returns the value of 'key' in 'section', the last one if the key is repeated.
+func (d *INIDocument) Get(section, key string) (string, bool) {
+	for i := len(d.sections) - 1; i >= 0; i-- {
+		if s := d.sections[i]; s.name == section {
+			if v, ok := s.doc.get(key); ok {
+				return v, true
+			}
+		}
+	}
+	return "", false
+}
+
+// GetAll returns all the values of 'key' in 'section', for multi-valued keys.
+func (d *INIDocument) GetAll(section, key string) []string {
+	var res []string
+	for _, s := range d.sections {
+		if s.name != section {
+			continue
+		}
+		for _, e := range s.doc.entries {
+			if e.key == key {
+				res = append(res, e.value)
+			}
+		}
+	}
+	return res
+}
+
+// Set sets the value of 'key' in 'section'. An existing entry is rewritten in place, the last one
+// if the key is repeated. A new entry is appended at the end of the section, which is appended
+// at the end of the document if it does not exist.
+func (d *INIDocument) Set(section, key, value string) error {
+	if !isINIKey(key) {
+		return fmt.Errorf("error when setting ini value: invalid key %q", key)
+	}
+
+	var target *iniSection
+	for i := len(d.sections) - 1; i >= 0; i-- {
+		s := d.sections[i]
+		if s.name != section {
+			continue
+		}
+		if s.doc.find(key) >= 0 {
+			s.doc.set(key, value)
+			return nil
+		}
+		if target == nil {
+			target = s
+		}
+	}
+
+	if target == nil {
+		if name, ok := parseINIHeader("[" + section + "]"); !ok || name != section {
+			return fmt.Errorf("error when setting ini value: invalid section name %q", section)
+		}
+		target = d.newSection(section)
+		target.header = "[" + section + "]" + d.eol
+		if d.ensureEOL() {
+			target.before = []string{d.eol}
+		}
+		d.sections = append(d.sections, target)
+	}
+
+	if len(target.doc.entries) == 0 && len(target.doc.trailer) == 0 && target.header != "" && !strings.HasSuffix(target.header, "\n") {
+		target.header += d.eol
+	}
+	target.doc.set(key, value)
+	return nil
+}
+
+// ensureEOL adds a line ending to the last line of the document if it has none, and returns
+// false if the document is empty.
+func (d *INIDocument) ensureEOL() bool {
+	for i := len(d.sections) - 1; i >= 0; i-- {
+		s := d.sections[i]
+		if s.doc.ensureEOL() {
+			return true
+		}
+		if s.header != "" {
+			if !strings.HasSuffix(s.header, "\n") {
+				s.header += d.eol
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// Delete removes all the entries with the key 'key' in 'section', along with the comment lines
+// right above them, and returns false if there was none.
+func (d *INIDocument) Delete(section, key string) bool {
+	found := false
+	for _, s := range d.sections {
+		if s.name == section && s.doc.delete(key) {
+			found = true
+		}
+	}
+	return found
+}
+
+// DeleteSection removes all the occurrences of 'section', with their comments and entries,
+// and returns false if there was none. Deleting the section "" removes the entries which precede
+// the first header, and keeps the comment and blank lines around them (such as the header comment
+// of the file).
+func (d *INIDocument) DeleteSection(section string) bool {
+	found := false
+	sections := d.sections[:0]
+	for _, s := range d.sections {
+		switch {
+		case s.name != section:
+			sections = append(sections, s)
+		case s.name == "":
+			found = len(s.doc.entries) > 0
+			var lines []string
+			for _, e := range s.doc.entries {
+				lines = append(lines, e.before...)
+			}
+			s.doc.trailer = append(lines, s.doc.trailer...)
+			s.doc.entries = nil
+			sections = append(sections, s)
+		default:
+			found = true
+		}
+	}
+	d.sections = sections
+	return found
+}
+
+// Map returns the sections of the document, see `UnmarshalINI()`.
+func (d *INIDocument) Map() *Map[string, *Map[string, string]] {
+	res := &Map[string, *Map[string, string]]{}
+	for _, name := range d.Sections() {
+		res.Set(name, d.sectionMap(name))
+	}
+	return res
+}
+
+func (d *INIDocument) sectionMap(section string) *Map[string, string] {
+	res := &Map[string, string]{}
+	for _, s := range d.sections {
+		if s.name != section {
+			continue
+		}
+		for _, e := range s.doc.entries {
+			res.Set(e.key, e.value)
+		}
+	}
+	return res
+}
+
+// Bytes returns the text of the document.
+func (d *INIDocument) Bytes() []byte {
+	var sb strings.Builder
+	for _, s := range d.sections {
+		for _, l := range s.before {
+			sb.WriteString(l)
+		}
+		sb.WriteString(s.header)
+		sb.Write(s.doc.bytes())
+	}
+	return []byte(sb.String())
+}
+
+// parseINIHeader parses the section header 'line', which starts with '['.
+func parseINIHeader(line string) (string, bool) {
+	end := -1
+	inQuote := false
+	for i := 1; i < len(line) && end < 0; i++ {
+		switch {
+		case line[i] == '\\' && inQuote:
+			i++
+		case line[i] == '"':
+			inQuote = !inQuote
+		case line[i] == ']' && !inQuote:
+			end = i
+		case line[i] == '\n' || line[i] == '\r':
+			return "", false
+		}
+	}
+	if end < 0 {
+		return "", false
+	}
+	if rest := strings.TrimLeft(line[end+1:], " \t"); rest != "" && rest[0] != ';' && rest[0] != '#' {
+		return "", false
+	}
+
+	content := strings.TrimSpace(line[1:end])
+	q := strings.IndexByte(content, '"')
+	if q < 0 {
+		return content, content != ""
+	}
+	name := strings.TrimSpace(content[:q])
+	quoted := content[q:]
+	if name == "" || len(quoted) < 2 || quoted[len(quoted)-1] != '"' {
+		return "", false
+	}
+	var sub strings.Builder
+	for i := 1; i < len(quoted)-1; i++ {
+		c := quoted[i]
+		if c == '\\' && i+1 < len(quoted)-1 {
+			i++
+			c = quoted[i]
+		} else if c == '"' || c == '\\' {
+			return "", false
+		}
+		sub.WriteByte(c)
+	}
+	return INISection(name, sub.String()), true
+}
+
+// parseINIEntry parses the entry line 'content', without its line ending.
+func parseINIEntry(content string) (*lineEntry, error) {
+	trimmed := strings.TrimLeft(content, " \t")
+	e := &lineEntry{prefix: content[:len(content)-len(trimmed)]}
+
+	// the value ends at an inline comment outside of quotes
+	k := strings.IndexByte(trimmed, '=')
+	end := len(trimmed)
+	inQuote := false
+	for i := 0; i < len(trimmed); i++ {
+		c := trimmed[i]
+		switch {
+		case c == '\\' && inQuote:
+			i++
+		case c == '"' && (k < 0 || i > k):
+			inQuote = !inQuote
+		case (c == ';' || c == '#') && !inQuote && i > 0 && (trimmed[i-1] == ' ' || trimmed[i-1] == '\t'):
+			end = i
+			i = len(trimmed)
+		}
+	}
+	for end > 0 && (trimmed[end-1] == ' ' || trimmed[end-1] == '\t') {
+		end--
+	}
+	e.suffix = trimmed[end:]
+	line := trimmed[:end]
+
+	if k < 0 || k >= end {
+		e.rawKey = line
+	} else {
+		e.rawKey = strings.TrimRight(line[:k], " \t")
+		v := k + 1
+		for v < len(line) && (line[v] == ' ' || line[v] == '\t') {
+			v++
+		}
+		e.sep = line[len(e.rawKey):v]
+		e.rawValue = line[v:]
+	}
+	e.key = e.rawKey
+	if e.key == "" {
+		return nil, fmt.Errorf("missing key in %q", content)
+	}
+
+	e.value = e.rawValue
+	if n := len(e.rawValue); n >= 2 && e.rawValue[0] == '"' && e.rawValue[n-1] == '"' && !endsWithEscape(e.rawValue[:n-1]) {
+		value, ok := unquoteINIValue(e.rawValue[1 : n-1])
+		if ok {
+			e.value, e.quote = value, '"'
+		}
+	}
+	return e, nil
+}
+
+// unquoteINIValue decodes the content of a quoted value, and returns false if it contains an
+// unescaped quote.
+func unquoteINIValue(s string) (string, bool) {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"':
+			return "", false
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case '"', '\\':
+				sb.WriteByte(s[i])
+			default:
+				sb.WriteByte('\\')
+				sb.WriteByte(s[i])
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String(), true
+}
+
+func isINIKey(key string) bool {
+	return key != "" && key == strings.TrimSpace(key) && !strings.ContainsAny(key, "=\r\n") &&
+		!strings.ContainsAny(key[:1], "[;#")
+}
+
+// renderINIValue renders 'value' so that it is read back as is, quoted when it is the case of
+// the previous value or when it has surrounding whitespace, quotes, comment characters or
+// control characters. Backslashes are only escaped in quoted values.
+func renderINIValue(value string, quote byte) (string, byte) {
+	if quote == 0 && value == strings.TrimSpace(value) && !strings.ContainsAny(value, "\";#\n\t") {
+		return value, 0
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+	return `"` + r.Replace(value) + `"`, '"'
+}
@@ -0,0 +1,193 @@
+package ordmap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const gitConfig = `# global settings
+[core]
+	bare = false
+	editor = vim ; inline comment
+[remote "origin"]
+	url = git@example.com:me/repo.git
+	fetch = +refs/heads/*:refs/remotes/origin/*
+	fetch = +refs/tags/*:refs/tags/*
+
+; branches
+[branch "main"]
+	remote = origin
+	description = "the \"main\" branch ; not a comment"
+[core]
+	autocrlf
+`
+
+func TestUnmarshalINI(t *testing.T) {
+	var x Any
+	require.NoError(t, UnmarshalINI([]byte(gitConfig), &x))
+	assert.JSONEq(t, `{
+		"core": {"bare": "false", "editor": "vim", "autocrlf": ""},
+		"remote \"origin\"": {"url": "git@example.com:me/repo.git", "fetch": "+refs/tags/*:refs/tags/*"},
+		"branch \"main\"": {"remote": "origin", "description": "the \"main\" branch ; not a comment"}
+	}`, jsonMarshalString(t, x))
+	assert.Equal(t, []string{"core", `remote "origin"`, `branch "main"`}, x.V().(*Map[string, any]).Keys())
+
+	require.NoError(t, UnmarshalINIWith([]byte(gitConfig), &x, INIOptions{MultiValue: true}))
+	origin := x.V().(*Map[string, any]).Get(INISection("remote", "origin")).(*Map[string, any])
+	assert.Equal(t, []string{"url", "fetch"}, origin.Keys())
+	assert.Equal(t, []any{"+refs/heads/*:refs/remotes/origin/*", "+refs/tags/*:refs/tags/*"}, origin.Get("fetch"))
+
+	// typed targets:
+	var sections Map[string, *Map[string, string]]
+	require.NoError(t, UnmarshalINI([]byte(gitConfig), &sections))
+	assert.Equal(t, []string{"bare", "editor", "autocrlf"}, sections.Get("core").Keys())
+
+	var cfg struct {
+		Server struct {
+			Port  int      `json:"port"`
+			Paths []string `json:"path"`
+		} `json:"server"`
+	}
+	input := "name = app\n\n[server]\nport=8080\npath = C:\\www\npath = D:\\www\n"
+	require.NoError(t, UnmarshalINIWith([]byte(input), &cfg, INIOptions{MultiValue: true}))
+	assert.Equal(t, 8080, cfg.Server.Port)
+	assert.Equal(t, []string{`C:\www`, `D:\www`}, cfg.Server.Paths)
+
+	require.NoError(t, UnmarshalINI([]byte(input), &sections))
+	assert.Equal(t, []string{"", "server"}, sections.Keys())
+	assert.Equal(t, "app", sections.Get("").Get("name"))
+
+	for _, input := range []string{
+		"[section\n",
+		"[]\n",
+		"[a] b\n",
+		"[a \"b]\n",
+		"= value\n",
+	} {
+		assert.Error(t, UnmarshalINI([]byte(input), &x), input)
+	}
+}
+
+func TestMarshalINI(t *testing.T) {
+	var x Any
+	require.NoError(t, UnmarshalINIWith([]byte(gitConfig), &x, INIOptions{MultiValue: true}))
+	out, err := MarshalINI(x)
+	require.NoError(t, err)
+	assert.Equal(t, `[core]
+bare = false
+editor = vim
+autocrlf =
+
+[remote "origin"]
+url = git@example.com:me/repo.git
+fetch = +refs/heads/*:refs/remotes/origin/*
+fetch = +refs/tags/*:refs/tags/*
+
+[branch "main"]
+remote = origin
+description = "the \"main\" branch ; not a comment"
+`, string(out))
+
+	var back Any
+	require.NoError(t, UnmarshalINIWith(out, &back, INIOptions{MultiValue: true}))
+	assert.Equal(t, x, back)
+
+	// global keys are written first:
+	m := &Map[string, any]{}
+	section := &Map[string, int]{}
+	section.Set("b", 2)
+	m.Set("s", section)
+	m.Set("g", " padded ")
+	out, err = MarshalINI(m)
+	require.NoError(t, err)
+	assert.Equal(t, "g = \" padded \"\n\n[s]\nb = 2\n", string(out))
+
+	_, err = MarshalINI([]any{1})
+	assert.Error(t, err)
+	m.Set("bad]", section)
+	_, err = MarshalINI(m)
+	assert.Error(t, err)
+}
+
+func TestINIDocument(t *testing.T) {
+	d, err := ParseINIDocument([]byte(gitConfig))
+	require.NoError(t, err)
+	assert.Equal(t, gitConfig, string(d.Bytes()), "unedited documents are kept as is")
+	assert.Equal(t, []string{"core", `remote "origin"`, `branch "main"`}, d.Sections())
+	assert.Equal(t, []string{"bare", "editor", "autocrlf"}, d.Keys("core"))
+	assert.Equal(t, []string{"+refs/heads/*:refs/remotes/origin/*", "+refs/tags/*:refs/tags/*"}, d.GetAll(`remote "origin"`, "fetch"))
+
+	v, ok := d.Get(`branch "main"`, "description")
+	assert.True(t, ok)
+	assert.Equal(t, `the "main" branch ; not a comment`, v)
+
+	require.NoError(t, d.Set("core", "editor", "emacs"))
+	require.NoError(t, d.Set("core", "pager", "less"))
+	require.NoError(t, d.Set(`branch "main"`, "description", "main"))
+	require.NoError(t, d.Set(`remote "origin"`, "pushurl", "x"))
+	require.NoError(t, d.Set(INISection("branch", "dev"), "remote", "origin"))
+	assert.Error(t, d.Set("core", "bad=key", "x"))
+	assert.Error(t, d.Set("bad]", "key", "x"))
+
+	assert.Equal(t, `# global settings
+[core]
+	bare = false
+	editor = emacs ; inline comment
+[remote "origin"]
+	url = git@example.com:me/repo.git
+	fetch = +refs/heads/*:refs/remotes/origin/*
+	fetch = +refs/tags/*:refs/tags/*
+	pushurl = x
+
+; branches
+[branch "main"]
+	remote = origin
+	description = "main"
+[core]
+	autocrlf
+	pager = less
+
+[branch "dev"]
+remote = origin
+`, string(d.Bytes()))
+
+	assert.True(t, d.DeleteSection(`branch "main"`))
+	assert.True(t, d.Delete(`remote "origin"`, "fetch"))
+	assert.False(t, d.Delete(`remote "origin"`, "fetch"))
+	assert.Equal(t, `# global settings
+[core]
+	bare = false
+	editor = emacs ; inline comment
+[remote "origin"]
+	url = git@example.com:me/repo.git
+	pushurl = x
+
+[core]
+	autocrlf
+	pager = less
+
+[branch "dev"]
+remote = origin
+`, string(d.Bytes()))
+
+	m := d.Map()
+	assert.Equal(t, []string{"core", `remote "origin"`, `branch "dev"`}, m.Keys())
+	assert.Equal(t, []string{"bare", "editor", "autocrlf", "pager"}, m.Get("core").Keys())
+
+	// global keys, and a file without final line ending:
+	d, err = ParseINIDocument([]byte("a=1\r\n\r\n[s]"))
+	require.NoError(t, err)
+	require.NoError(t, d.Set("", "b", "2"))
+	require.NoError(t, d.Set("s", "c", "3"))
+	assert.Equal(t, "a=1\r\nb = 2\r\n\r\n[s]\r\nc = 3\r\n", string(d.Bytes()))
+	assert.Equal(t, []string{"", "s"}, d.Sections())
+
+	// deleting the global keys keeps the header comment of the file:
+	d, err = ParseINIDocument([]byte("; top\nroot=1\n; about other\nother=2\n\n[core]\nx=1\n"))
+	require.NoError(t, err)
+	assert.True(t, d.DeleteSection(""))
+	assert.False(t, d.DeleteSection(""))
+	assert.Equal(t, "; top\n; about other\n\n[core]\nx=1\n", string(d.Bytes()))
+}
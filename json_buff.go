@@ -35,6 +35,9 @@ func isValidFirstByte(b byte) bool {
 type jsonBuff struct {
 	p []byte
 	i int
+
+	// useNumber makes `Decode()` decode numbers as json.Number.
+	useNumber bool
 }
 
 func (b *jsonBuff) eatSpace() {
@@ -82,6 +85,9 @@ func (b *jsonBuff) move(x int) error {
 
 func (b *jsonBuff) Decode(v any) error {
 	dec := json.NewDecoder(bytes.NewReader(b.tail()))
+	if b.useNumber {
+		dec.UseNumber()
+	}
 	err := dec.Decode(v)
 	if err != nil {
 		return err
@@ -0,0 +1,234 @@
+package ordmap
+
+import "strings"
+
+// lineDocument is the common part of the line-oriented key/value documents
+// (`PropertiesDocument`, `EnvDocument`).
+//
+// Each entry keeps its original text, and the comment and blank lines which precede it, so
+// that `bytes()` returns the original document when nothing was edited, and only re-renders
+// the edited entries otherwise.
+type lineDocument struct {
+	entries []*lineEntry
+	// trailer holds the comment and blank lines after the last entry
+	trailer []string
+	// eol is the line ending used for new lines, the one of the first line of the source
+	eol string
+	// sep is the separator written between the key and the value of new entries, "=" if empty
+	sep string
+	// placeAfter adds new entries right after the last entry and the comments which follow it,
+	// before the trailing blank lines, with the indentation of the last entry (INI sections).
+	// Otherwise new entries are appended at the end of the document.
+	placeAfter bool
+
+	renderKey func(key string) string
+	// renderValue renders a value, keeping the quote character 'quote' of the previous value
+	// when possible, and returns the quote character it used
+	renderValue func(value string, quote byte) (string, byte)
+}
+
+// lineEntry is an entry of a lineDocument.
+type lineEntry struct {
+	// before holds the comment and blank lines preceding the entry, with their line endings
+	before []string
+	// raw is the text of the entry, possibly spanning several lines, with its line ending
+	raw string
+
+	key   string
+	value string
+
+	// parts of raw needed to render the entry again with another value:
+	// raw == prefix + rawKey + sep + <rendered value> + suffix + eol
+	prefix string
+	rawKey string
+	sep    string
+	suffix string
+	eol    string
+	// rawValue is the value as written, quote is its quote character, 0 if not quoted
+	rawValue string
+	quote    byte
+}
+
+// splitLines splits 's' into lines which keep their line ending.
+func splitLines(s string) []string {
+	var res []string
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			res = append(res, s)
+			break
+		}
+		res = append(res, s[:i+1])
+		s = s[i+1:]
+	}
+	return res
+}
+
+// detectEOL returns the line ending of the first line of 's', "\n" by default.
+func detectEOL(s string) string {
+	i := strings.IndexByte(s, '\n')
+	if i > 0 && s[i-1] == '\r' {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// cutEOL splits 'line' into its content and its line ending.
+func cutEOL(line string) (string, string) {
+	if strings.HasSuffix(line, "\r\n") {
+		return line[:len(line)-2], "\r\n"
+	}
+	if strings.HasSuffix(line, "\n") {
+		return line[:len(line)-1], "\n"
+	}
+	return line, ""
+}
+
+// find returns the index of the last entry with the key 'key', -1 if there is none.
+func (d *lineDocument) find(key string) int {
+	for i := len(d.entries) - 1; i >= 0; i-- {
+		if d.entries[i].key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func (d *lineDocument) get(key string) (string, bool) {
+	i := d.find(key)
+	if i < 0 {
+		return "", false
+	}
+	return d.entries[i].value, true
+}
+
+// set updates the last entry with the key 'key', or adds a new entry at the end of
+// the document (see 'placeAfter').
+func (d *lineDocument) set(key, value string) {
+	if i := d.find(key); i >= 0 {
+		e := d.entries[i]
+		if e.value == value {
+			return
+		}
+		e.value = value
+		e.rawValue, e.quote = d.renderValue(value, e.quote)
+		sep := e.sep
+		if sep == "" {
+			sep = "="
+		}
+		e.raw = e.prefix + e.rawKey + sep + e.rawValue + e.suffix + e.eol
+		return
+	}
+
+	before, after := d.trailer, []string(nil)
+	if d.placeAfter {
+		// the new entry goes after the comments which follow the last entry, but before the
+		// trailing blank lines
+		n := len(d.trailer)
+		for n > 0 && strings.TrimSpace(d.trailer[n-1]) == "" {
+			n--
+		}
+		before, after = d.trailer[:n:n], d.trailer[n:]
+	}
+	if len(after) == 0 {
+		d.ensureEOL()
+	}
+
+	e := &lineEntry{
+		before: before,
+		key:    key,
+		value:  value,
+		rawKey: d.renderKey(key),
+		sep:    d.sep,
+		eol:    d.eol,
+	}
+	if e.sep == "" {
+		e.sep = "="
+	}
+	if n := len(d.entries); n > 0 && d.placeAfter {
+		// indented like the previous entry
+		last := d.entries[n-1].prefix
+		e.prefix = last[:len(last)-len(strings.TrimLeft(last, " \t"))]
+	}
+	e.rawValue, e.quote = d.renderValue(value, 0)
+	e.raw = e.prefix + e.rawKey + e.sep + e.rawValue + e.eol
+	d.entries = append(d.entries, e)
+	d.trailer = after
+}
+
+// ensureEOL adds a line ending to the last line of the document if it has none, and returns
+// false if the document is empty.
+func (d *lineDocument) ensureEOL() bool {
+	if n := len(d.trailer); n > 0 {
+		if !strings.HasSuffix(d.trailer[n-1], "\n") {
+			d.trailer[n-1] += d.eol
+		}
+		return true
+	}
+	if n := len(d.entries); n > 0 {
+		if last := d.entries[n-1]; last.eol == "" {
+			last.eol = d.eol
+			last.raw += d.eol
+		}
+		return true
+	}
+	return false
+}
+
+// delete removes all the entries with the key 'key', along with the comment lines right above
+// them. Blank lines, and comments separated from the entry by a blank line, are kept.
+func (d *lineDocument) delete(key string) bool {
+	found := false
+	var entries []*lineEntry
+	var pending []string
+	for _, e := range d.entries {
+		if e.key != key {
+			e.before = append(pending, e.before...)
+			pending = nil
+			entries = append(entries, e)
+			continue
+		}
+		found = true
+		n := len(e.before)
+		for n > 0 && strings.TrimSpace(e.before[n-1]) != "" {
+			n--
+		}
+		pending = append(pending, e.before[:n]...)
+	}
+	if !found {
+		return false
+	}
+	d.entries = entries
+	d.trailer = append(pending, d.trailer...)
+	return true
+}
+
+// keys returns the keys of the document in the order of their first occurrence.
+func (d *lineDocument) keys() []string {
+	m := d.toMap()
+	return m.Keys()
+}
+
+// toMap returns the entries as a Map. With repeated keys, the last value wins, at the position
+// of the first occurrence.
+func (d *lineDocument) toMap() *Map[string, string] {
+	m := &Map[string, string]{}
+	for _, e := range d.entries {
+		m.Set(e.key, e.value)
+	}
+	return m
+}
+
+func (d *lineDocument) bytes() []byte {
+	var sb strings.Builder
+	for _, e := range d.entries {
+		for _, l := range e.before {
+			sb.WriteString(l)
+		}
+		sb.WriteString(e.raw)
+	}
+	for _, l := range d.trailer {
+		sb.WriteString(l)
+	}
+	return []byte(sb.String())
+}
@@ -0,0 +1,207 @@
+package ordmap
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MsgpackMarshaler is implemented by types which can encode themselves as a single MessagePack object.
+type MsgpackMarshaler interface {
+	MarshalMsgpack() ([]byte, error)
+}
+
+// MsgpackUnmarshaler is implemented by types which can decode themselves from a single MessagePack object.
+type MsgpackUnmarshaler interface {
+	UnmarshalMsgpack(data []byte) error
+}
+
+// MsgpackExt is a MessagePack extension value, other than the timestamp extension type (-1).
+type MsgpackExt struct {
+	Type int8
+	Data []byte
+}
+
+// MsgpackBin is the type of bin keys decoded from a MessagePack map, since []byte values
+// can not be used as keys. It is encoded back as a bin value.
+type MsgpackBin string
+
+// UnmarshalMsgpack decodes the MessagePack object 'data' and stores the result in 'v'.
+//
+// Maps are decoded as `*Map[any, any]` values which keep the order of their entries, and whose
+// keys can be of any type but arrays, maps and extension values. Values are decoded as:
+//   - positive and negative fixint: int; the other integer formats keep their width: int8, int16,
+//     int32, int64, uint8, uint16, uint32 and uint64,
+//   - float 32 and float 64: float32 and float64,
+//   - str: string, bin: []byte (`MsgpackBin` for map keys),
+//   - array: []any, nil: nil, bool: bool,
+//   - the timestamp extension type: time.Time (in UTC), other extension types: `MsgpackExt`.
+//
+// Since `MarshalMsgpack()` writes sized integer types with the format of their width, decoding
+// and encoding back a value gives the same bytes, as long as strings and maps use their
+// shortest format.
+//
+// If 'v' implements `MsgpackUnmarshaler`, its UnmarshalMsgpack method is called with 'data',
+// otherwise the decoded value is stored in 'v' with `Any.DecodeInto()`.
+func UnmarshalMsgpack(data []byte, v any) error {
+	if u, ok := v.(MsgpackUnmarshaler); ok {
+		return u.UnmarshalMsgpack(data)
+	}
+	x, err := parseMsgpack(data)
+	if err != nil {
+		return err
+	}
+	return Any{v: x}.DecodeInto(v)
+}
+
+// MarshalMsgpack returns the MessagePack encoding of 'v'.
+//
+// Maps are written in the order of their entries; structs are written as maps with their fields
+// in order of declaration (see `FromStruct()`), go maps with their entries sorted by their encoded keys.
+// int and uint values use the shortest format which can hold them, the sized integer types
+// (int8 ... int64, uint8 ... uint64) use the format of their width. time.Time values are written
+// with the timestamp extension type.
+//
+// Strings are written as str, and must be valid UTF-8: binary data should be passed as a []byte
+// (or as a `MsgpackBin` for map keys) to be written as bin.
+func MarshalMsgpack(v any) ([]byte, error) {
+	e := &msgpackEncoder{}
+	err := e.encode("", v)
+	if err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// parseMsgpack decodes 'data', which must hold exactly one object.
+func parseMsgpack(data []byte) (any, error) {
+	d := newMsgpackDecoder(bytes.NewReader(data))
+	v, err := d.decode()
+	if err == nil && d.off < int64(len(data)) {
+		err = errors.New("extra trailing data")
+	}
+	if err != nil {
+		return nil, d.wrapErr(err)
+	}
+	return v, nil
+}
+
+func (x *Any) UnmarshalMsgpack(data []byte) error {
+	v, err := parseMsgpack(data)
+	if err != nil {
+		return err
+	}
+	x.v = v
+	return nil
+}
+
+func (x Any) MarshalMsgpack() ([]byte, error) {
+	return MarshalMsgpack(x.v)
+}
+
+// UnmarshalMsgpack replaces the content of 'm' with the decoded map, or clears 'm' if the object is nil.
+// 'm' is left untouched if an error occurs. Use `UnmarshalMerge()` to merge the map into the existing content.
+func (m *Map[K, V]) UnmarshalMsgpack(data []byte) error {
+	return m.unmarshalMsgpack(data, false)
+}
+
+func (t *MergeTarget[K, V]) UnmarshalMsgpack(data []byte) error {
+	return t.m.unmarshalMsgpack(data, true)
+}
+
+func (m *Map[K, V]) unmarshalMsgpack(data []byte, merge bool) error {
+	v, err := parseMsgpack(data)
+	if err != nil {
+		return err
+	}
+	var res Map[K, V]
+	err = Any{v: v}.DecodeInto(&res)
+	if err != nil {
+		return fmt.Errorf("error when decoding map: %w", err)
+	}
+	m.applyDecoded(&res, merge)
+	return nil
+}
+
+// MarshalMsgpack writes 'm' as a MessagePack map, with its entries in insertion order.
+func (m Map[K, V]) MarshalMsgpack() ([]byte, error) {
+	return MarshalMsgpack(&m)
+}
+
+// MsgpackDecoder reads a stream of MessagePack objects, one at a time, the same way
+// json.Decoder reads a stream of JSON values.
+type MsgpackDecoder struct {
+	r *bufio.Reader
+	d *msgpackDecoder
+}
+
+// NewMsgpackDecoder returns a MsgpackDecoder which reads from 'r'. The decoder buffers its
+// input, and may read more data from 'r' than the objects it decodes.
+func NewMsgpackDecoder(r io.Reader) *MsgpackDecoder {
+	br := bufio.NewReader(r)
+	return &MsgpackDecoder{r: br, d: newMsgpackDecoder(br)}
+}
+
+// Next decodes the next object of the stream.
+//
+// Next returns io.EOF when there are no more objects.
+func (dec *MsgpackDecoder) Next() (Any, error) {
+	v, err := dec.next()
+	if err != nil {
+		return Any{}, err
+	}
+	return Any{v: v}, nil
+}
+
+// Decode decodes the next object of the stream into 'v', for example a `*Map[string, any]`.
+//
+// Decode returns io.EOF when there are no more objects.
+func (dec *MsgpackDecoder) Decode(v any) error {
+	u, isUnmarshaler := v.(MsgpackUnmarshaler)
+	if isUnmarshaler {
+		// keep the bytes of the object for the UnmarshalMsgpack method:
+		dec.d.raw = []byte{}
+		defer func() { dec.d.raw = nil }()
+	}
+	x, err := dec.next()
+	if err != nil {
+		return err
+	}
+	if isUnmarshaler {
+		return u.UnmarshalMsgpack(dec.d.raw)
+	}
+	return Any{v: x}.DecodeInto(v)
+}
+
+func (dec *MsgpackDecoder) next() (any, error) {
+	if _, err := dec.r.Peek(1); err == io.EOF {
+		return nil, io.EOF
+	}
+	v, err := dec.d.decode()
+	if err != nil {
+		return nil, dec.d.wrapErr(err)
+	}
+	return v, nil
+}
+
+// MsgpackEncoder writes a stream of MessagePack objects.
+type MsgpackEncoder struct {
+	w io.Writer
+}
+
+// NewMsgpackEncoder returns a MsgpackEncoder which writes to 'w'.
+func NewMsgpackEncoder(w io.Writer) *MsgpackEncoder {
+	return &MsgpackEncoder{w: w}
+}
+
+// Encode writes the MessagePack encoding of 'v' to the stream.
+func (enc *MsgpackEncoder) Encode(v any) error {
+	bs, err := MarshalMsgpack(v)
+	if err != nil {
+		return err
+	}
+	_, err = enc.w.Write(bs)
+	return err
+}
@@ -0,0 +1,325 @@
+package ordmap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+	"unicode/utf8"
+)
+
+// maxMsgpackDepth is the maximum nesting depth of arrays and maps accepted by the decoder.
+const maxMsgpackDepth = 1000
+
+// msgpackTimestamp is the extension type of timestamps.
+const msgpackTimestamp = -1
+
+type msgpackReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+type msgpackDecoder struct {
+	r     msgpackReader
+	off   int64
+	depth int
+	// raw collects the bytes read, when not nil
+	raw []byte
+}
+
+func newMsgpackDecoder(r msgpackReader) *msgpackDecoder {
+	return &msgpackDecoder{r: r}
+}
+
+func (d *msgpackDecoder) wrapErr(err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("error when decoding msgpack at offset %d: %w", d.off, err)
+}
+
+func (d *msgpackDecoder) readByte() (byte, error) {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	d.off++
+	if d.raw != nil {
+		d.raw = append(d.raw, b)
+	}
+	return b, nil
+}
+
+// readBytes
+//
+// reads 'n' bytes; the buffer grows while reading, so that a corrupted length does not
+// allocate more memory than the size of the input.
+func (d *msgpackDecoder) readBytes(n uint64) ([]byte, error) {
+	var buf bytes.Buffer
+	read, err := io.CopyN(&buf, d.r, int64(n))
+	d.off += read
+	if d.raw != nil {
+		d.raw = append(d.raw, buf.Bytes()...)
+	}
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if n == 0 {
+		return []byte{}, nil
+	}
+	return buf.Bytes(), nil
+}
+
+func (d *msgpackDecoder) readUint(size int) (uint64, error) {
+	bs, err := d.readBytes(uint64(size))
+	if err != nil {
+		return 0, err
+	}
+	var n uint64
+	for _, b := range bs {
+		n = n<<8 | uint64(b)
+	}
+	return n, nil
+}
+
+func (d *msgpackDecoder) decode() (any, error) {
+	b, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case b <= 0x7f:
+		return int(b), nil
+	case b >= 0xe0:
+		return int(int8(b)), nil
+	case b&0xf0 == 0x80:
+		return d.nested(func() (any, error) { return d.decodeMap(uint64(b & 0x0f)) })
+	case b&0xf0 == 0x90:
+		return d.nested(func() (any, error) { return d.decodeArray(uint64(b & 0x0f)) })
+	case b&0xe0 == 0xa0:
+		return d.decodeStr(uint64(b & 0x1f))
+	}
+
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.readUint(1 << (b - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		return d.readBytes(n)
+
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.readUint(1 << (b - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeExt(n)
+
+	case 0xca:
+		n, err := d.readUint(4)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float32frombits(uint32(n)), nil
+	case 0xcb:
+		n, err := d.readUint(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(n), nil
+
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := d.readUint(1 << (b - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		switch b {
+		case 0xcc:
+			return uint8(n), nil
+		case 0xcd:
+			return uint16(n), nil
+		case 0xce:
+			return uint32(n), nil
+		}
+		return n, nil
+
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		n, err := d.readUint(1 << (b - 0xd0))
+		if err != nil {
+			return nil, err
+		}
+		switch b {
+		case 0xd0:
+			return int8(n), nil
+		case 0xd1:
+			return int16(n), nil
+		case 0xd2:
+			return int32(n), nil
+		}
+		return int64(n), nil
+
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.decodeExt(1 << (b - 0xd4))
+
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.readUint(1 << (b - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeStr(n)
+
+	case 0xdc, 0xdd:
+		n, err := d.readUint(2 << (b - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.nested(func() (any, error) { return d.decodeArray(n) })
+
+	case 0xde, 0xdf:
+		n, err := d.readUint(2 << (b - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.nested(func() (any, error) { return d.decodeMap(n) })
+	}
+
+	// 0xc1 is never used
+	d.off--
+	return nil, fmt.Errorf("invalid format 0x%02x", b)
+}
+
+func (d *msgpackDecoder) nested(fn func() (any, error)) (any, error) {
+	d.depth++
+	if d.depth > maxMsgpackDepth {
+		return nil, fmt.Errorf("maximum nesting depth of %d exceeded", maxMsgpackDepth)
+	}
+	v, err := fn()
+	d.depth--
+	return v, err
+}
+
+func (d *msgpackDecoder) decodeStr(n uint64) (any, error) {
+	bs, err := d.readBytes(n)
+	if err != nil {
+		return nil, err
+	}
+	if !utf8.Valid(bs) {
+		return nil, errors.New("str is not valid UTF-8")
+	}
+	return string(bs), nil
+}
+
+func (d *msgpackDecoder) decodeArray(n uint64) (any, error) {
+	// the slice grows while reading, see readBytes:
+	res := make([]any, 0, minUint64(n, 1024))
+	for i := uint64(0); i < n; i++ {
+		v, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, v)
+	}
+	return res, nil
+}
+
+func (d *msgpackDecoder) decodeMap(n uint64) (any, error) {
+	res := &Map[any, any]{}
+	for i := uint64(0); i < n; i++ {
+		keyOff := d.off
+		k, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		k, err = msgpackMapKey(k)
+		if err == nil {
+			if _, exists := res.Get2(k); exists {
+				err = fmt.Errorf("duplicate map key %v", k)
+			}
+		}
+		if err != nil {
+			d.off = keyOff
+			return nil, err
+		}
+
+		v, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		res.Set(k, v)
+	}
+	return res, nil
+}
+
+// msgpackMapKey
+//
+// converts a decoded value into a value which can be used as a key of a go map.
+func msgpackMapKey(k any) (any, error) {
+	switch x := k.(type) {
+	case []byte:
+		return MsgpackBin(x), nil
+	case float32:
+		if math.IsNaN(float64(x)) {
+			return nil, errors.New("unsupported map key: NaN")
+		}
+	case float64:
+		if math.IsNaN(x) {
+			return nil, errors.New("unsupported map key: NaN")
+		}
+	case []any, *Map[any, any], MsgpackExt:
+		return nil, fmt.Errorf("unsupported map key of type %T", k)
+	}
+	return k, nil
+}
+
+func (d *msgpackDecoder) decodeExt(n uint64) (any, error) {
+	typ, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+	data, err := d.readBytes(n)
+	if err != nil {
+		return nil, err
+	}
+	if int8(typ) != msgpackTimestamp {
+		return MsgpackExt{Type: int8(typ), Data: data}, nil
+	}
+
+	switch len(data) {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(data)), 0).UTC(), nil
+	case 8:
+		n := binary.BigEndian.Uint64(data)
+		nsec, sec := int64(n>>34), int64(n&(1<<34-1))
+		if nsec < 1e9 {
+			return time.Unix(sec, nsec).UTC(), nil
+		}
+	case 12:
+		nsec := int64(binary.BigEndian.Uint32(data))
+		sec := int64(binary.BigEndian.Uint64(data[4:]))
+		if nsec < 1e9 {
+			return time.Unix(sec, nsec).UTC(), nil
+		}
+	}
+	return nil, fmt.Errorf("invalid timestamp of %d bytes", len(data))
+}
+
+func minUint64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
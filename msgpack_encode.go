@@ -0,0 +1,447 @@
+package ordmap
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+	"unicode/utf8"
+)
+
+type msgpackEncoder struct {
+	buf []byte
+}
+
+func (e *msgpackEncoder) writeUint(format byte, size int, n uint64) {
+	e.buf = append(e.buf, format)
+	for i := size - 1; i >= 0; i-- {
+		e.buf = append(e.buf, byte(n>>(8*i)))
+	}
+}
+
+// writeInt writes 'n' with the shortest format which can hold it.
+func (e *msgpackEncoder) writeInt(n int64) {
+	switch {
+	case n >= 0:
+		e.writeCompactUint(uint64(n))
+	case n >= -32:
+		e.buf = append(e.buf, byte(n))
+	case n >= math.MinInt8:
+		e.writeUint(0xd0, 1, uint64(n))
+	case n >= math.MinInt16:
+		e.writeUint(0xd1, 2, uint64(n))
+	case n >= math.MinInt32:
+		e.writeUint(0xd2, 4, uint64(n))
+	default:
+		e.writeUint(0xd3, 8, uint64(n))
+	}
+}
+
+// writeCompactUint writes 'n' with the shortest format which can hold it.
+func (e *msgpackEncoder) writeCompactUint(n uint64) {
+	switch {
+	case n <= 0x7f:
+		e.buf = append(e.buf, byte(n))
+	case n <= math.MaxUint8:
+		e.writeUint(0xcc, 1, n)
+	case n <= math.MaxUint16:
+		e.writeUint(0xcd, 2, n)
+	case n <= math.MaxUint32:
+		e.writeUint(0xce, 4, n)
+	default:
+		e.writeUint(0xcf, 8, n)
+	}
+}
+
+// writeLength
+//
+// writes the header of a str, bin, array or map of length 'n'. 'fix' is the format of the
+// fix variant (0 if there is none), 'formats' the formats with a length on 1, 2 and 4 bytes.
+func (e *msgpackEncoder) writeLength(where string, n int, fix byte, fixMax int, formats [3]byte) error {
+	switch {
+	case fix != 0 && n <= fixMax:
+		e.buf = append(e.buf, fix|byte(n))
+	case formats[0] != 0 && n <= math.MaxUint8:
+		e.writeUint(formats[0], 1, uint64(n))
+	case n <= math.MaxUint16:
+		e.writeUint(formats[1], 2, uint64(n))
+	case uint64(n) <= math.MaxUint32:
+		e.writeUint(formats[2], 4, uint64(n))
+	default:
+		return fmt.Errorf("error when encoding msgpack %s: length %d is too large", where, n)
+	}
+	return nil
+}
+
+func (e *msgpackEncoder) writeStr(where string, s string) error {
+	if !utf8.ValidString(s) {
+		return fmt.Errorf("error when encoding msgpack %s: string is not valid UTF-8, use a []byte to write a bin", where)
+	}
+	err := e.writeLength(where, len(s), 0xa0, 31, [3]byte{0xd9, 0xda, 0xdb})
+	if err != nil {
+		return err
+	}
+	e.buf = append(e.buf, s...)
+	return nil
+}
+
+func (e *msgpackEncoder) writeBin(where string, bs []byte) error {
+	err := e.writeLength(where, len(bs), 0, 0, [3]byte{0xc4, 0xc5, 0xc6})
+	if err != nil {
+		return err
+	}
+	e.buf = append(e.buf, bs...)
+	return nil
+}
+
+func (e *msgpackEncoder) writeExt(where string, typ int8, data []byte) error {
+	switch len(data) {
+	case 1:
+		e.buf = append(e.buf, 0xd4)
+	case 2:
+		e.buf = append(e.buf, 0xd5)
+	case 4:
+		e.buf = append(e.buf, 0xd6)
+	case 8:
+		e.buf = append(e.buf, 0xd7)
+	case 16:
+		e.buf = append(e.buf, 0xd8)
+	default:
+		err := e.writeLength(where, len(data), 0, 0, [3]byte{0xc7, 0xc8, 0xc9})
+		if err != nil {
+			return err
+		}
+	}
+	e.buf = append(e.buf, byte(typ))
+	e.buf = append(e.buf, data...)
+	return nil
+}
+
+// writeTimestamp writes 't' with the smallest of the 32, 64 and 96 bits timestamp formats.
+func (e *msgpackEncoder) writeTimestamp(where string, t time.Time) error {
+	sec, nsec := t.Unix(), uint64(t.Nanosecond())
+	switch {
+	case sec >= 0 && sec <= math.MaxUint32 && nsec == 0:
+		data := make([]byte, 4)
+		binary.BigEndian.PutUint32(data, uint32(sec))
+		return e.writeExt(where, msgpackTimestamp, data)
+	case sec >= 0 && sec < 1<<34:
+		data := make([]byte, 8)
+		binary.BigEndian.PutUint64(data, nsec<<34|uint64(sec))
+		return e.writeExt(where, msgpackTimestamp, data)
+	}
+	data := make([]byte, 12)
+	binary.BigEndian.PutUint32(data, uint32(nsec))
+	binary.BigEndian.PutUint64(data[4:], uint64(sec))
+	return e.writeExt(where, msgpackTimestamp, data)
+}
+
+func (e *msgpackEncoder) encode(where string, v any) error {
+	for {
+		switch x := v.(type) {
+		case nil:
+			e.buf = append(e.buf, 0xc0)
+			return nil
+
+		case Any:
+			v = x.v
+			continue
+		case *Any:
+			if x == nil {
+				v = nil
+				continue
+			}
+			v = x.v
+			continue
+		case *YAMLAnchor:
+			v = x.Value
+			continue
+		case *YAMLScalar:
+			v = x.Value
+			continue
+
+		case bool:
+			if x {
+				e.buf = append(e.buf, 0xc3)
+			} else {
+				e.buf = append(e.buf, 0xc2)
+			}
+			return nil
+		case int:
+			e.writeInt(int64(x))
+			return nil
+		case uint:
+			e.writeCompactUint(uint64(x))
+			return nil
+		case int8:
+			e.writeUint(0xd0, 1, uint64(x))
+			return nil
+		case int16:
+			e.writeUint(0xd1, 2, uint64(x))
+			return nil
+		case int32:
+			e.writeUint(0xd2, 4, uint64(x))
+			return nil
+		case int64:
+			e.writeUint(0xd3, 8, uint64(x))
+			return nil
+		case uint8:
+			e.writeUint(0xcc, 1, uint64(x))
+			return nil
+		case uint16:
+			e.writeUint(0xcd, 2, uint64(x))
+			return nil
+		case uint32:
+			e.writeUint(0xce, 4, uint64(x))
+			return nil
+		case uint64:
+			e.writeUint(0xcf, 8, x)
+			return nil
+		case float32:
+			e.writeUint(0xca, 4, uint64(math.Float32bits(x)))
+			return nil
+		case float64:
+			e.writeUint(0xcb, 8, math.Float64bits(x))
+			return nil
+		case json.Number:
+			if i, err := x.Int64(); err == nil {
+				e.writeInt(i)
+				return nil
+			}
+			f, err := x.Float64()
+			if err != nil {
+				return fmt.Errorf("error when encoding msgpack %s: %w", where, err)
+			}
+			v = f
+			continue
+
+		case string:
+			return e.writeStr(where, x)
+		case []byte:
+			return e.writeBin(where, x)
+		case MsgpackBin:
+			return e.writeBin(where, []byte(x))
+		case MsgpackExt:
+			return e.writeExt(where, x.Type, x.Data)
+		case time.Time:
+			return e.writeTimestamp(where, x)
+
+		case []any:
+			err := e.writeLength(where, len(x), 0x90, 15, [3]byte{0, 0xdc, 0xdd})
+			if err != nil {
+				return err
+			}
+			for i, elt := range x {
+				err := e.encode(fmt.Sprintf("%s[%d]", where, i), elt)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		case anyMap:
+			if reflect.ValueOf(x).IsNil() {
+				v = nil
+				continue
+			}
+			return e.encodeMap(where, x, false)
+		}
+
+		rv := reflect.ValueOf(v)
+		// Map values are encoded as maps:
+		if rv.Kind() != reflect.Pointer && reflect.PointerTo(rv.Type()).Implements(anyMapType) {
+			p := reflect.New(rv.Type())
+			p.Elem().Set(rv)
+			v = p.Interface()
+			continue
+		}
+
+		switch x := v.(type) {
+		case MsgpackMarshaler:
+			if rv.Kind() == reflect.Pointer && rv.IsNil() {
+				v = nil
+				continue
+			}
+			bs, err := x.MarshalMsgpack()
+			if err != nil {
+				return fmt.Errorf("error when encoding msgpack %s: %w", where, err)
+			}
+			if _, err := parseMsgpack(bs); err != nil {
+				return fmt.Errorf("error when encoding msgpack %s: invalid output of MarshalMsgpack: %w", where, err)
+			}
+			e.buf = append(e.buf, bs...)
+			return nil
+		case json.Marshaler:
+			if rv.Kind() == reflect.Pointer && rv.IsNil() {
+				v = nil
+				continue
+			}
+			bs, err := x.MarshalJSON()
+			if err != nil {
+				return fmt.Errorf("error when encoding msgpack %s: %w", where, err)
+			}
+			var res Any
+			err = json.Unmarshal(bs, &res)
+			if err != nil {
+				return fmt.Errorf("error when encoding msgpack %s: %w", where, err)
+			}
+			v = res.v
+			continue
+		case encoding.TextMarshaler:
+			if rv.Kind() == reflect.Pointer && rv.IsNil() {
+				v = nil
+				continue
+			}
+			bs, err := x.MarshalText()
+			if err != nil {
+				return fmt.Errorf("error when encoding msgpack %s: %w", where, err)
+			}
+			v = string(bs)
+			continue
+		}
+
+		// other go values: named scalar types, structs, go maps and slices
+		switch rv.Kind() {
+		case reflect.String:
+			v = rv.String()
+			continue
+		case reflect.Bool:
+			v = rv.Bool()
+			continue
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+			reflect.Float32, reflect.Float64:
+			// numbers use the format of the unnamed type of the same width
+			v = rv.Convert(msgpackBasicTypes[rv.Kind()]).Interface()
+			continue
+
+		case reflect.Pointer, reflect.Interface:
+			if rv.IsNil() {
+				v = nil
+				continue
+			}
+			v = rv.Elem().Interface()
+			continue
+
+		case reflect.Struct:
+			res := &Map[string, any]{}
+			for _, f := range cachedStructFields(rv.Type()).list {
+				fv, ok := fieldByIndex(rv, f.index)
+				if !ok || (f.omitEmpty && isEmptyValue(fv)) {
+					continue
+				}
+				res.Set(f.name, fv.Interface())
+			}
+			return e.encodeMap(where, res, false)
+
+		case reflect.Map:
+			if rv.IsNil() {
+				v = nil
+				continue
+			}
+			// go maps have no order, their entries are sorted:
+			res := &Map[any, any]{}
+			iter := rv.MapRange()
+			for iter.Next() {
+				res.Set(iter.Key().Interface(), iter.Value().Interface())
+			}
+			return e.encodeMap(where, res, true)
+
+		case reflect.Slice, reflect.Array:
+			if rv.Kind() == reflect.Slice && rv.IsNil() {
+				v = nil
+				continue
+			}
+			if rv.Type().Elem().Kind() == reflect.Uint8 {
+				bs := make([]byte, rv.Len())
+				reflect.Copy(reflect.ValueOf(bs), rv)
+				v = bs
+				continue
+			}
+			err := e.writeLength(where, rv.Len(), 0x90, 15, [3]byte{0, 0xdc, 0xdd})
+			if err != nil {
+				return err
+			}
+			for i := 0; i < rv.Len(); i++ {
+				err := e.encode(fmt.Sprintf("%s[%d]", where, i), rv.Index(i).Interface())
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		return fmt.Errorf("error when encoding msgpack %s: unsupported type %T", where, v)
+	}
+}
+
+// msgpackBasicTypes maps the kinds of numbers to their unnamed go type.
+var msgpackBasicTypes = map[reflect.Kind]reflect.Type{
+	reflect.Int:     reflect.TypeOf(int(0)),
+	reflect.Int8:    reflect.TypeOf(int8(0)),
+	reflect.Int16:   reflect.TypeOf(int16(0)),
+	reflect.Int32:   reflect.TypeOf(int32(0)),
+	reflect.Int64:   reflect.TypeOf(int64(0)),
+	reflect.Uint:    reflect.TypeOf(uint(0)),
+	reflect.Uint8:   reflect.TypeOf(uint8(0)),
+	reflect.Uint16:  reflect.TypeOf(uint16(0)),
+	reflect.Uint32:  reflect.TypeOf(uint32(0)),
+	reflect.Uint64:  reflect.TypeOf(uint64(0)),
+	reflect.Uintptr: reflect.TypeOf(uint64(0)),
+	reflect.Float32: reflect.TypeOf(float32(0)),
+	reflect.Float64: reflect.TypeOf(float64(0)),
+}
+
+// encodeMap
+//
+// writes the entries of 'm' in insertion order, or sorted by their encoded keys if 'sorted' is true.
+func (e *msgpackEncoder) encodeMap(where string, m anyMap, sorted bool) error {
+	err := e.writeLength(where, m.Len(), 0x80, 15, [3]byte{0, 0xde, 0xdf})
+	if err != nil {
+		return err
+	}
+	if !sorted {
+		return m.eachAny(func(k, v any) error {
+			err := e.encode(fmt.Sprintf("%s (key %v)", where, k), k)
+			if err != nil {
+				return err
+			}
+			return e.encode(fmt.Sprintf("%s.%v", where, k), v)
+		})
+	}
+
+	type entry struct {
+		key, value []byte
+	}
+	entries := make([]entry, 0, m.Len())
+	err = m.eachAny(func(k, v any) error {
+		sub := &msgpackEncoder{}
+		err := sub.encode(fmt.Sprintf("%s (key %v)", where, k), k)
+		if err != nil {
+			return err
+		}
+		keyLen := len(sub.buf)
+		err = sub.encode(fmt.Sprintf("%s.%v", where, k), v)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry{key: sub.buf[:keyLen], value: sub.buf[keyLen:]})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].key, entries[j].key) < 0
+	})
+	for _, entry := range entries {
+		e.buf = append(e.buf, entry.key...)
+		e.buf = append(e.buf, entry.value...)
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package ordmap
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMsgpack_Values(t *testing.T) {
+	table := []struct {
+		hex   string
+		value any
+	}{
+		{"c0", nil},
+		{"c2", false},
+		{"c3", true},
+		{"00", 0},
+		{"7f", 127},
+		{"ff", -1},
+		{"e0", -32},
+		{"cc80", uint8(128)},
+		{"cc01", uint8(1)},
+		{"cd0100", uint16(256)},
+		{"ce00010000", uint32(65536)},
+		{"cfffffffffffffffff", uint64(math.MaxUint64)},
+		{"d0df", int8(-33)},
+		{"d001", int8(1)},
+		{"d1ff00", int16(-256)},
+		{"d2ffff0000", int32(-65536)},
+		{"d3000000000000002a", int64(42)},
+		{"ca3fc00000", float32(1.5)},
+		{"cb3ff199999999999a", 1.1},
+		{"a0", ""},
+		{"a3616263", "abc"},
+		{"d920" + strings.Repeat("61", 32), strings.Repeat("a", 32)},
+		{"c400", []byte{}},
+		{"c4020102", []byte{1, 2}},
+		{"90", []any{}},
+		{"9201a161", []any{1, "a"}},
+		{"d40105", MsgpackExt{Type: 1, Data: []byte{5}}},
+		{"c703020a0b0c", MsgpackExt{Type: 2, Data: []byte{10, 11, 12}}},
+		{"d6ff514b67b0", time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC)},
+		{"d7ff77359400514b67b0", time.Date(2013, 3, 21, 20, 4, 0, 500000000, time.UTC)},
+		{"c70cff00000000ffffffffffffffff", time.Date(1969, 12, 31, 23, 59, 59, 0, time.UTC)},
+	}
+
+	for _, tc := range table {
+		var x Any
+		err := UnmarshalMsgpack(mustHex(t, tc.hex), &x)
+		require.NoError(t, err, tc.hex)
+		assert.Equal(t, tc.value, x.V(), tc.hex)
+
+		// integer widths are kept when encoding back:
+		out, err := MarshalMsgpack(x)
+		require.NoError(t, err, tc.hex)
+		assert.Equal(t, tc.hex, hex.EncodeToString(out))
+	}
+
+	// int and uint use the shortest format:
+	type Count int
+	for v, expected := range map[any]string{
+		int(200):       "ccc8",
+		int(-200):      "d1ff38",
+		int(1 << 40):   "cf0000010000000000",
+		uint(5):        "05",
+		Count(-5):      "fb",
+		int64(1):       "d30000000000000001",
+		float64(1):     "cb3ff0000000000000",
+		MsgpackBin(""): "c400",
+	} {
+		out, err := MarshalMsgpack(v)
+		require.NoError(t, err)
+		assert.Equal(t, expected, hex.EncodeToString(out), "%T %v", v, v)
+	}
+}
+
+func TestMsgpack_Maps(t *testing.T) {
+	// {"b": 1, 2: [true], bin(01): nil}
+	input := "83" + "a16201" + "0291c3" + "c40101c0"
+	var x Any
+	err := UnmarshalMsgpack(mustHex(t, input), &x)
+	require.NoError(t, err)
+
+	m := x.V().(*Map[any, any])
+	assert.Equal(t, []any{"b", 2, MsgpackBin("\x01")}, m.Keys())
+	assert.Equal(t, []any{true}, m.Get(2))
+
+	out, err := MarshalMsgpack(x)
+	require.NoError(t, err)
+	assert.Equal(t, input, hex.EncodeToString(out))
+
+	// decoding into a Map keeps the order:
+	var sm Map[string, int]
+	err = UnmarshalMsgpack(mustHex(t, "82a17a01a16102"), &sm)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"z", "a"}, sm.Keys())
+
+	out, err = MarshalMsgpack(sm)
+	require.NoError(t, err)
+	assert.Equal(t, "82a17a01a16102", hex.EncodeToString(out))
+
+	err = UnmarshalMsgpack(mustHex(t, "82a16103a16204"), UnmarshalMerge(&sm))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"z", "a", "b"}, sm.Keys())
+	assert.Equal(t, 3, sm.Get("a"))
+
+	err = sm.UnmarshalMsgpack(mustHex(t, "81a161a178"))
+	assert.Error(t, err)
+	assert.Equal(t, 3, sm.Len(), "map is left untouched on error")
+
+	require.NoError(t, sm.UnmarshalMsgpack(mustHex(t, "c0")))
+	assert.Equal(t, 0, sm.Len())
+
+	// go maps have their entries sorted:
+	out, err = MarshalMsgpack(map[string]int{"b": 2, "a": 1})
+	require.NoError(t, err)
+	assert.Equal(t, "82a16101a16202", hex.EncodeToString(out))
+}
+
+func TestMsgpack_Struct(t *testing.T) {
+	type Item struct {
+		ID    uint32            `json:"id"`
+		Name  string            `json:"name"`
+		Attrs Map[string, any]  `json:"attrs"`
+		Raw   []byte            `json:"raw,omitempty"`
+		When  time.Time         `json:"when"`
+		Extra map[string]string `json:"-"`
+	}
+
+	attrs := Map[string, any]{}
+	attrs.Set("size", 3)
+	attrs.Set("color", "red")
+	item := Item{ID: 7, Name: "x", Attrs: attrs, When: time.Date(2024, 5, 6, 7, 8, 9, 10, time.UTC)}
+
+	out, err := MarshalMsgpack(item)
+	require.NoError(t, err)
+
+	var x Any
+	require.NoError(t, UnmarshalMsgpack(out, &x))
+	m := x.V().(*Map[any, any])
+	assert.Equal(t, []any{"id", "name", "attrs", "when"}, m.Keys())
+	assert.Equal(t, uint32(7), m.Get("id"))
+	assert.Equal(t, []any{"size", "color"}, m.Get("attrs").(*Map[any, any]).Keys())
+
+	var back Item
+	require.NoError(t, UnmarshalMsgpack(out, &back))
+	assert.Equal(t, item, back)
+}
+
+func TestMsgpackDecoder(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewMsgpackEncoder(&buf)
+	first := &Map[string, any]{}
+	first.Set("z", 1)
+	first.Set("a", []any{"x"})
+	require.NoError(t, enc.Encode(first))
+	require.NoError(t, enc.Encode("second"))
+	require.NoError(t, enc.Encode(first))
+
+	dec := NewMsgpackDecoder(&buf)
+
+	var m Map[string, any]
+	require.NoError(t, dec.Decode(&m))
+	assert.Equal(t, []string{"z", "a"}, m.Keys())
+
+	x, err := dec.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "second", x.V())
+
+	var s struct {
+		Z int `json:"z"`
+	}
+	require.NoError(t, dec.Decode(&s))
+	assert.Equal(t, 1, s.Z)
+
+	_, err = dec.Next()
+	assert.Equal(t, io.EOF, err)
+
+	// a truncated object is an error, not the end of the stream:
+	dec = NewMsgpackDecoder(bytes.NewReader(mustHex(t, "01"+"92a1")))
+	_, err = dec.Next()
+	require.NoError(t, err)
+	_, err = dec.Next()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestMsgpack_Errors(t *testing.T) {
+	table := []string{
+		"",
+		"c1",
+		"cd01",
+		"a2",
+		"a1ff",
+		"92c0",
+		"81c0",
+		"82a161c0a161c0",
+		"8191c0c0",
+		"d6ff",
+		"c703ff010203",
+		"d7ffffffffff00000000",
+		"0000",
+		"dbffffffff",
+	}
+	for _, input := range table {
+		var x Any
+		err := UnmarshalMsgpack(mustHex(t, input), &x)
+		assert.Error(t, err, "input: %s", input)
+	}
+
+	deep := bytes.Repeat([]byte{0x91}, maxMsgpackDepth+1)
+	var x Any
+	err := UnmarshalMsgpack(append(deep, 0x00), &x)
+	assert.ErrorContains(t, err, "nesting depth")
+
+	_, err = MarshalMsgpack(make(chan int))
+	assert.Error(t, err)
+
+	// strings which are not valid UTF-8 can not be decoded back, and are refused:
+	_, err = MarshalMsgpack("a\xffb")
+	assert.ErrorContains(t, err, "UTF-8")
+	m := &Map[string, any]{}
+	m.Set("a\xff", 1)
+	_, err = MarshalMsgpack(m)
+	assert.ErrorContains(t, err, "UTF-8")
+
+	// they round trip as bin values:
+	out, err := MarshalMsgpack([]any{[]byte("a\xffb"), MsgpackBin("\xfe")})
+	require.NoError(t, err)
+	err = UnmarshalMsgpack(out, &x)
+	require.NoError(t, err)
+	assert.Equal(t, []any{[]byte("a\xffb"), []byte("\xfe")}, x.V())
+}
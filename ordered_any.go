@@ -12,3 +12,14 @@ type Any struct {
 func (x *Any) V() any {
 	return x.v
 }
+
+// NewAny wraps 'v' in an Any.
+//
+// 'v' is expected to be a value which could have been produced by unmarshalling
+// into an Any: nested objects should be `*Map` values.
+func NewAny(v any) Any {
+	if x, ok := v.(Any); ok {
+		return x
+	}
+	return Any{v: v}
+}
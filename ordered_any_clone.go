@@ -0,0 +1,122 @@
+package ordmap
+
+import "reflect"
+
+// DeepClone returns a copy of 'x' which shares no object or array with 'x'.
+//
+// See the package level `DeepClone()` function.
+func (x Any) DeepClone() Any {
+	return Any{v: DeepClone(x.v)}
+}
+
+// DeepClone returns a deep copy of 'v': `*Map` values (of any type), `Any`, `*Any`,
+// `[]any` and `map[string]any` values are copied recursively, other values are returned as is.
+//
+// If 'v' contains several references to the same map or slice (including references from
+// a map to itself), the copy contains the same references to the copied map or slice.
+func DeepClone(v any) any {
+	c := &cloner{seen: make(map[any]any)}
+	return c.clone(v)
+}
+
+// deepCloner is implemented by *Map[K, V], so that the cloner can copy a Map
+// without knowing its type parameters.
+type deepCloner interface {
+	deepClone(c *cloner) any
+}
+
+// cloner
+//
+// keeps track of the maps and slices which have already been copied, to reuse
+// their copy when they are referenced several times.
+type cloner struct {
+	seen map[any]any
+}
+
+type sliceIdentity struct {
+	ptr uintptr
+	len int
+}
+
+type mapIdentity struct {
+	ptr uintptr
+}
+
+func (c *cloner) clone(v any) any {
+	switch x := v.(type) {
+	case Any:
+		return Any{v: c.clone(x.v)}
+
+	case *Any:
+		if x == nil {
+			return x
+		}
+		if res, ok := c.seen[x]; ok {
+			return res
+		}
+		res := &Any{}
+		c.seen[x] = res
+		res.v = c.clone(x.v)
+		return res
+
+	case deepCloner:
+		if reflect.ValueOf(x).IsNil() {
+			return v
+		}
+		if res, ok := c.seen[x]; ok {
+			return res
+		}
+		return x.deepClone(c)
+
+	case []any:
+		if x == nil {
+			return x
+		}
+		id := sliceIdentity{ptr: reflect.ValueOf(x).Pointer(), len: len(x)}
+		if res, ok := c.seen[id]; ok {
+			return res
+		}
+		res := make([]any, len(x))
+		c.seen[id] = res
+		for i := range x {
+			res[i] = c.clone(x[i])
+		}
+		return res
+
+	case map[string]any:
+		if x == nil {
+			return x
+		}
+		id := mapIdentity{ptr: reflect.ValueOf(x).Pointer()}
+		if res, ok := c.seen[id]; ok {
+			return res
+		}
+		res := make(map[string]any, len(x))
+		c.seen[id] = res
+		for k, elt := range x {
+			res[k] = c.clone(elt)
+		}
+		return res
+	}
+	return v
+}
+
+func (m *Map[K, V]) deepClone(c *cloner) any {
+	res := &Map[K, V]{}
+	c.seen[m] = res
+	if len(m.m) == 0 {
+		return res
+	}
+
+	res.m = make(map[K]V, len(m.m))
+	res.keys = make([]K, len(m.keys))
+	copy(res.keys, m.keys)
+	for _, k := range m.keys {
+		var v V
+		if cloned := c.clone(m.m[k]); cloned != nil {
+			v = cloned.(V)
+		}
+		res.m[k] = v
+	}
+	return res
+}
@@ -0,0 +1,90 @@
+package ordmap
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestAnyDeepClone(t *testing.T) {
+	x := mustAny(t, `{"z":{"y":[1,{"x":true}]},"w":"s"}`)
+
+	c := x.DeepClone()
+	assert.Equal(t, jsonMarshalString(t, x), jsonMarshalString(t, c))
+
+	// editing the clone does not modify the original:
+	root := c.V().(*Map[string, any])
+	z := root.Get("z").(*Map[string, any])
+	z.Set("new", 1)
+	arr := z.Get("y").([]any)
+	arr[1].(*Map[string, any]).Delete("x")
+	arr[0] = 2.0
+
+	assert.Equal(t, `{"z":{"y":[1,{"x":true}]},"w":"s"}`, jsonMarshalString(t, x))
+	assert.Equal(t, `{"z":{"y":[2,{}],"new":1},"w":"s"}`, jsonMarshalString(t, c))
+
+	var y Any
+	err := yaml.Unmarshal([]byte("a:\n  1: [x]\n"), &y)
+	require.NoError(t, err)
+	yc := y.DeepClone()
+	yc.V().(*Map[any, any]).Get("a").(*Map[any, any]).Set(2, nil)
+	assert.Equal(t, 1, y.V().(*Map[any, any]).Get("a").(*Map[any, any]).Len())
+}
+
+func TestDeepClone_SharedAndCycles(t *testing.T) {
+	shared := &Map[string, any]{}
+	shared.Set("k", "v")
+
+	root := &Map[string, any]{}
+	root.Set("a", shared)
+	root.Set("b", shared)
+	root.Set("self", root)
+
+	list := []any{1, nil}
+	list[1] = list
+	root.Set("list", list)
+
+	c := DeepClone(root).(*Map[string, any])
+	require.NotSame(t, root, c)
+
+	// the clone has the same structure as the original:
+	ca := c.Get("a").(*Map[string, any])
+	assert.NotSame(t, shared, ca)
+	assert.Same(t, ca, c.Get("b"))
+	assert.Same(t, c, c.Get("self"))
+
+	cl := c.Get("list").([]any)
+	assert.Equal(t, 1, cl[0])
+	assert.Same(t, &cl[0], &cl[1].([]any)[0])
+	assert.NotSame(t, &list[0], &cl[0])
+}
+
+func TestDeepClone_TypedMaps(t *testing.T) {
+	type Entry struct{ Name string }
+
+	inner := &Map[string, []any]{}
+	inner.Set("x", []any{1, 2})
+
+	m := &Map[int, *Map[string, []any]]{}
+	m.Set(2, inner)
+	m.Set(1, nil)
+
+	c := DeepClone(m).(*Map[int, *Map[string, []any]])
+	assert.Equal(t, []int{2, 1}, c.Keys())
+	assert.Nil(t, c.Get(1))
+	c.Get(2).Get("x")[0] = "changed"
+	assert.Equal(t, 1, inner.Get("x")[0])
+
+	// values of other types are shared:
+	e := &Entry{"e"}
+	em := &Map[string, *Entry]{}
+	em.Set("e", e)
+	assert.Same(t, e, DeepClone(em).(*Map[string, *Entry]).Get("e"))
+
+	bs, err := json.Marshal(DeepClone(Any{}))
+	require.NoError(t, err)
+	assert.Equal(t, "null", string(bs))
+}
@@ -8,7 +8,7 @@ import (
 	"github.com/LeGEC/ordmap"
 )
 
-func ExampleStandard() {
+func Example_standard() {
 	input := `{
 		"last_name": "Doe",
 		"first_name": "John",
@@ -15,7 +15,14 @@ func (x Any) MarshalJSON() ([]byte, error) {
 }
 
 func (x *Any) UnmarshalJSON(p []byte) error {
-	buff := jsonBuff{p: p}
+	return x.unmarshalJSON(p, false)
+}
+
+// unmarshalJSON
+//
+// decodes 'p' into 'x'. If 'useNumber' is set, numbers are decoded as json.Number.
+func (x *Any) unmarshalJSON(p []byte, useNumber bool) error {
+	buff := jsonBuff{p: p, useNumber: useNumber}
 
 	x.v = nil
 
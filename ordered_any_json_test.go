@@ -207,6 +207,15 @@ func jsonCompact(input string) string {
 	return string(bs)
 }
 
+// jsonMarshalString returns the JSON encoding of 'v', keeping the order of Map keys
+// (where `jsonCompact()` sorts them).
+func jsonMarshalString(t *testing.T, v any) string {
+	t.Helper()
+	bs, err := json.Marshal(v)
+	require.NoError(t, err)
+	return string(bs)
+}
+
 func FuzzAnyJsonUnmarshal(f *testing.F) {
 	f.Add(`null`)
 	f.Add(`2`)
@@ -0,0 +1,569 @@
+package ordmap
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// PatchOperation is a single operation of a JSON Patch document, as described
+// in RFC 6902.
+//
+// Value is only used by the "add", "replace" and "test" operations, From is only
+// used by "move" and "copy".
+type PatchOperation struct {
+	Op    string
+	Path  string
+	From  string
+	Value any
+}
+
+// Patch is a JSON Patch document (RFC 6902): a list of operations, applied in sequence.
+//
+// When applied on an `Any` document, the operations are aware of the order of the keys:
+//   - "add" on a new key of an object inserts it at the end of the object,
+//   - "add" or "replace" on an existing key keeps the key at its position,
+//   - "move" removes the value at 'from' and inserts it as a new key at 'path',
+//     the order of the other keys is left untouched.
+//
+// As in RFC 6902, a "move" operation where 'from' and 'path' are equal is a no-op.
+type Patch []PatchOperation
+
+func (op PatchOperation) MarshalJSON() ([]byte, error) {
+	var m Map[string, any]
+	m.Set("op", op.Op)
+	switch op.Op {
+	case "move", "copy":
+		m.Set("from", op.From)
+	}
+	m.Set("path", op.Path)
+	switch op.Op {
+	case "add", "replace", "test":
+		m.Set("value", op.Value)
+	}
+	return m.MarshalJSON()
+}
+
+func (op *PatchOperation) UnmarshalJSON(p []byte) error {
+	var m Map[string, Any]
+	err := m.UnmarshalJSON(p)
+	if err != nil {
+		return fmt.Errorf("error when decoding patch operation: %w", err)
+	}
+
+	*op = PatchOperation{}
+
+	strField := func(name string, dst *string) error {
+		v, ok := m.Get2(name)
+		if !ok {
+			return nil
+		}
+		s, ok := v.v.(string)
+		if !ok {
+			return fmt.Errorf("error when decoding patch operation: field %q should be a string, got %T", name, v.v)
+		}
+		*dst = s
+		return nil
+	}
+
+	for _, f := range []struct {
+		name string
+		dst  *string
+	}{{"op", &op.Op}, {"path", &op.Path}, {"from", &op.From}} {
+		if err := strField(f.name, f.dst); err != nil {
+			return err
+		}
+	}
+
+	if _, ok := m.Get2("op"); !ok {
+		return errors.New("error when decoding patch operation: missing \"op\" field")
+	}
+	if _, ok := m.Get2("path"); !ok {
+		return errors.New("error when decoding patch operation: missing \"path\" field")
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		v, ok := m.Get2("value")
+		if !ok {
+			return fmt.Errorf("error when decoding patch operation: missing \"value\" field for %q", op.Op)
+		}
+		op.Value = v.v
+	case "move", "copy":
+		if _, ok := m.Get2("from"); !ok {
+			return fmt.Errorf("error when decoding patch operation: missing \"from\" field for %q", op.Op)
+		}
+	}
+	return nil
+}
+
+// ApplyPatch applies the operations of 'patch' on a copy of 'doc', and returns the result.
+//
+// 'doc' is left untouched. If any operation fails (including a failed "test"), an error
+// is returned and no partial result is returned.
+//
+// The document may contain objects (`*Map[string, any]`), arrays (`[]any`) and scalar values,
+// as produced by `json.Unmarshal()` into an `Any`.
+func ApplyPatch(doc Any, patch Patch) (Any, error) {
+	root := DeepClone(doc.v)
+
+	for i, op := range patch {
+		var err error
+		root, err = applyPatchOp(root, op)
+		if err != nil {
+			return Any{}, fmt.Errorf("error when applying patch operation %d (%s %q): %w", i, op.Op, op.Path, err)
+		}
+	}
+
+	return Any{v: root}, nil
+}
+
+func applyPatchOp(root any, op PatchOperation) (any, error) {
+	path, err := parseJSONPointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	value := op.Value
+	if a, ok := value.(Any); ok {
+		value = a.v
+	}
+
+	switch op.Op {
+	case "add":
+		return patchAdd(root, path, DeepClone(value))
+
+	case "remove":
+		if len(path) == 0 {
+			return nil, errors.New("cannot remove the root of the document")
+		}
+		return patchRemove(root, path)
+
+	case "replace":
+		if len(path) == 0 {
+			return DeepClone(value), nil
+		}
+		return patchUpdate(root, path, func(parent any, tok string) (any, error) {
+			switch p := parent.(type) {
+			case *Map[string, any]:
+				if _, ok := p.Get2(tok); !ok {
+					return nil, fmt.Errorf("key %q not found", tok)
+				}
+				p.Set(tok, DeepClone(value))
+				return p, nil
+			case []any:
+				idx, err := parseArrayIndex(tok, len(p), false)
+				if err != nil {
+					return nil, err
+				}
+				p[idx] = DeepClone(value)
+				return p, nil
+			default:
+				return nil, fmt.Errorf("cannot replace a child of a value of type %T", parent)
+			}
+		})
+
+	case "move", "copy":
+		from, err := parseJSONPointer(op.From)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 'from': %w", err)
+		}
+		v, err := patchGet(root, from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 'from': %w", err)
+		}
+
+		if op.Op == "copy" {
+			return patchAdd(root, path, DeepClone(v))
+		}
+
+		if len(from) < len(path) && isPathPrefix(from, path) {
+			return nil, errors.New("cannot move a value into one of its children")
+		}
+		if len(from) == len(path) && isPathPrefix(from, path) {
+			// from == path: nothing to do
+			return root, nil
+		}
+		root, err = patchRemove(root, from)
+		if err != nil {
+			return nil, err
+		}
+		return patchAdd(root, path, v)
+
+	case "test":
+		v, err := patchGet(root, path)
+		if err != nil {
+			return nil, err
+		}
+		if !jsonEqual(v, value, false) {
+			return nil, errors.New("test failed: values differ")
+		}
+		return root, nil
+
+	default:
+		return nil, fmt.Errorf("unknown operation %q", op.Op)
+	}
+}
+
+func patchAdd(root any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return patchUpdate(root, path, func(parent any, tok string) (any, error) {
+		switch p := parent.(type) {
+		case *Map[string, any]:
+			p.Set(tok, value)
+			return p, nil
+		case []any:
+			idx, err := parseArrayIndex(tok, len(p), true)
+			if err != nil {
+				return nil, err
+			}
+			p = append(p, nil)
+			copy(p[idx+1:], p[idx:])
+			p[idx] = value
+			return p, nil
+		default:
+			return nil, fmt.Errorf("cannot add a child to a value of type %T", parent)
+		}
+	})
+}
+
+func patchRemove(root any, path []string) (any, error) {
+	return patchUpdate(root, path, func(parent any, tok string) (any, error) {
+		switch p := parent.(type) {
+		case *Map[string, any]:
+			if !p.Delete(tok) {
+				return nil, fmt.Errorf("key %q not found", tok)
+			}
+			return p, nil
+		case []any:
+			idx, err := parseArrayIndex(tok, len(p), false)
+			if err != nil {
+				return nil, err
+			}
+			return append(p[:idx], p[idx+1:]...), nil
+		default:
+			return nil, fmt.Errorf("cannot remove a child of a value of type %T", parent)
+		}
+	})
+}
+
+// patchUpdate
+//
+// walks down 'node' following 'path', calls 'fn' on the parent of the target location,
+// and stores the value returned by 'fn' in place of the parent.
+// 'path' must not be empty.
+func patchUpdate(node any, path []string, fn func(parent any, tok string) (any, error)) (any, error) {
+	if len(path) == 1 {
+		return fn(node, path[0])
+	}
+
+	child, err := patchChild(node, path[0])
+	if err != nil {
+		return nil, err
+	}
+	child, err = patchUpdate(child, path[1:], fn)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n := node.(type) {
+	case *Map[string, any]:
+		n.Set(path[0], child)
+	case []any:
+		idx, _ := parseArrayIndex(path[0], len(n), false)
+		n[idx] = child
+	}
+	return node, nil
+}
+
+func patchGet(node any, path []string) (any, error) {
+	for _, tok := range path {
+		var err error
+		node, err = patchChild(node, tok)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+func patchChild(node any, tok string) (any, error) {
+	switch n := node.(type) {
+	case *Map[string, any]:
+		v, ok := n.Get2(tok)
+		if !ok {
+			return nil, fmt.Errorf("key %q not found", tok)
+		}
+		return v, nil
+	case []any:
+		idx, err := parseArrayIndex(tok, len(n), false)
+		if err != nil {
+			return nil, err
+		}
+		return n[idx], nil
+	default:
+		return nil, fmt.Errorf("cannot look up %q in a value of type %T", tok, node)
+	}
+}
+
+// parseArrayIndex
+//
+// parses a JSON Pointer token used as an array index.
+// If 'forInsert' is true, "-" and 'length' are accepted and designate the end of the array.
+func parseArrayIndex(tok string, length int, forInsert bool) (int, error) {
+	if forInsert && tok == "-" {
+		return length, nil
+	}
+	if tok == "" || (len(tok) > 1 && tok[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", tok)
+	}
+	for i := 0; i < len(tok); i++ {
+		if tok[i] < '0' || tok[i] > '9' {
+			return 0, fmt.Errorf("invalid array index %q", tok)
+		}
+	}
+	idx, err := strconv.Atoi(tok)
+	if err != nil {
+		return 0, fmt.Errorf("invalid array index %q", tok)
+	}
+
+	max := length - 1
+	if forInsert {
+		max = length
+	}
+	if idx > max {
+		return 0, fmt.Errorf("array index %d out of bounds", idx)
+	}
+	return idx, nil
+}
+
+var (
+	pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+	pointerEscaper   = strings.NewReplacer("~", "~0", "/", "~1")
+)
+
+func parseJSONPointer(ptr string) ([]string, error) {
+	if ptr == "" {
+		return nil, nil
+	}
+	if ptr[0] != '/' {
+		return nil, fmt.Errorf("invalid JSON pointer %q: should start with '/'", ptr)
+	}
+	toks := strings.Split(ptr[1:], "/")
+	for i, tok := range toks {
+		toks[i] = pointerUnescaper.Replace(tok)
+	}
+	return toks, nil
+}
+
+func isPathPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonNumber
+//
+// returns the float64 value of any go numeric value, or of a json.Number.
+func jsonNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), true
+	}
+	return 0, false
+}
+
+// jsonEqual
+//
+// compares two JSON values.
+// If 'ordered' is false, the order of keys in objects is ignored (as specified for the
+// "test" operation of RFC 6902).
+func jsonEqual(a, b any, ordered bool) bool {
+	if x, ok := a.(Any); ok {
+		a = x.v
+	}
+	if x, ok := b.(Any); ok {
+		b = x.v
+	}
+
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	if fa, ok := jsonNumber(a); ok {
+		fb, ok := jsonNumber(b)
+		return ok && fa == fb
+	}
+
+	switch x := a.(type) {
+	case string:
+		y, ok := b.(string)
+		return ok && x == y
+	case bool:
+		y, ok := b.(bool)
+		return ok && x == y
+	case *Map[string, any]:
+		y, ok := b.(*Map[string, any])
+		if !ok || x.Len() != y.Len() {
+			return false
+		}
+		for i, k := range x.keys {
+			if ordered && y.keys[i] != k {
+				return false
+			}
+			vy, ok := y.m[k]
+			if !ok || !jsonEqual(x.m[k], vy, ordered) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		y, ok := b.([]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !jsonEqual(x[i], y[i], ordered) {
+				return false
+			}
+		}
+		return true
+	}
+
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(ja, jb)
+}
+
+// CreatePatch returns a patch which, when applied on 'a', produces 'b'.
+//
+// The order of the keys is taken into account: if keys of an object appear in a different
+// order in 'b', the patch contains a "remove" followed by an "add" for each misplaced key,
+// which sends it to the end of its object. Only standard operations are used, so that any
+// RFC 6902 implementation which preserves key order produces the same result.
+//
+// Arrays are compared element by element, after trimming their common prefix and suffix.
+func CreatePatch(a, b Any) Patch {
+	var patch Patch
+	diffJSONValues(&patch, "", a.v, b.v)
+	return patch
+}
+
+func diffJSONValues(patch *Patch, path string, a, b any) {
+	if jsonEqual(a, b, true) {
+		return
+	}
+
+	switch x := a.(type) {
+	case *Map[string, any]:
+		if y, ok := b.(*Map[string, any]); ok {
+			diffJSONObjects(patch, path, x, y)
+			return
+		}
+	case []any:
+		if y, ok := b.([]any); ok {
+			diffJSONArrays(patch, path, x, y)
+			return
+		}
+	}
+
+	*patch = append(*patch, PatchOperation{Op: "replace", Path: path, Value: DeepClone(b)})
+}
+
+func diffJSONObjects(patch *Patch, path string, a, b *Map[string, any]) {
+	// remove keys which do not exist in 'b',
+	// and compute the position of remaining keys in 'a':
+	posInA := make(map[string]int)
+	for _, k := range a.keys {
+		if _, ok := b.m[k]; !ok {
+			*patch = append(*patch, PatchOperation{Op: "remove", Path: path + "/" + pointerEscaper.Replace(k)})
+			continue
+		}
+		posInA[k] = len(posInA)
+	}
+
+	// the longest prefix of 'b' keys which exist in 'a', in the same order, can stay in place.
+	// all following keys are either added or removed and re-added at the end of the object,
+	// in the order of 'b'.
+	kept := 0
+	lastPos := -1
+	for _, k := range b.keys {
+		pos, ok := posInA[k]
+		if !ok || pos < lastPos {
+			break
+		}
+		lastPos = pos
+		kept++
+	}
+
+	for i, k := range b.keys {
+		childPath := path + "/" + pointerEscaper.Replace(k)
+
+		if _, ok := posInA[k]; !ok {
+			*patch = append(*patch, PatchOperation{Op: "add", Path: childPath, Value: DeepClone(b.m[k])})
+			continue
+		}
+
+		if i >= kept {
+			*patch = append(*patch,
+				PatchOperation{Op: "remove", Path: childPath},
+				PatchOperation{Op: "add", Path: childPath, Value: DeepClone(b.m[k])},
+			)
+			continue
+		}
+		diffJSONValues(patch, childPath, a.m[k], b.m[k])
+	}
+}
+
+func diffJSONArrays(patch *Patch, path string, a, b []any) {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && jsonEqual(a[prefix], b[prefix], true) {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && jsonEqual(a[len(a)-1-suffix], b[len(b)-1-suffix], true) {
+		suffix++
+	}
+
+	midA := a[prefix : len(a)-suffix]
+	midB := b[prefix : len(b)-suffix]
+
+	common := len(midA)
+	if len(midB) < common {
+		common = len(midB)
+	}
+	for i := 0; i < common; i++ {
+		diffJSONValues(patch, path+"/"+strconv.Itoa(prefix+i), midA[i], midB[i])
+	}
+	for i := len(midA) - 1; i >= common; i-- {
+		*patch = append(*patch, PatchOperation{Op: "remove", Path: path + "/" + strconv.Itoa(prefix+i)})
+	}
+	for i := common; i < len(midB); i++ {
+		*patch = append(*patch, PatchOperation{Op: "add", Path: path + "/" + strconv.Itoa(prefix+i), Value: DeepClone(midB[i])})
+	}
+}
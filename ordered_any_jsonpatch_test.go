@@ -0,0 +1,162 @@
+package ordmap
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustAny(t *testing.T, input string) Any {
+	t.Helper()
+	var x Any
+	err := json.Unmarshal([]byte(input), &x)
+	require.NoError(t, err)
+	return x
+}
+
+func mustPatch(t *testing.T, input string) Patch {
+	t.Helper()
+	var p Patch
+	err := json.Unmarshal([]byte(input), &p)
+	require.NoError(t, err)
+	return p
+}
+
+func TestApplyPatch(t *testing.T) {
+	type testCase struct{ doc, patch, expected string }
+	table := []testCase{
+		// add on an object appends the key at the end:
+		{`{"c":1,"a":2}`, `[{"op":"add","path":"/b","value":3}]`, `{"c":1,"a":2,"b":3}`},
+		// add on an existing key replaces the value in place:
+		{`{"c":1,"a":2}`, `[{"op":"add","path":"/c","value":{"z":1,"y":2}}]`, `{"c":{"z":1,"y":2},"a":2}`},
+		{`{"a":[1,2]}`, `[{"op":"add","path":"/a/1","value":3}]`, `{"a":[1,3,2]}`},
+		{`{"a":[1,2]}`, `[{"op":"add","path":"/a/-","value":3}]`, `{"a":[1,2,3]}`},
+		{`{"a":1}`, `[{"op":"add","path":"","value":[1]}]`, `[1]`},
+		{`{"c":1,"a":2,"b":3}`, `[{"op":"remove","path":"/a"}]`, `{"c":1,"b":3}`},
+		{`[1,2,3]`, `[{"op":"remove","path":"/1"}]`, `[1,3]`},
+		{`{"c":1,"a":2,"b":3}`, `[{"op":"replace","path":"/a","value":null}]`, `{"c":1,"a":null,"b":3}`},
+		// move keeps the order of the other keys, and appends the moved key:
+		{`{"c":1,"a":2,"b":3}`, `[{"op":"move","from":"/c","path":"/d"}]`, `{"a":2,"b":3,"d":1}`},
+		// as in RFC 6902, moving a value to its own location is a no-op:
+		{`{"c":1,"a":2,"b":3}`, `[{"op":"move","from":"/c","path":"/c"}]`, `{"c":1,"a":2,"b":3}`},
+		{`{"x":{"c":1,"a":2}}`, `[{"op":"move","from":"/x/c","path":"/x/c"}]`, `{"x":{"c":1,"a":2}}`},
+		{`{"x":{"c":1,"a":2},"y":{}}`, `[{"op":"move","from":"/x/a","path":"/y/a"}]`, `{"x":{"c":1},"y":{"a":2}}`},
+		{`{"x":{"k":[1]},"y":2}`, `[{"op":"copy","from":"/x","path":"/z"},{"op":"add","path":"/z/k/-","value":2}]`, `{"x":{"k":[1]},"y":2,"z":{"k":[1,2]}}`},
+		// keys containing '/' and '~':
+		{`{"a/b":1,"m~n":2}`, `[{"op":"replace","path":"/a~1b","value":3},{"op":"remove","path":"/m~0n"}]`, `{"a/b":3}`},
+		// test ignores the order of keys:
+		{`{"a":{"x":1,"y":[true]}}`, `[{"op":"test","path":"/a","value":{"y":[true],"x":1.0}}]`, `{"a":{"x":1,"y":[true]}}`},
+	}
+
+	for i, tc := range table {
+		doc := mustAny(t, tc.doc)
+		patch := mustPatch(t, tc.patch)
+
+		res, err := ApplyPatch(doc, patch)
+		require.NoError(t, err, "test %d: patch: %s", i, tc.patch)
+
+		bs, err := json.Marshal(res)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, string(bs), "test %d: patch: %s", i, tc.patch)
+
+		// the original document must be left untouched:
+		bs, err = json.Marshal(doc)
+		require.NoError(t, err)
+		assert.Equal(t, tc.doc, string(bs), "test %d: original document was modified", i)
+	}
+}
+
+func TestApplyPatchErrors(t *testing.T) {
+	type testCase struct{ doc, patch string }
+	table := []testCase{
+		{`{"a":1}`, `[{"op":"remove","path":"/b"}]`},
+		{`{"a":1}`, `[{"op":"replace","path":"/b","value":2}]`},
+		{`{"a":1}`, `[{"op":"add","path":"/b/c","value":2}]`},
+		{`[1,2]`, `[{"op":"add","path":"/3","value":2}]`},
+		{`[1,2]`, `[{"op":"remove","path":"/01"}]`},
+		{`[1,2]`, `[{"op":"remove","path":"/-"}]`},
+		{`{"a":{"b":1}}`, `[{"op":"move","from":"/a","path":"/a/b/c"}]`},
+		{`{"a":1}`, `[{"op":"test","path":"/a","value":"1"}]`},
+		{`{"a":1}`, `[{"op":"frobnicate","path":"/a"}]`},
+		{`{"a":1}`, `[{"op":"add","path":"a","value":1}]`},
+	}
+
+	for i, tc := range table {
+		doc := mustAny(t, tc.doc)
+		patch := mustPatch(t, tc.patch)
+
+		_, err := ApplyPatch(doc, patch)
+		assert.Error(t, err, "test %d: patch: %s", i, tc.patch)
+	}
+}
+
+func TestPatchOperation_JSON(t *testing.T) {
+	input := `[{"op":"add","path":"/a","value":{"z":1,"y":null}},{"op":"remove","path":"/b"},{"op":"move","from":"/c","path":"/d"},{"op":"test","path":"/e","value":null}]`
+
+	patch := mustPatch(t, input)
+	require.Len(t, patch, 4)
+	assert.IsType(t, &Map[string, any]{}, patch[0].Value)
+	assert.Equal(t, "/c", patch[2].From)
+
+	bs, err := json.Marshal(patch)
+	require.NoError(t, err)
+	assert.Equal(t, input, string(bs))
+
+	errorCases := []string{
+		`{"path":"/a"}`,
+		`{"op":"add","path":"/a"}`,
+		`{"op":"move","path":"/a"}`,
+		`{"op":1,"path":"/a"}`,
+	}
+	for _, input := range errorCases {
+		var op PatchOperation
+		err := json.Unmarshal([]byte(input), &op)
+		assert.Error(t, err, "input: %s", input)
+	}
+}
+
+func TestCreatePatch(t *testing.T) {
+	type testCase struct{ a, b string }
+	table := []testCase{
+		{`{"a":1}`, `{"a":1}`},
+		{`{"a":1,"b":2}`, `{"a":1,"b":3}`},
+		{`{"a":1,"b":2}`, `{"a":1,"c":{"x":1,"w":2}}`},
+		// key order changes:
+		{`{"a":1,"b":2,"c":3}`, `{"c":3,"a":1,"b":2}`},
+		{`{"a":1,"b":2,"c":3}`, `{"a":1,"d":4,"c":3,"b":5}`},
+		{`{"x":{"a":1,"b":2},"y":[1,2,3,4]}`, `{"y":[1,5,4],"x":{"b":2,"a":1}}`},
+		{`[1,2,3]`, `[0,1,2,3]`},
+		{`[1,2,3]`, `{"a":1}`},
+		{`"foo"`, `null`},
+	}
+
+	for i, tc := range table {
+		a := mustAny(t, tc.a)
+		b := mustAny(t, tc.b)
+
+		patch := CreatePatch(a, b)
+
+		res, err := ApplyPatch(a, patch)
+		require.NoError(t, err, "test %d", i)
+
+		bs, err := json.Marshal(res)
+		require.NoError(t, err)
+		assert.Equal(t, tc.b, string(bs), "test %d: patch: %v", i, patch)
+	}
+}
+
+func TestCreatePatch_Minimal(t *testing.T) {
+	a := mustAny(t, `{"a":1,"b":2,"c":3,"d":[1,2,3]}`)
+	b := mustAny(t, `{"a":1,"c":3,"b":2,"d":[1,3],"e":true}`)
+
+	patch := CreatePatch(a, b)
+
+	bs, err := json.Marshal(patch)
+	require.NoError(t, err)
+	expected := `[{"op":"remove","path":"/b"},{"op":"add","path":"/b","value":2},{"op":"remove","path":"/d"},{"op":"add","path":"/d","value":[1,3]},{"op":"add","path":"/e","value":true}]`
+	assert.Equal(t, expected, string(bs))
+
+	assert.Empty(t, CreatePatch(a, a))
+}
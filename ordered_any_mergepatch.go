@@ -0,0 +1,134 @@
+package ordmap
+
+import "fmt"
+
+// MergePatch applies a JSON Merge Patch (RFC 7396) on a copy of 'target', and returns the result.
+//
+// The order of the keys is preserved: keys which already exist in 'target' keep their
+// position, new keys are appended in the order in which they appear in 'patch', and
+// keys with a `null` value in 'patch' are deleted.
+//
+// Objects may be `*Map[string, any]` (as produced by JSON decoding) or `*Map[any, any]`
+// with string keys (as produced by YAML decoding); objects in the result are `*Map[string, any]`.
+func MergePatch(target, patch Any) (Any, error) {
+	res, err := mergePatchValue(DeepClone(target.v), patch.v)
+	if err != nil {
+		return Any{}, err
+	}
+	return Any{v: res}, nil
+}
+
+func mergePatchValue(target, patch any) (any, error) {
+	patchObj, ok, err := mergePatchObject(patch)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return DeepClone(patch), nil
+	}
+
+	targetObj, ok, err := mergePatchObject(target)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		targetObj = &Map[string, any]{}
+	}
+
+	for _, k := range patchObj.keys {
+		pv := patchObj.m[k]
+		if pv == nil {
+			targetObj.Delete(k)
+			continue
+		}
+
+		v, err := mergePatchValue(targetObj.m[k], pv)
+		if err != nil {
+			return nil, fmt.Errorf("error when merging key %q: %w", k, err)
+		}
+		targetObj.Set(k, v)
+	}
+	return targetObj, nil
+}
+
+// mergePatchObject
+//
+// returns 'v' as a `*Map[string, any]` if it represents a JSON object.
+// A `*Map[any, any]` is converted to a new `*Map[string, any]`, and an error is returned
+// if one of its keys is not a string.
+func mergePatchObject(v any) (*Map[string, any], bool, error) {
+	switch x := v.(type) {
+	case Any:
+		return mergePatchObject(x.v)
+	case *Map[string, any]:
+		return x, true, nil
+	case *Map[any, any]:
+		res := &Map[string, any]{}
+		for _, k := range x.keys {
+			s, ok := k.(string)
+			if !ok {
+				return nil, false, fmt.Errorf("object key %v is a %T, expected a string", k, k)
+			}
+			res.Set(s, x.m[k])
+		}
+		return res, true, nil
+	default:
+		return nil, false, nil
+	}
+}
+
+// CreateMergePatch returns a JSON Merge Patch (RFC 7396) which, when applied on 'from', produces 'to'.
+//
+// Keys which are added in 'to' appear in the patch in the same order as in 'to', so that
+// applying the patch appends them in that order. A merge patch cannot describe a change
+// in the order of existing keys, nor set a key to `null`: the latter returns an error.
+func CreateMergePatch(from, to Any) (Any, error) {
+	res, err := createMergePatchValue(from.v, to.v)
+	if err != nil {
+		return Any{}, err
+	}
+	return Any{v: res}, nil
+}
+
+func createMergePatchValue(from, to any) (any, error) {
+	toObj, ok, err := mergePatchObject(to)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return DeepClone(to), nil
+	}
+
+	fromObj, ok, err := mergePatchObject(from)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		fromObj = &Map[string, any]{}
+	}
+
+	res := &Map[string, any]{}
+	for _, k := range fromObj.keys {
+		if _, ok := toObj.m[k]; !ok {
+			res.Set(k, nil)
+		}
+	}
+	for _, k := range toObj.keys {
+		tv := toObj.m[k]
+		if tv == nil {
+			return nil, fmt.Errorf("error when creating merge patch: key %q has a null value, which cannot be expressed in a merge patch", k)
+		}
+
+		fv, ok := fromObj.m[k]
+		if ok && jsonEqual(fv, tv, false) {
+			continue
+		}
+
+		v, err := createMergePatchValue(fv, tv)
+		if err != nil {
+			return nil, err
+		}
+		res.Set(k, v)
+	}
+	return res, nil
+}
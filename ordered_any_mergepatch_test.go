@@ -0,0 +1,111 @@
+package ordmap
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestMergePatch(t *testing.T) {
+	type testCase struct{ target, patch, expected string }
+	table := []testCase{
+		// examples from RFC 7396, appendix A:
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+
+		// existing keys keep their position, new keys are appended in the patch order:
+		{`{"z":1,"y":2,"x":3}`, `{"w":4,"y":5,"v":6,"z":null}`, `{"y":5,"x":3,"w":4,"v":6}`},
+		{
+			`{"name":"svc","spec":{"replicas":1,"image":"app:1","env":{"B":"1","A":"2"}}}`,
+			`{"spec":{"env":{"C":"3","B":null},"image":"app:2","ports":[80]}}`,
+			`{"name":"svc","spec":{"replicas":1,"image":"app:2","env":{"A":"2","C":"3"},"ports":[80]}}`,
+		},
+	}
+
+	for i, tc := range table {
+		target := mustAny(t, tc.target)
+		patch := mustAny(t, tc.patch)
+
+		res, err := MergePatch(target, patch)
+		require.NoError(t, err, "test %d", i)
+
+		bs, err := json.Marshal(res)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, string(bs), "test %d: target: %s patch: %s", i, tc.target, tc.patch)
+
+		// the original target must be left untouched:
+		assert.Equal(t, tc.target, jsonMarshalString(t, target), "test %d", i)
+	}
+}
+
+func TestMergePatch_Map(t *testing.T) {
+	var m Map[string, any]
+	m.Set("b", 1)
+	m.Set("a", &Map[string, any]{})
+	m.Get("a").(*Map[string, any]).Set("y", true)
+
+	res, err := MergePatch(NewAny(&m), mustAny(t, `{"a":{"x":false},"c":[1]}`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"b":1,"a":{"y":true,"x":false},"c":[1]}`, jsonMarshalString(t, res))
+	assert.Equal(t, `{"b":1,"a":{"y":true}}`, jsonMarshalString(t, &m))
+
+	// yaml decoded objects are accepted, as long as their keys are strings:
+	var y Any
+	err = yaml.Unmarshal([]byte("b: 1\na:\n  y: true\n"), &y)
+	require.NoError(t, err)
+	res, err = MergePatch(y, mustAny(t, `{"a":{"x":false}}`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"b":1,"a":{"y":true,"x":false}}`, jsonMarshalString(t, res))
+
+	err = yaml.Unmarshal([]byte("b: 1\n12: true\n"), &y)
+	require.NoError(t, err)
+	_, err = MergePatch(y, mustAny(t, `{"a":{"x":false}}`))
+	assert.Error(t, err)
+}
+
+func TestCreateMergePatch(t *testing.T) {
+	type testCase struct{ from, to, expected string }
+	table := []testCase{
+		{`{"a":1}`, `{"a":1}`, `{}`},
+		{`{"a":1,"b":2}`, `{"a":1}`, `{"b":null}`},
+		{`{"a":1}`, `{"a":1,"c":3,"b":2}`, `{"c":3,"b":2}`},
+		{`{"a":{"x":1,"y":2}}`, `{"a":{"x":1,"y":3,"z":[]}}`, `{"a":{"y":3,"z":[]}}`},
+		{`{"a":[1,2]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`{"a":1}`, `[1]`, `[1]`},
+		{`[1]`, `{"a":{"b":1}}`, `{"a":{"b":1}}`},
+	}
+
+	for i, tc := range table {
+		from := mustAny(t, tc.from)
+		to := mustAny(t, tc.to)
+
+		patch, err := CreateMergePatch(from, to)
+		require.NoError(t, err, "test %d", i)
+		assert.Equal(t, tc.expected, jsonMarshalString(t, patch), "test %d", i)
+
+		res, err := MergePatch(from, patch)
+		require.NoError(t, err)
+		assert.Equal(t, tc.to, jsonMarshalString(t, res), "test %d", i)
+	}
+
+	_, err := CreateMergePatch(mustAny(t, `{}`), mustAny(t, `{"a":null}`))
+	assert.Error(t, err)
+	_, err = CreateMergePatch(mustAny(t, `{}`), mustAny(t, `{"a":{"b":null}}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,426 @@
+package ordmap
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// NormalizeMode tells `Any.Normalize()` which shape the values should be converted to.
+type NormalizeMode int
+
+const (
+	// NormalizeJSON converts values to the shape produced by decoding JSON into an Any:
+	//   - objects are `*Map[string, any]`: string keys are kept, booleans, numbers and null keys
+	//     are formatted as strings ("true", "12", "1.5", "null"), timestamp keys use RFC 3339,
+	//   - numbers are float64,
+	//   - timestamps are strings in RFC 3339 format,
+	//   - `!!binary` scalars (decoded with `YAMLOptions.PreserveScalars`) and []byte values are base64 strings.
+	//
+	// Lossy conversions are an error unless `NormalizeLossy` is set: integers, and json.Number
+	// values, which can not be represented exactly as a float64, NaN and infinite numbers (converted
+	// to null), strings which
+	// are not valid UTF-8 (invalid bytes are replaced with U+FFFD), `ComplexKey` keys (replaced with
+	// their canonical form), and keys which collide once formatted (the first entry is kept).
+	NormalizeJSON NormalizeMode = 1 << iota
+
+	// NormalizeYAML converts values to the shape produced by decoding YAML into an Any:
+	// objects are `*Map[any, any]`, and float numbers without a fractional part are int values
+	// (except -0, which stays a float64).
+	//
+	// json.Number values keep the type they are written with: numbers written as integers are
+	// int values (int64 or uint64 if they do not fit in an int), other numbers are float64.
+	// Numbers which can not be represented exactly are a lossy conversion, see `NormalizeJSON`.
+	NormalizeYAML
+
+	// NormalizeLossy allows lossy conversions, see `NormalizeJSON`.
+	NormalizeLossy
+)
+
+// maxExactFloat is the largest integer below which all integers can be represented by a float64.
+const maxExactFloat = 1 << 53
+
+// Normalize returns a copy of 'x' where all objects, numbers, timestamps and binary values
+// are converted to the shape described by 'mode'. The order of keys is kept.
+//
+// `*YAMLAnchor` and `*YAMLScalar` wrappers are replaced with their value.
+func (x Any) Normalize(mode NormalizeMode) (Any, error) {
+	n := normalizer{lossy: mode&NormalizeLossy != 0}
+	switch mode &^ NormalizeLossy {
+	case NormalizeJSON:
+		n.json = true
+	case NormalizeYAML:
+	default:
+		return Any{}, fmt.Errorf("invalid normalize mode %d: expected NormalizeJSON or NormalizeYAML", mode)
+	}
+
+	v, err := n.normalize(nil, x.v)
+	if err != nil {
+		return Any{}, err
+	}
+	return Any{v: v}, nil
+}
+
+type normalizer struct {
+	json  bool
+	lossy bool
+}
+
+// lossyErr
+//
+// returns an error describing a lossy conversion at 'path', or nil if lossy conversions are allowed.
+func (n *normalizer) lossyErr(path Path, format string, args ...any) error {
+	if n.lossy {
+		return nil
+	}
+	return fmt.Errorf("error when normalizing %q: %s (use NormalizeLossy to allow lossy conversions)", path, fmt.Sprintf(format, args...))
+}
+
+func (n *normalizer) normalize(path Path, v any) (any, error) {
+	switch x := v.(type) {
+	case Any:
+		return n.normalize(path, x.v)
+	case *Any:
+		if x == nil {
+			return nil, nil
+		}
+		return n.normalize(path, x.v)
+	case *YAMLAnchor:
+		return n.normalize(path, x.Value)
+	case *YAMLScalar:
+		if n.json && x.Tag == "!!binary" && !x.Modified() {
+			return strings.Join(strings.Fields(x.Text), ""), nil
+		}
+		return n.normalize(path, x.Value)
+
+	case []any:
+		if x == nil {
+			return x, nil
+		}
+		res := make([]any, len(x))
+		for i, elt := range x {
+			elt, err := n.normalize(path.Index(i), elt)
+			if err != nil {
+				return nil, err
+			}
+			res[i] = elt
+		}
+		return res, nil
+
+	case anyMap:
+		if reflect.ValueOf(x).IsNil() {
+			return nil, nil
+		}
+		if n.json {
+			return n.normalizeJSONObject(path, x)
+		}
+		return n.normalizeYAMLObject(path, x)
+
+	case string:
+		if n.json && !utf8.ValidString(x) {
+			if err := n.lossyErr(path, "string is not valid UTF-8"); err != nil {
+				return nil, err
+			}
+			return strings.ToValidUTF8(x, "\uFFFD"), nil
+		}
+		return x, nil
+
+	case []byte:
+		if n.json {
+			return base64.StdEncoding.EncodeToString(x), nil
+		}
+		return x, nil
+
+	case time.Time:
+		if n.json {
+			return x.Format(time.RFC3339Nano), nil
+		}
+		return x, nil
+
+	case json.Number:
+		return n.normalizeNumber(path, x)
+
+	case float64:
+		return n.normalizeFloat(path, x)
+	case float32:
+		return n.normalizeFloat(path, float64(x))
+
+	case int:
+		return n.normalizeInt(path, int64(x))
+	case int8:
+		return n.normalizeInt(path, int64(x))
+	case int16:
+		return n.normalizeInt(path, int64(x))
+	case int32:
+		return n.normalizeInt(path, int64(x))
+	case int64:
+		return n.normalizeInt(path, x)
+	case uint:
+		return n.normalizeUint(path, uint64(x))
+	case uint8:
+		return n.normalizeUint(path, uint64(x))
+	case uint16:
+		return n.normalizeUint(path, uint64(x))
+	case uint32:
+		return n.normalizeUint(path, uint64(x))
+	case uint64:
+		return n.normalizeUint(path, x)
+	}
+	return v, nil
+}
+
+func (n *normalizer) normalizeFloat(path Path, f float64) (any, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		if !n.json {
+			return f, nil
+		}
+		if err := n.lossyErr(path, "%v can not be represented in JSON", f); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+	// -0 would lose its sign as an int:
+	negZero := f == 0 && math.Signbit(f)
+	if !n.json && f == math.Trunc(f) && math.Abs(f) < maxExactFloat && !negZero {
+		return int(f), nil
+	}
+	return f, nil
+}
+
+// normalizeNumber
+//
+// converts 'x' to an integer when it is written as an integer, and to a float64 otherwise.
+// The conversion is lossy if the float64 does not format back to the same number.
+func (n *normalizer) normalizeNumber(path Path, x json.Number) (any, error) {
+	s := string(x)
+	if !strings.ContainsAny(s, ".eE") {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n.normalizeInt(path, i)
+		}
+		if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return n.normalizeUint(path, u)
+		}
+	}
+
+	exact, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("error when normalizing %q: invalid number %q", path, s)
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return nil, fmt.Errorf("error when normalizing %q: %w", path, err)
+	}
+	if math.IsInf(f, 0) {
+		if err := n.lossyErr(path, "number %s overflows a float64", s); err != nil {
+			return nil, err
+		}
+		return n.normalizeFloat(path, f)
+	}
+	back, _ := new(big.Rat).SetString(strconv.FormatFloat(f, 'g', -1, 64))
+	if back.Cmp(exact) != 0 {
+		if err := n.lossyErr(path, "number %s can not be represented exactly as a float64", s); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (n *normalizer) normalizeInt(path Path, i int64) (any, error) {
+	if !n.json {
+		if int64(int(i)) == i {
+			return int(i), nil
+		}
+		return i, nil
+	}
+	if i > maxExactFloat || i < -maxExactFloat {
+		if err := n.lossyErr(path, "integer %d can not be represented exactly as a float64", i); err != nil {
+			return nil, err
+		}
+	}
+	return float64(i), nil
+}
+
+func (n *normalizer) normalizeUint(path Path, u uint64) (any, error) {
+	if u <= math.MaxInt64 {
+		return n.normalizeInt(path, int64(u))
+	}
+	if !n.json {
+		return u, nil
+	}
+	if err := n.lossyErr(path, "integer %d can not be represented exactly as a float64", u); err != nil {
+		return nil, err
+	}
+	return float64(u), nil
+}
+
+func (n *normalizer) normalizeJSONObject(path Path, m anyMap) (*Map[string, any], error) {
+	res := &Map[string, any]{}
+	err := m.eachAny(func(k, v any) error {
+		entryPath := path.Key(k)
+		key, err := n.jsonKey(entryPath, k)
+		if err != nil {
+			return err
+		}
+		if _, exists := res.Get2(key); exists {
+			if err := n.lossyErr(entryPath, "key %q is already present", key); err != nil {
+				return err
+			}
+			return nil
+		}
+
+		v, err = n.normalize(entryPath, v)
+		if err != nil {
+			return err
+		}
+		res.Set(key, v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// jsonKey
+//
+// formats the key 'k' as a string.
+func (n *normalizer) jsonKey(path Path, k any) (string, error) {
+	switch x := k.(type) {
+	case string:
+		if !utf8.ValidString(x) {
+			if err := n.lossyErr(path, "key is not valid UTF-8"); err != nil {
+				return "", err
+			}
+			return strings.ToValidUTF8(x, "\uFFFD"), nil
+		}
+		return x, nil
+	case nil:
+		return "null", nil
+	case bool:
+		return strconv.FormatBool(x), nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(x), nil
+	case float64:
+		return strconv.FormatFloat(x, 'g', -1, 64), nil
+	case float32:
+		return strconv.FormatFloat(float64(x), 'g', -1, 32), nil
+	case time.Time:
+		return x.Format(time.RFC3339Nano), nil
+	case ComplexKey:
+		if err := n.lossyErr(path, "complex key %s can not be represented in JSON", x); err != nil {
+			return "", err
+		}
+		return x.String(), nil
+	}
+
+	if err := n.lossyErr(path, "key of type %T can not be represented in JSON", k); err != nil {
+		return "", err
+	}
+	return fmt.Sprint(k), nil
+}
+
+func (n *normalizer) normalizeYAMLObject(path Path, m anyMap) (*Map[any, any], error) {
+	res := &Map[any, any]{}
+	err := m.eachAny(func(k, v any) error {
+		entryPath := path.Key(k)
+		v, err := n.normalize(entryPath, v)
+		if err != nil {
+			return err
+		}
+		res.Set(k, v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// JSONToYAML converts a JSON document to YAML, keeping the order of keys.
+//
+// Numbers keep their type: numbers written as integers are YAML integers, other numbers
+// are YAML floats (`2.0` is written `2.0`). Numbers which can not be represented exactly
+// as an int64, a uint64 or a float64 are an error.
+func JSONToYAML(data []byte) ([]byte, error) {
+	var x Any
+	err := x.unmarshalJSON(data, true)
+	if err != nil {
+		return nil, err
+	}
+
+	x, err = x.Normalize(NormalizeYAML)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	err = enc.Encode(Any{v: markYAMLFloats(x.v)})
+	if err != nil {
+		return nil, err
+	}
+	err = enc.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// markYAMLFloats
+//
+// replaces, in the normalized value 'v', the float64 values which the yaml encoder would write
+// like integers with scalar nodes which have a fractional part ("2.0"), so that they are decoded
+// as floats.
+func markYAMLFloats(v any) any {
+	switch x := v.(type) {
+	case float64:
+		s := strconv.FormatFloat(x, 'g', -1, 64)
+		if !math.IsInf(x, 0) && !math.IsNaN(x) && !strings.ContainsAny(s, ".e") {
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: s + ".0"}
+		}
+	case []any:
+		for i, elt := range x {
+			x[i] = markYAMLFloats(elt)
+		}
+	case *Map[any, any]:
+		for _, k := range x.keys {
+			x.m[k] = markYAMLFloats(x.m[k])
+		}
+	}
+	return v
+}
+
+// YAMLToJSON converts the first document of a YAML stream to JSON, keeping the order of keys.
+//
+// Values are converted following the rules of `NormalizeJSON`; lossy conversions are an error.
+func YAMLToJSON(data []byte) ([]byte, error) {
+	var node yaml.Node
+	err := yaml.Unmarshal(data, &node)
+	if err != nil {
+		return nil, err
+	}
+
+	var x Any
+	err = x.UnmarshalYAMLWith(&node, YAMLOptions{PreserveScalars: true, ComplexKeys: true})
+	if err != nil {
+		return nil, err
+	}
+
+	x, err = x.Normalize(NormalizeJSON)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(x)
+}
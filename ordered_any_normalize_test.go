@@ -0,0 +1,155 @@
+package ordmap
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestYAMLToJSON(t *testing.T) {
+	input := `name: demo
+count: 3
+ratio: 0.5
+1: int key
+true: bool key
+~: null key
+created: 2024-01-02T03:04:05Z
+logo: !!binary |
+  R0lGODlhDAAMAIQAAP
+  //9/X17unp5WZmZgAA
+list: [b, a]
+`
+	out, err := YAMLToJSON([]byte(input))
+	require.NoError(t, err)
+	assert.Equal(t, `{"name":"demo","count":3,"ratio":0.5,"1":"int key","true":"bool key","null":"null key","created":"2024-01-02T03:04:05Z","logo":"R0lGODlhDAAMAIQAAP//9/X17unp5WZmZgAA","list":["b","a"]}`, string(out))
+
+	// lossy conversions are errors:
+	table := []string{
+		"1: a\n'1': b\n",
+		"? [a, b]\n: complex\n",
+		"big: 9007199254740993\n",
+		"nan: .nan\n",
+	}
+	for _, input := range table {
+		_, err := YAMLToJSON([]byte(input))
+		assert.ErrorContains(t, err, "NormalizeLossy", "input: %q", input)
+	}
+
+	_, err = YAMLToJSON([]byte("a: [\n"))
+	assert.Error(t, err)
+}
+
+func TestJSONToYAML(t *testing.T) {
+	input := `{"z":1,"a":{"y":2.5,"b":[1,"2",true,null]},"yes":"no","e":1e3}`
+	out, err := JSONToYAML([]byte(input))
+	require.NoError(t, err)
+
+	expected := `z: 1
+a:
+  "y": 2.5
+  b:
+    - 1
+    - "2"
+    - true
+    - null
+"yes": "no"
+e: 1000.0
+`
+	assert.Equal(t, expected, string(out))
+
+	// round trip:
+	back, err := YAMLToJSON(out)
+	require.NoError(t, err)
+	assert.Equal(t, `{"z":1,"a":{"y":2.5,"b":[1,"2",true,null]},"yes":"no","e":1000}`, string(back))
+
+	_, err = JSONToYAML([]byte(`{"a":`))
+	assert.Error(t, err)
+}
+
+func TestJSONToYAML_Numbers(t *testing.T) {
+	// integers keep their precision, floats stay floats:
+	out, err := JSONToYAML([]byte(`{"id":9007199254740993,"big":18446744073709551615,"neg":-9007199254740993,"f":2.0,"z":-0.0,"r":0.1}`))
+	require.NoError(t, err)
+	expected := `id: 9007199254740993
+big: 18446744073709551615
+neg: -9007199254740993
+f: 2.0
+z: -0.0
+r: 0.1
+`
+	assert.Equal(t, expected, string(out))
+
+	var back Any
+	require.NoError(t, yaml.Unmarshal(out, &back))
+	m := back.V().(*Map[any, any])
+	assert.Equal(t, 9007199254740993, m.Get("id"))
+	assert.Equal(t, uint64(math.MaxUint64), m.Get("big"))
+	assert.Equal(t, 2.0, m.Get("f"))
+	assert.True(t, math.Signbit(m.Get("z").(float64)))
+
+	// numbers which can not be represented exactly are an error:
+	for _, input := range []string{`{"id":9007199254740993.0}`, `[1e400]`, `[123456789012345678901234567890]`} {
+		_, err = JSONToYAML([]byte(input))
+		assert.ErrorContains(t, err, "NormalizeLossy", "input: %s", input)
+	}
+
+	// unless lossy conversions are allowed:
+	y, err := NewAny(json.Number("9007199254740993.0")).Normalize(NormalizeYAML | NormalizeLossy)
+	require.NoError(t, err)
+	assert.Equal(t, 9007199254740992.0, y.V())
+
+	y, err = NewAny([]any{json.Number("-0.0"), json.Number("7"), json.Number("1e3"), math.Copysign(0, -1), 2.0}).Normalize(NormalizeYAML)
+	require.NoError(t, err)
+	values := y.V().([]any)
+	assert.True(t, math.Signbit(values[0].(float64)))
+	assert.Equal(t, 7, values[1])
+	assert.Equal(t, 1000.0, values[2])
+	assert.True(t, math.Signbit(values[3].(float64)))
+	assert.Equal(t, 2, values[4])
+}
+
+func TestAnyNormalize(t *testing.T) {
+	root := &Map[any, any]{}
+	root.Set(2, "b")
+	root.Set("2", "collides")
+	root.Set(1.5, math.Inf(1))
+	root.Set("when", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	root.Set("bytes", []byte("hi"))
+	root.Set("invalid", "a\xffb")
+	root.Set("big", uint64(math.MaxUint64))
+	x := NewAny(root)
+
+	_, err := x.Normalize(NormalizeJSON)
+	assert.ErrorContains(t, err, `"2"`)
+
+	n, err := x.Normalize(NormalizeJSON | NormalizeLossy)
+	require.NoError(t, err)
+	m := n.V().(*Map[string, any])
+	assert.Equal(t, []string{"2", "1.5", "when", "bytes", "invalid", "big"}, m.Keys())
+	assert.Equal(t, "b", m.Get("2"))
+	assert.Nil(t, m.Get("1.5"))
+	assert.Equal(t, "2024-01-02T03:04:05Z", m.Get("when"))
+	assert.Equal(t, "aGk=", m.Get("bytes"))
+	assert.Equal(t, "a\uFFFDb", m.Get("invalid"))
+	assert.Equal(t, float64(math.MaxUint64), m.Get("big"))
+
+	// json shaped values to yaml shaped values:
+	j := mustAny(t, `{"a":1,"b":{"c":[2.5,3]}}`)
+	y, err := j.Normalize(NormalizeYAML)
+	require.NoError(t, err)
+	ym := y.V().(*Map[any, any])
+	assert.Equal(t, []any{"a", "b"}, ym.Keys())
+	assert.Equal(t, 1, ym.Get("a"))
+	assert.Equal(t, []any{2.5, 3}, ym.Get("b").(*Map[any, any]).Get("c"))
+
+	// the original is untouched:
+	assert.Equal(t, 1.0, j.V().(*Map[string, any]).Get("a"))
+
+	_, err = j.Normalize(NormalizeJSON | NormalizeYAML)
+	assert.Error(t, err)
+}
@@ -0,0 +1,201 @@
+package ordmap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PathElem is a step in a Path: either a key in an object, or an index in an array.
+type PathElem struct {
+	key     any
+	index   int
+	isIndex bool
+}
+
+// KeyElem returns a PathElem which designates the entry 'key' of an object.
+func KeyElem(key any) PathElem {
+	return PathElem{key: key}
+}
+
+// IndexElem returns a PathElem which designates the element at index 'i' of an array.
+func IndexElem(i int) PathElem {
+	return PathElem{index: i, isIndex: true}
+}
+
+func (e PathElem) IsIndex() bool {
+	return e.isIndex
+}
+
+// Key returns the key of an object entry, or nil if 'e' is an array index.
+func (e PathElem) Key() any {
+	return e.key
+}
+
+// Index returns the array index, or -1 if 'e' is an object key.
+func (e PathElem) Index() int {
+	if !e.isIndex {
+		return -1
+	}
+	return e.index
+}
+
+// token
+//
+// returns the representation of 'e' as a string (an index is formatted as a decimal number).
+func (e PathElem) token() string {
+	if e.isIndex {
+		return strconv.Itoa(e.index)
+	}
+	if s, ok := e.key.(string); ok {
+		return s
+	}
+	return fmt.Sprint(e.key)
+}
+
+// Path designates a location in a tree of values, such as the content of an Any.
+type Path []PathElem
+
+// Key returns a new Path, with the object key 'key' appended to 'p'.
+func (p Path) Key(key any) Path {
+	return p.append(KeyElem(key))
+}
+
+// Index returns a new Path, with the array index 'i' appended to 'p'.
+func (p Path) Index(i int) Path {
+	return p.append(IndexElem(i))
+}
+
+func (p Path) append(e PathElem) Path {
+	res := make(Path, len(p), len(p)+1)
+	copy(res, p)
+	return append(res, e)
+}
+
+// Parent returns the path to the parent of 'p'; the parent of the empty path is the empty path.
+func (p Path) Parent() Path {
+	if len(p) == 0 {
+		return p
+	}
+	return p[: len(p)-1 : len(p)-1]
+}
+
+// JSONPointer returns the representation of 'p' as a JSON Pointer (RFC 6901), e.g: "/spec/ports/0/name".
+func (p Path) JSONPointer() string {
+	var sb strings.Builder
+	for _, e := range p {
+		sb.WriteByte('/')
+		sb.WriteString(pointerEscaper.Replace(e.token()))
+	}
+	return sb.String()
+}
+
+// String returns the representation of 'p' in dotted notation, e.g: "spec.ports[0].name".
+//
+// Keys which are not made only of letters, digits, '_' and '-' are quoted: `labels["app.kubernetes.io/name"]`.
+func (p Path) String() string {
+	var sb strings.Builder
+	for i, e := range p {
+		if e.isIndex {
+			sb.WriteByte('[')
+			sb.WriteString(strconv.Itoa(e.index))
+			sb.WriteByte(']')
+			continue
+		}
+
+		tok := e.token()
+		if !isPlainPathKey(tok) {
+			sb.WriteByte('[')
+			sb.WriteString(strconv.Quote(tok))
+			sb.WriteByte(']')
+			continue
+		}
+		if i > 0 {
+			sb.WriteByte('.')
+		}
+		sb.WriteString(tok)
+	}
+	return sb.String()
+}
+
+func isPlainPathKey(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// ParseJSONPointer parses a JSON Pointer (RFC 6901).
+//
+// All elements of the returned Path are string keys, since a JSON Pointer does not tell apart
+// object keys and array indices. Functions of this package which follow a Path in a document
+// accept a string key such as "0" to designate an element of an array.
+func ParseJSONPointer(ptr string) (Path, error) {
+	toks, err := parseJSONPointer(ptr)
+	if err != nil {
+		return nil, err
+	}
+	res := make(Path, len(toks))
+	for i, tok := range toks {
+		res[i] = KeyElem(tok)
+	}
+	return res, nil
+}
+
+// ParsePath parses a path in dotted notation, as returned by `Path.String()`.
+func ParsePath(s string) (Path, error) {
+	var res Path
+	i := 0
+	for i < len(s) {
+		switch {
+		case s[i] == '[':
+			end := strings.IndexByte(s[i:], ']')
+			if i+1 < len(s) && s[i+1] == '"' {
+				quoted, err := strconv.QuotedPrefix(s[i+1:])
+				if err != nil {
+					return nil, fmt.Errorf("invalid path %q: %w", s, err)
+				}
+				end = 1 + len(quoted)
+				if i+end >= len(s) || s[i+end] != ']' {
+					return nil, fmt.Errorf("invalid path %q: expected ']' at offset %d", s, i+end)
+				}
+				key, _ := strconv.Unquote(quoted)
+				res = append(res, KeyElem(key))
+				i += end + 1
+				continue
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: missing ']'", s)
+			}
+			idx, err := strconv.Atoi(s[i+1 : i+end])
+			if err != nil || idx < 0 {
+				return nil, fmt.Errorf("invalid path %q: invalid index %q", s, s[i+1:i+end])
+			}
+			res = append(res, IndexElem(idx))
+			i += end + 1
+
+		case s[i] == '.' && i > 0:
+			i++
+			fallthrough
+
+		default:
+			j := i
+			for j < len(s) && s[j] != '.' && s[j] != '[' {
+				j++
+			}
+			if j == i {
+				return nil, fmt.Errorf("invalid path %q: empty key at offset %d", s, i)
+			}
+			res = append(res, KeyElem(s[i:j]))
+			i = j
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,68 @@
+package ordmap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPath(t *testing.T) {
+	p := Path{}.Key("spec").Key("ports").Index(0).Key("name")
+	assert.Equal(t, "/spec/ports/0/name", p.JSONPointer())
+	assert.Equal(t, "spec.ports[0].name", p.String())
+	assert.Equal(t, "spec.ports[0]", p.Parent().String())
+
+	p = Path{}.Key("metadata").Key("labels").Key("app.kubernetes.io/name")
+	assert.Equal(t, "/metadata/labels/app.kubernetes.io~1name", p.JSONPointer())
+	assert.Equal(t, `metadata.labels["app.kubernetes.io/name"]`, p.String())
+
+	assert.Equal(t, "", Path{}.JSONPointer())
+	assert.Equal(t, "", Path{}.String())
+	assert.Equal(t, "[2][3]", Path{}.Index(2).Index(3).String())
+	assert.Equal(t, `["a~b"].12`, Path{}.Key("a~b").Key(12).String())
+
+	// appending to a path does not modify it:
+	base := Path{}.Key("a")
+	p1 := base.Key("b")
+	p2 := base.Key("c")
+	assert.Equal(t, "a.b", p1.String())
+	assert.Equal(t, "a.c", p2.String())
+}
+
+func TestParsePath(t *testing.T) {
+	table := []string{
+		"",
+		"a",
+		"spec.ports[0].name",
+		`metadata.labels["app.kubernetes.io/name"]`,
+		`[1][2].x["\"quoted\""]`,
+	}
+	for _, s := range table {
+		p, err := ParsePath(s)
+		require.NoError(t, err, "path: %s", s)
+		assert.Equal(t, s, p.String())
+	}
+
+	p, err := ParsePath("a[3]")
+	require.NoError(t, err)
+	assert.Equal(t, Path{KeyElem("a"), IndexElem(3)}, p)
+	assert.True(t, p[1].IsIndex())
+	assert.Equal(t, 3, p[1].Index())
+	assert.Equal(t, -1, p[0].Index())
+
+	for _, s := range []string{".a", "a..b", "a[", "a[x]", "a[-1]", `a["x"`, `a["x]`} {
+		_, err := ParsePath(s)
+		assert.Error(t, err, "path: %s", s)
+	}
+}
+
+func TestParseJSONPointer(t *testing.T) {
+	p, err := ParseJSONPointer("/a~1b/0/m~0n")
+	require.NoError(t, err)
+	assert.Equal(t, Path{KeyElem("a/b"), KeyElem("0"), KeyElem("m~n")}, p)
+	assert.Equal(t, "/a~1b/0/m~0n", p.JSONPointer())
+
+	_, err = ParseJSONPointer("a")
+	assert.Error(t, err)
+}
@@ -0,0 +1,992 @@
+package ordmap
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DecodeOptions controls how `Any.DecodeIntoWith()` maps values on go types.
+type DecodeOptions struct {
+	// WeaklyTyped enables conversions between scalar types:
+	//   - strings are parsed into numbers and booleans,
+	//   - numbers and booleans are formatted into strings,
+	//   - numbers are converted to booleans (non zero is true), and booleans to 0 or 1,
+	//   - a single value is accepted where a slice is expected.
+	WeaklyTyped bool
+
+	// ErrorUnused makes the decoding fail when an object has a key which matches
+	// no field of the target struct.
+	ErrorUnused bool
+
+	// Hook, if set, is called on each value before it is decoded into a target of type 'target'.
+	// The value it returns is decoded in place of the original value.
+	Hook func(value any, target reflect.Type) (any, error)
+}
+
+// DecodeInto stores the content of 'x' in the value pointed to by 'target'.
+//
+// Objects can be decoded into structs (honoring `json` and `yaml` tags, with a fallback
+// to a case insensitive match of the field name), go maps, or `Map` values.
+// See `DecodeIntoWith()` for options.
+func (x Any) DecodeInto(target any) error {
+	return x.DecodeIntoWith(target, DecodeOptions{})
+}
+
+// DecodeIntoWith is the same as `DecodeInto()`, with options.
+func (x Any) DecodeIntoWith(target any, opts DecodeOptions) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("error when decoding: target should be a non nil pointer, got %T", target)
+	}
+
+	d := &anyDecoder{opts: opts}
+	return d.decode("", x.v, rv.Elem())
+}
+
+// anyDecodable is implemented by *Map[K, V], so that the decoder can fill a Map
+// without knowing its type parameters.
+type anyDecodable interface {
+	decodeAny(d *anyDecoder, where string, v any) error
+}
+
+// anyMap is implemented by *Map[K, V], to iterate over a Map without knowing its type parameters.
+type anyMap interface {
+	Len() int
+	eachAny(fn func(key, value any) error) error
+}
+
+func (m *Map[K, V]) eachAny(fn func(key, value any) error) error {
+	for _, k := range m.keys {
+		err := fn(k, m.m[k])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Map[K, V]) decodeAny(d *anyDecoder, where string, v any) error {
+	res := Map[K, V]{}
+	isObject, err := forEachEntry(v, func(k, val any) error {
+		var key K
+		err := d.decodeKey(where, k, reflect.ValueOf(&key).Elem())
+		if err != nil {
+			return err
+		}
+		var value V
+		err = d.decode(fmt.Sprintf("%s.%v", where, k), val, reflect.ValueOf(&value).Elem())
+		if err != nil {
+			return err
+		}
+		res.Set(key, value)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !isObject {
+		return decodeTypeError(where, v, reflect.TypeOf(m).Elem())
+	}
+
+	*m = res
+	return nil
+}
+
+// forEachEntry
+//
+// calls 'fn' on each key/value pair of 'v' if 'v' represents an object, and returns false otherwise.
+// Entries of a go map are visited in the order of their sorted keys.
+func forEachEntry(v any, fn func(k, v any) error) (bool, error) {
+	if m, ok := v.(anyMap); ok {
+		if reflect.ValueOf(v).IsNil() {
+			return true, nil
+		}
+		return true, m.eachAny(fn)
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Map {
+		return false, nil
+	}
+	keys := rv.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+	for _, k := range keys {
+		err := fn(k.Interface(), rv.MapIndex(k).Interface())
+		if err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+type anyDecoder struct {
+	opts DecodeOptions
+}
+
+var (
+	anyType             = reflect.TypeOf(Any{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+func decodeTypeError(where string, v any, t reflect.Type) error {
+	if where == "" {
+		return fmt.Errorf("error when decoding: cannot decode %T into %v", v, t)
+	}
+	return fmt.Errorf("error when decoding %s: cannot decode %T into %v", where, v, t)
+}
+
+func (d *anyDecoder) decode(where string, v any, rv reflect.Value) error {
+	if d.opts.Hook != nil {
+		var err error
+		v, err = d.opts.Hook(v, rv.Type())
+		if err != nil {
+			if where == "" {
+				return fmt.Errorf("error when decoding: %w", err)
+			}
+			return fmt.Errorf("error when decoding %s: %w", where, err)
+		}
+	}
+	return d.decodeValue(where, v, rv)
+}
+
+func (d *anyDecoder) decodeValue(where string, v any, rv reflect.Value) error {
+	switch x := v.(type) {
+	case Any:
+		v = x.v
+	case *Any:
+		v = nil
+		if x != nil {
+			v = x.v
+		}
+	case *YAMLScalar:
+		v = nil
+		if x != nil {
+			v = x.Value
+		}
+	}
+
+	t := rv.Type()
+
+	if t == anyType {
+		rv.Set(reflect.ValueOf(Any{v: v}))
+		return nil
+	}
+
+	if v == nil {
+		rv.Set(reflect.Zero(t))
+		return nil
+	}
+
+	if t.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			rv.Set(reflect.New(t.Elem()))
+		}
+		return d.decodeValue(where, v, rv.Elem())
+	}
+
+	vt := reflect.TypeOf(v)
+	if vt.AssignableTo(t) {
+		rv.Set(reflect.ValueOf(v))
+		return nil
+	}
+
+	if rv.CanAddr() {
+		addr := rv.Addr()
+		if dec, ok := addr.Interface().(anyDecodable); ok {
+			return dec.decodeAny(d, where, v)
+		}
+		if s, ok := v.(string); ok && addr.Type().Implements(textUnmarshalerType) {
+			err := addr.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+			if err != nil {
+				return fmt.Errorf("error when decoding %s: %w", where, err)
+			}
+			return nil
+		}
+		if addr.Type().Implements(jsonUnmarshalerType) {
+			bs, err := json.Marshal(v)
+			if err != nil {
+				return fmt.Errorf("error when decoding %s: %w", where, err)
+			}
+			err = addr.Interface().(json.Unmarshaler).UnmarshalJSON(bs)
+			if err != nil {
+				return fmt.Errorf("error when decoding %s: %w", where, err)
+			}
+			return nil
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		if !vt.Implements(t) {
+			return decodeTypeError(where, v, t)
+		}
+		rv.Set(reflect.ValueOf(v))
+		return nil
+
+	case reflect.Bool:
+		b, ok := d.toBool(v)
+		if !ok {
+			return decodeTypeError(where, v, t)
+		}
+		rv.SetBool(b)
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := d.toInt64(v)
+		if !ok || rv.OverflowInt(n) {
+			return decodeTypeError(where, v, t)
+		}
+		rv.SetInt(n)
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, ok := d.toUint64(v)
+		if !ok || rv.OverflowUint(n) {
+			return decodeTypeError(where, v, t)
+		}
+		rv.SetUint(n)
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		f, ok := d.toFloat64(v)
+		if !ok || rv.OverflowFloat(f) {
+			return decodeTypeError(where, v, t)
+		}
+		rv.SetFloat(f)
+		return nil
+
+	case reflect.String:
+		s, ok := d.toString(v)
+		if !ok {
+			return decodeTypeError(where, v, t)
+		}
+		rv.SetString(s)
+		return nil
+
+	case reflect.Slice, reflect.Array:
+		return d.decodeSlice(where, v, rv)
+
+	case reflect.Map:
+		return d.decodeMap(where, v, rv)
+
+	case reflect.Struct:
+		return d.decodeStruct(where, v, rv)
+	}
+
+	return decodeTypeError(where, v, t)
+}
+
+func (d *anyDecoder) decodeSlice(where string, v any, rv reflect.Value) error {
+	t := rv.Type()
+
+	if s, ok := v.(string); ok && t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+		bs, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return fmt.Errorf("error when decoding %s: %w", where, err)
+		}
+		rv.SetBytes(bs)
+		return nil
+	}
+
+	src := reflect.ValueOf(v)
+	if src.Kind() != reflect.Slice && src.Kind() != reflect.Array {
+		if !d.opts.WeaklyTyped {
+			return decodeTypeError(where, v, t)
+		}
+		src = reflect.ValueOf([]any{v})
+	}
+
+	n := src.Len()
+	if t.Kind() == reflect.Array {
+		if n > t.Len() {
+			return fmt.Errorf("error when decoding %s: too many elements (%d) for %v", where, n, t)
+		}
+		rv.Set(reflect.Zero(t))
+	} else {
+		rv.Set(reflect.MakeSlice(t, n, n))
+	}
+
+	for i := 0; i < n; i++ {
+		err := d.decode(fmt.Sprintf("%s[%d]", where, i), src.Index(i).Interface(), rv.Index(i))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *anyDecoder) decodeMap(where string, v any, rv reflect.Value) error {
+	t := rv.Type()
+	res := reflect.MakeMap(t)
+	isObject, err := forEachEntry(v, func(k, val any) error {
+		key := reflect.New(t.Key()).Elem()
+		err := d.decodeKey(where, k, key)
+		if err != nil {
+			return err
+		}
+		elt := reflect.New(t.Elem()).Elem()
+		err = d.decode(fmt.Sprintf("%s.%v", where, k), val, elt)
+		if err != nil {
+			return err
+		}
+		res.SetMapIndex(key, elt)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !isObject {
+		return decodeTypeError(where, v, t)
+	}
+	rv.Set(res)
+	return nil
+}
+
+// decodeKey
+//
+// decodes an object key; as with `encoding/json`, numeric keys can be parsed from strings.
+func (d *anyDecoder) decodeKey(where string, k any, rv reflect.Value) error {
+	s, ok := k.(string)
+	if !ok {
+		return d.decode(where+" (key)", k, rv)
+	}
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		if !rv.Addr().Type().Implements(textUnmarshalerType) {
+			weak := &anyDecoder{opts: d.opts}
+			weak.opts.WeaklyTyped = true
+			return weak.decode(fmt.Sprintf("%s (key %q)", where, s), s, rv)
+		}
+	}
+	return d.decode(fmt.Sprintf("%s (key %q)", where, s), s, rv)
+}
+
+func (d *anyDecoder) decodeStruct(where string, v any, rv reflect.Value) error {
+	t := rv.Type()
+	fields := cachedStructFields(t)
+
+	isObject, err := forEachEntry(v, func(k, val any) error {
+		name, ok := k.(string)
+		if !ok {
+			name = fmt.Sprint(k)
+		}
+
+		f := fields.lookup(name)
+		if f == nil {
+			if d.opts.ErrorUnused {
+				return fmt.Errorf("error when decoding %s: unknown field %q in %v", where, name, t)
+			}
+			return nil
+		}
+
+		fv, err := fieldByIndexAlloc(rv, f.index)
+		if err != nil {
+			return fmt.Errorf("error when decoding %s.%s: %w", where, name, err)
+		}
+		return d.decode(where+"."+name, val, fv)
+	})
+	if err != nil {
+		return err
+	}
+	if !isObject {
+		return decodeTypeError(where, v, t)
+	}
+	return nil
+}
+
+// fieldByIndexAlloc
+//
+// same as reflect.Value.FieldByIndex, but allocates nil pointers to embedded structs.
+func fieldByIndexAlloc(rv reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && rv.Kind() == reflect.Pointer {
+			if rv.IsNil() {
+				if !rv.CanSet() {
+					return reflect.Value{}, fmt.Errorf("cannot set embedded pointer to unexported struct %v", rv.Type().Elem())
+				}
+				rv.Set(reflect.New(rv.Type().Elem()))
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(x)
+	}
+	return rv, nil
+}
+
+func (d *anyDecoder) toBool(v any) (bool, bool) {
+	switch x := v.(type) {
+	case bool:
+		return x, true
+	case string:
+		if d.opts.WeaklyTyped {
+			b, err := strconv.ParseBool(x)
+			return b, err == nil
+		}
+		return false, false
+	}
+	if d.opts.WeaklyTyped {
+		if f, ok := jsonNumber(v); ok {
+			return f != 0, true
+		}
+	}
+	return false, false
+}
+
+func (d *anyDecoder) toInt64(v any) (int64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := rv.Uint()
+		return int64(u), u <= math.MaxInt64
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return 0, false
+		}
+		return int64(f), true
+	}
+
+	switch x := v.(type) {
+	case json.Number:
+		n, err := x.Int64()
+		return n, err == nil
+	case string:
+		if d.opts.WeaklyTyped {
+			n, err := strconv.ParseInt(strings.TrimSpace(x), 0, 64)
+			return n, err == nil
+		}
+	case bool:
+		if d.opts.WeaklyTyped {
+			if x {
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+func (d *anyDecoder) toUint64(v any) (uint64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := rv.Int()
+		return uint64(n), n >= 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint(), true
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if f != math.Trunc(f) || f < 0 || f >= math.MaxUint64 {
+			return 0, false
+		}
+		return uint64(f), true
+	}
+
+	switch x := v.(type) {
+	case json.Number:
+		n, err := strconv.ParseUint(string(x), 10, 64)
+		return n, err == nil
+	case string:
+		if d.opts.WeaklyTyped {
+			n, err := strconv.ParseUint(strings.TrimSpace(x), 0, 64)
+			return n, err == nil
+		}
+	case bool:
+		if d.opts.WeaklyTyped {
+			if x {
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+func (d *anyDecoder) toFloat64(v any) (float64, bool) {
+	if f, ok := jsonNumber(v); ok {
+		return f, true
+	}
+	if !d.opts.WeaklyTyped {
+		return 0, false
+	}
+	switch x := v.(type) {
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		return f, err == nil
+	case bool:
+		if x {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func (d *anyDecoder) toString(v any) (string, bool) {
+	if s, ok := v.(string); ok {
+		return s, true
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.String {
+		return rv.String(), true
+	}
+	if !d.opts.WeaklyTyped {
+		return "", false
+	}
+
+	switch x := v.(type) {
+	case bool:
+		return strconv.FormatBool(x), true
+	case json.Number:
+		return string(x), true
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64), true
+	case float32:
+		return strconv.FormatFloat(float64(x), 'f', -1, 32), true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(rv.Uint(), 10), true
+	}
+	return "", false
+}
+
+// FromStruct converts 'v' to an Any, where structs are represented as `*Map[string, any]`
+// with their keys in the order of the declaration of the fields.
+//
+// Fields are named after their `json` tag (or `yaml` tag if there is no `json` tag),
+// fields tagged with "-" are skipped, "omitempty" is honored, and the fields of embedded
+// structs are promoted in place of the embedded field, following the rules of `encoding/json`.
+//
+// Go maps are converted to `*Map[string, any]` with sorted keys, slices to `[]any`.
+// Values which implement `json.Marshaler` or `encoding.TextMarshaler` are kept as is.
+func FromStruct(v any) (Any, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return Any{}, fmt.Errorf("error when converting struct: expected a struct, got %T", v)
+	}
+
+	res, err := toAnyValue("", rv)
+	if err != nil {
+		return Any{}, err
+	}
+	return Any{v: res}, nil
+}
+
+var (
+	anyMapType        = reflect.TypeOf((*anyMap)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+func toAnyValue(where string, rv reflect.Value) (any, error) {
+	if !rv.IsValid() {
+		return nil, nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+		if rv.IsNil() {
+			return nil, nil
+		}
+	}
+
+	if rv.Type() == anyType {
+		return rv.Interface().(Any).v, nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Interface:
+	case reflect.Pointer:
+		if m, ok := rv.Interface().(anyMap); ok {
+			return mapToAnyValue(where, m)
+		}
+	default:
+		if reflect.PointerTo(rv.Type()).Implements(anyMapType) {
+			p := reflect.New(rv.Type())
+			p.Elem().Set(rv)
+			return mapToAnyValue(where, p.Interface().(anyMap))
+		}
+	}
+	if rv.Kind() != reflect.Interface && (rv.Type().Implements(jsonMarshalerType) || rv.Type().Implements(textMarshalerType)) {
+		return rv.Interface(), nil
+	}
+	if rv.Kind() != reflect.Interface && rv.Kind() != reflect.Pointer {
+		// marshalers with a pointer receiver are used as well, on the value or on a copy of it:
+		pt := reflect.PointerTo(rv.Type())
+		if pt.Implements(jsonMarshalerType) || pt.Implements(textMarshalerType) {
+			if rv.CanAddr() {
+				return rv.Addr().Interface(), nil
+			}
+			p := reflect.New(rv.Type())
+			p.Elem().Set(rv)
+			return p.Interface(), nil
+		}
+	}
+
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return toAnyValue(where, rv.Elem())
+
+	case reflect.Struct:
+		res := &Map[string, any]{}
+		for _, f := range cachedStructFields(rv.Type()).list {
+			fv, ok := fieldByIndex(rv, f.index)
+			if !ok {
+				continue
+			}
+			if f.omitEmpty && isEmptyValue(fv) {
+				continue
+			}
+			v, err := toAnyValue(where+"."+f.name, fv)
+			if err != nil {
+				return nil, err
+			}
+			res.Set(f.name, v)
+		}
+		return res, nil
+
+	case reflect.Map:
+		keys := make([]string, 0, rv.Len())
+		values := make(map[string]reflect.Value, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			k, err := mapKeyString(iter.Key())
+			if err != nil {
+				return nil, fmt.Errorf("error when converting %s: %w", where, err)
+			}
+			keys = append(keys, k)
+			values[k] = iter.Value()
+		}
+		sort.Strings(keys)
+
+		res := &Map[string, any]{}
+		for _, k := range keys {
+			v, err := toAnyValue(where+"."+k, values[k])
+			if err != nil {
+				return nil, err
+			}
+			res.Set(k, v)
+		}
+		return res, nil
+
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			bs := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(bs), rv)
+			return bs, nil
+		}
+		res := make([]any, rv.Len())
+		for i := range res {
+			v, err := toAnyValue(fmt.Sprintf("%s[%d]", where, i), rv.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			res[i] = v
+		}
+		return res, nil
+
+	case reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+		return nil, fmt.Errorf("error when converting %s: unsupported type %v", where, rv.Type())
+	}
+
+	return rv.Interface(), nil
+}
+
+func mapToAnyValue(where string, m anyMap) (any, error) {
+	strKeys := &Map[string, any]{}
+	anyKeys := &Map[any, any]{}
+	allStrings := true
+	err := m.eachAny(func(k, v any) error {
+		key := fmt.Sprint(k)
+		x, err := toAnyValue(where+"."+key, reflect.ValueOf(v))
+		if err != nil {
+			return err
+		}
+		if s, ok := k.(string); ok && allStrings {
+			strKeys.Set(s, x)
+		} else {
+			allStrings = false
+		}
+		anyKeys.Set(k, x)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if allStrings {
+		return strKeys, nil
+	}
+	return anyKeys, nil
+}
+
+// mapKeyString
+//
+// formats the key of a go map the same way `encoding/json` does.
+func mapKeyString(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		bs, err := tm.MarshalText()
+		return string(bs), err
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("unsupported map key type %v", k.Type())
+}
+
+// fieldByIndex
+//
+// same as reflect.Value.FieldByIndex, but returns false instead of panicking on nil embedded pointers.
+func fieldByIndex(rv reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && rv.Kind() == reflect.Pointer {
+			if rv.IsNil() {
+				return reflect.Value{}, false
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(x)
+	}
+	return rv, true
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+	return false
+}
+
+// structField
+//
+// describes how a field of a struct is named when converted from/to an object.
+type structField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+	tagged    bool
+}
+
+type structFields struct {
+	list   []structField
+	byName map[string]int
+	byFold map[string]int
+}
+
+func (fs *structFields) lookup(name string) *structField {
+	if i, ok := fs.byName[name]; ok {
+		return &fs.list[i]
+	}
+	if i, ok := fs.byFold[strings.ToLower(name)]; ok {
+		return &fs.list[i]
+	}
+	return nil
+}
+
+var fieldCache sync.Map // map[reflect.Type]*structFields
+
+func cachedStructFields(t reflect.Type) *structFields {
+	if fs, ok := fieldCache.Load(t); ok {
+		return fs.(*structFields)
+	}
+	fs, _ := fieldCache.LoadOrStore(t, computeStructFields(t))
+	return fs.(*structFields)
+}
+
+// fieldTag
+//
+// returns the name and options of a field, read from its `json` tag, or from its `yaml` tag.
+func fieldTag(f reflect.StructField) (name string, opts []string, skip bool) {
+	tag, ok := f.Tag.Lookup("json")
+	if !ok {
+		tag, ok = f.Tag.Lookup("yaml")
+	}
+	if !ok {
+		return "", nil, false
+	}
+	if tag == "-" {
+		return "", nil, true
+	}
+	parts := strings.Split(tag, ",")
+	return parts[0], parts[1:], false
+}
+
+func hasOption(opts []string, opt string) bool {
+	for _, o := range opts {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// computeStructFields
+//
+// lists the fields of a struct, with fields of embedded structs promoted using
+// the same rules as `encoding/json`. The fields are sorted in declaration order.
+func computeStructFields(t reflect.Type) *structFields {
+	type queued struct {
+		typ   reflect.Type
+		index []int
+	}
+
+	var candidates []structField
+	current := []queued{{typ: t}}
+	visited := map[reflect.Type]bool{}
+
+	for len(current) > 0 {
+		var next []queued
+		for _, q := range current {
+			if visited[q.typ] {
+				continue
+			}
+			visited[q.typ] = true
+
+			for i := 0; i < q.typ.NumField(); i++ {
+				f := q.typ.Field(i)
+				ft := f.Type
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+
+				if f.Anonymous {
+					if !f.IsExported() && ft.Kind() != reflect.Struct {
+						continue
+					}
+				} else if !f.IsExported() {
+					continue
+				}
+
+				name, opts, skip := fieldTag(f)
+				if skip {
+					continue
+				}
+
+				index := make([]int, len(q.index)+1)
+				copy(index, q.index)
+				index[len(q.index)] = i
+
+				if ft.Kind() == reflect.Struct && ((f.Anonymous && name == "") || hasOption(opts, "inline")) {
+					next = append(next, queued{typ: ft, index: index})
+					continue
+				}
+
+				tagged := name != ""
+				if name == "" {
+					name = f.Name
+				}
+				candidates = append(candidates, structField{
+					name:      name,
+					index:     index,
+					omitEmpty: hasOption(opts, "omitempty"),
+					tagged:    tagged,
+				})
+			}
+		}
+		current = next
+	}
+
+	// resolve conflicts: the shallowest field wins, then the tagged field;
+	// if it is still ambiguous, all fields with that name are dropped.
+	byName := map[string][]structField{}
+	for _, f := range candidates {
+		byName[f.name] = append(byName[f.name], f)
+	}
+
+	var list []structField
+	for _, fs := range byName {
+		if f, ok := dominantField(fs); ok {
+			list = append(list, f)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i].index, list[j].index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+
+	res := &structFields{
+		list:   list,
+		byName: make(map[string]int, len(list)),
+		byFold: make(map[string]int, len(list)),
+	}
+	for i, f := range list {
+		res.byName[f.name] = i
+		if _, ok := res.byFold[strings.ToLower(f.name)]; !ok {
+			res.byFold[strings.ToLower(f.name)] = i
+		}
+	}
+	return res
+}
+
+func dominantField(fs []structField) (structField, bool) {
+	depth := len(fs[0].index)
+	for _, f := range fs {
+		if len(f.index) < depth {
+			depth = len(f.index)
+		}
+	}
+
+	var shallowest []structField
+	for _, f := range fs {
+		if len(f.index) == depth {
+			shallowest = append(shallowest, f)
+		}
+	}
+	if len(shallowest) == 1 {
+		return shallowest[0], true
+	}
+
+	var tagged []structField
+	for _, f := range shallowest {
+		if f.tagged {
+			tagged = append(tagged, f)
+		}
+	}
+	if len(tagged) == 1 {
+		return tagged[0], true
+	}
+	return structField{}, false
+}
@@ -0,0 +1,284 @@
+package ordmap
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+type testServer struct {
+	Host    string            `json:"host"`
+	Port    int               `json:"port,omitempty"`
+	Tags    []string          `json:"tags,omitempty"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Started time.Time         `json:"started"`
+}
+
+type testMeta struct {
+	Name    string `yaml:"name"`
+	Comment string `json:"-"`
+}
+
+type testConfig struct {
+	testMeta
+	Version  float64                   `json:"version"`
+	Servers  []testServer              `json:"servers"`
+	Backup   *testServer               `json:"backup,omitempty"`
+	Settings Map[string, int]          `json:"settings"`
+	Extra    *Map[string, *testServer] `json:"extra,omitempty"`
+	Raw      Any                       `json:"raw"`
+	Enabled  bool
+}
+
+func TestAnyDecodeInto(t *testing.T) {
+	input := `{
+		"name": "prod",
+		"version": 2,
+		"servers": [
+			{"host": "a.example.com", "port": 80, "tags": ["x", "y"], "started": "2024-01-02T03:04:05Z"},
+			{"host": "b.example.com", "labels": {"zone": "eu"}}
+		],
+		"settings": {"z": 1, "a": 2, "m": 3},
+		"extra": {"second": {"host": "s"}, "first": {"host": "f"}},
+		"raw": {"y": 1, "x": [true]},
+		"ENABLED": true,
+		"unknown": 12
+	}`
+
+	x := mustAny(t, input)
+
+	var cfg testConfig
+	err := x.DecodeInto(&cfg)
+	require.NoError(t, err)
+
+	assert.Equal(t, "prod", cfg.Name)
+	assert.Equal(t, 2.0, cfg.Version)
+	require.Len(t, cfg.Servers, 2)
+	assert.Equal(t, testServer{
+		Host:    "a.example.com",
+		Port:    80,
+		Tags:    []string{"x", "y"},
+		Started: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}, cfg.Servers[0])
+	assert.Equal(t, map[string]string{"zone": "eu"}, cfg.Servers[1].Labels)
+	assert.Nil(t, cfg.Backup)
+	assert.Equal(t, []string{"z", "a", "m"}, cfg.Settings.Keys())
+	assert.Equal(t, 3, cfg.Settings.Get("m"))
+	assert.Equal(t, []string{"second", "first"}, cfg.Extra.Keys())
+	assert.Equal(t, "f", cfg.Extra.Get("first").Host)
+	assert.Equal(t, `{"y":1,"x":[true]}`, jsonMarshalString(t, cfg.Raw))
+	assert.True(t, cfg.Enabled)
+
+	err = x.DecodeIntoWith(&cfg, DecodeOptions{ErrorUnused: true})
+	assert.ErrorContains(t, err, "unknown")
+}
+
+func TestAnyDecodeInto_YAML(t *testing.T) {
+	input := `
+name: dev
+version: 1.5
+settings:
+  b: 1
+  a: 2
+servers:
+  - host: localhost
+    port: 8080
+`
+	var x Any
+	err := yaml.Unmarshal([]byte(input), &x)
+	require.NoError(t, err)
+
+	var cfg testConfig
+	err = x.DecodeInto(&cfg)
+	require.NoError(t, err)
+
+	assert.Equal(t, "dev", cfg.Name)
+	assert.Equal(t, 1.5, cfg.Version)
+	assert.Equal(t, []string{"b", "a"}, cfg.Settings.Keys())
+	assert.Equal(t, []testServer{{Host: "localhost", Port: 8080}}, cfg.Servers)
+
+	// decode into a Map with non string keys:
+	err = yaml.Unmarshal([]byte("3: c\n1: a\n2: b\n"), &x)
+	require.NoError(t, err)
+	var m Map[int, string]
+	err = x.DecodeInto(&m)
+	require.NoError(t, err)
+	assert.Equal(t, []int{3, 1, 2}, m.Keys())
+}
+
+func TestAnyDecodeInto_WeaklyTyped(t *testing.T) {
+	type Target struct {
+		Count   int      `json:"count"`
+		Ratio   float32  `json:"ratio"`
+		Enabled bool     `json:"enabled"`
+		Name    string   `json:"name"`
+		List    []string `json:"list"`
+		IDs     map[int]uint8
+	}
+
+	x := mustAny(t, `{"count":"42","ratio":"0.5","enabled":"true","name":12.5,"list":"single","IDs":{"1":"2"}}`)
+
+	var target Target
+	err := x.DecodeInto(&target)
+	assert.Error(t, err)
+
+	err = x.DecodeIntoWith(&target, DecodeOptions{WeaklyTyped: true})
+	require.NoError(t, err)
+	assert.Equal(t, Target{
+		Count:   42,
+		Ratio:   0.5,
+		Enabled: true,
+		Name:    "12.5",
+		List:    []string{"single"},
+		IDs:     map[int]uint8{1: 2},
+	}, target)
+
+	// hook: parse durations
+	type WithDuration struct {
+		Timeout time.Duration `json:"timeout"`
+	}
+	var wd WithDuration
+	hook := func(v any, target reflect.Type) (any, error) {
+		if s, ok := v.(string); ok && target == reflect.TypeOf(time.Duration(0)) {
+			return time.ParseDuration(s)
+		}
+		return v, nil
+	}
+	err = mustAny(t, `{"timeout":"1m30s"}`).DecodeIntoWith(&wd, DecodeOptions{Hook: hook})
+	require.NoError(t, err)
+	assert.Equal(t, 90*time.Second, wd.Timeout)
+}
+
+func TestAnyDecodeInto_Errors(t *testing.T) {
+	type Target struct {
+		Count uint8 `json:"count"`
+	}
+	table := []string{
+		`{"count":-1}`,
+		`{"count":256}`,
+		`{"count":1.5}`,
+		`{"count":"1"}`,
+		`{"count":{}}`,
+		`[1]`,
+	}
+	for _, input := range table {
+		var target Target
+		err := mustAny(t, input).DecodeInto(&target)
+		assert.Error(t, err, "input: %s", input)
+	}
+
+	var target Target
+	assert.Error(t, mustAny(t, `{}`).DecodeInto(target))
+}
+
+func TestFromStruct(t *testing.T) {
+	settings := Map[string, int]{}
+	settings.Set("z", 1)
+	settings.Set("a", 2)
+
+	cfg := testConfig{
+		testMeta: testMeta{Name: "prod", Comment: "not exported"},
+		Version:  2,
+		Servers: []testServer{
+			{Host: "a", Port: 80, Labels: map[string]string{"b": "2", "a": "1"}, Started: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		},
+		Settings: settings,
+		Raw:      mustAny(t, `{"y":1,"x":2}`),
+	}
+
+	x, err := FromStruct(&cfg)
+	require.NoError(t, err)
+
+	m, ok := x.V().(*Map[string, any])
+	require.True(t, ok)
+	assert.Equal(t, []string{"name", "version", "servers", "settings", "raw", "Enabled"}, m.Keys())
+
+	expected := `{"name":"prod","version":2,"servers":[{"host":"a","port":80,"labels":{"a":"1","b":"2"},"started":"2024-01-02T03:04:05Z"}],"settings":{"z":1,"a":2},"raw":{"y":1,"x":2},"Enabled":false}`
+	assert.Equal(t, expected, jsonMarshalString(t, x))
+
+	// round trip:
+	var back testConfig
+	err = x.DecodeInto(&back)
+	require.NoError(t, err)
+	cfg.Comment = ""
+	assert.Equal(t, jsonMarshalString(t, cfg), jsonMarshalString(t, back))
+
+	_, err = FromStruct(12)
+	assert.Error(t, err)
+}
+
+type testVersion struct {
+	Major, Minor int
+}
+
+// MarshalText has a pointer receiver.
+func (v *testVersion) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("v%d.%d", v.Major, v.Minor)), nil
+}
+
+func TestFromStruct_PointerMarshalers(t *testing.T) {
+	type Release struct {
+		Name    string      `json:"name"`
+		Version testVersion `json:"version"`
+	}
+	r := Release{Name: "r", Version: testVersion{Major: 1, Minor: 2}}
+
+	// addressable fields, and fields of a struct passed by value:
+	for _, v := range []any{&r, r} {
+		x, err := FromStruct(v)
+		require.NoError(t, err)
+		assert.Equal(t, `{"name":"r","version":"v1.2"}`, jsonMarshalString(t, x))
+	}
+}
+
+func TestAnyDecodeInto_NilAny(t *testing.T) {
+	type Target struct {
+		Raw   Any
+		Count *int
+		Name  string
+	}
+	count := 3
+	target := Target{Raw: NewAny(1), Count: &count, Name: "x"}
+
+	m := &Map[string, any]{}
+	m.Set("Raw", (*Any)(nil))
+	m.Set("Count", (*Any)(nil))
+	m.Set("Name", (*YAMLScalar)(nil))
+	require.NoError(t, NewAny(m).DecodeInto(&target))
+	assert.Equal(t, Target{}, target)
+
+	var n int
+	require.NoError(t, NewAny((*Any)(nil)).DecodeInto(&n))
+	assert.Equal(t, 0, n)
+}
+
+func TestFromStruct_EmbeddedConflicts(t *testing.T) {
+	type A struct {
+		X int
+		Y int `json:"y"`
+	}
+	type B struct {
+		X int
+		Y int
+	}
+	type C struct {
+		First string
+		A
+		B
+		Z *A `json:"z,omitempty"`
+	}
+
+	x, err := FromStruct(C{First: "first", A: A{X: 1, Y: 2}, B: B{X: 3, Y: 4}})
+	require.NoError(t, err)
+
+	// 'X' is ambiguous and dropped, 'y' and 'Y' are different names and are both kept
+	bs, err := json.Marshal(x)
+	require.NoError(t, err)
+	assert.Equal(t, `{"First":"first","y":2,"Y":4}`, string(bs))
+}
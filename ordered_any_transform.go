@@ -0,0 +1,294 @@
+package ordmap
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// CollisionPolicy tells `Transform()` what to do when two keys of the same object are renamed to the same key.
+type CollisionPolicy int
+
+const (
+	// CollisionError makes `Transform()` fail.
+	CollisionError CollisionPolicy = iota
+	// CollisionKeepFirst keeps the value of the first entry, later entries are dropped.
+	CollisionKeepFirst
+	// CollisionKeepLast keeps the value of the last entry, at the position of the first entry.
+	CollisionKeepLast
+)
+
+// TransformFuncs holds the functions applied by `Transform()`. Any of them may be nil.
+//
+// The Path passed to each function designates the location of the value in the original document.
+type TransformFuncs struct {
+	// Key is called on each key of each object, and returns the new key.
+	Key func(path Path, key any) (any, error)
+
+	// Value is called on each value, after its children have been transformed,
+	// and returns the new value.
+	Value func(path Path, v any) (any, error)
+
+	// Filter is called on each object entry and array element, after Value. If it
+	// returns false, the entry is dropped.
+	Filter func(path Path, v any) bool
+
+	// OnCollision is the policy applied when Key renames two keys of an object to the same key.
+	OnCollision CollisionPolicy
+}
+
+// Transform returns a transformed copy of 'doc': objects (`*Map[string, any]` and `*Map[any, any]`)
+// and arrays are rebuilt recursively, applying the functions of 'fns' to all keys and values.
+//
+// The relative order of the entries of each object and array is preserved.
+// 'doc' is left untouched.
+func Transform(doc Any, fns TransformFuncs) (Any, error) {
+	v, err := fns.transform(nil, doc.v)
+	if err != nil {
+		return Any{}, err
+	}
+	return Any{v: v}, nil
+}
+
+func (fns *TransformFuncs) transform(path Path, v any) (any, error) {
+	var err error
+
+	switch x := v.(type) {
+	case Any:
+		return fns.transform(path, x.v)
+
+	case *Map[string, any]:
+		res := &Map[string, any]{}
+		err = fns.transformEntries(path, x, func(k any, v any) error {
+			s, ok := k.(string)
+			if !ok {
+				return fmt.Errorf("error when transforming %q: key %v is a %T, expected a string", path, k, k)
+			}
+			_, exists := res.Get2(s)
+			if keep, err := fns.checkCollision(path, k, exists); !keep {
+				return err
+			}
+			res.Set(s, v)
+			return nil
+		})
+		v = res
+
+	case *Map[any, any]:
+		res := &Map[any, any]{}
+		err = fns.transformEntries(path, x, func(k any, v any) error {
+			_, exists := res.Get2(k)
+			if keep, err := fns.checkCollision(path, k, exists); !keep {
+				return err
+			}
+			res.Set(k, v)
+			return nil
+		})
+		v = res
+
+	case []any:
+		res := make([]any, 0, len(x))
+		for i, elt := range x {
+			eltPath := path.Index(i)
+			elt, err := fns.transform(eltPath, elt)
+			if err != nil {
+				return nil, err
+			}
+			if fns.Filter != nil && !fns.Filter(eltPath, elt) {
+				continue
+			}
+			res = append(res, elt)
+		}
+		v = res
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	if fns.Value != nil {
+		v, err = fns.Value(path, v)
+		if err != nil {
+			return nil, fmt.Errorf("error when transforming %q: %w", path, err)
+		}
+	}
+	return v, nil
+}
+
+// transformEntries
+//
+// transforms and filters all the entries of 'm', and calls 'set' with the new key and value of each kept entry.
+func (fns *TransformFuncs) transformEntries(path Path, m anyMap, set func(k, v any) error) error {
+	return m.eachAny(func(k, v any) error {
+		entryPath := path.Key(k)
+
+		v, err := fns.transform(entryPath, v)
+		if err != nil {
+			return err
+		}
+		if fns.Filter != nil && !fns.Filter(entryPath, v) {
+			return nil
+		}
+
+		if fns.Key != nil {
+			k, err = fns.Key(entryPath, k)
+			if err != nil {
+				return fmt.Errorf("error when transforming key %q: %w", entryPath, err)
+			}
+		}
+		return set(k, v)
+	})
+}
+
+// checkCollision
+//
+// applies the collision policy, and returns true if the entry for key 'k' should be stored.
+func (fns *TransformFuncs) checkCollision(path Path, k any, exists bool) (bool, error) {
+	if !exists {
+		return true, nil
+	}
+	switch fns.OnCollision {
+	case CollisionKeepFirst:
+		return false, nil
+	case CollisionKeepLast:
+		return true, nil
+	default:
+		return false, fmt.Errorf("error when transforming %q: several keys are renamed to %v", path, k)
+	}
+}
+
+// RenameKeys returns a function usable as `TransformFuncs.Key`, which applies 'rename' on all
+// string keys. Keys of other types are left unchanged.
+func RenameKeys(rename func(string) string) func(path Path, key any) (any, error) {
+	return func(path Path, key any) (any, error) {
+		if s, ok := key.(string); ok {
+			return rename(s), nil
+		}
+		return key, nil
+	}
+}
+
+// DropNulls is a function usable as `TransformFuncs.Filter`, which drops all null values.
+func DropNulls(path Path, v any) bool {
+	return v != nil
+}
+
+var jsonNumberSyntax = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// CoerceNumericStrings is a function usable as `TransformFuncs.Value`, which converts
+// strings holding a number into a float64.
+//
+// Only strings which follow the JSON syntax for numbers are converted: "NaN", "Inf",
+// "0x10" or "1_000" are left as strings, and so are numbers which overflow a float64.
+func CoerceNumericStrings(path Path, v any) (any, error) {
+	s, ok := v.(string)
+	if !ok {
+		return v, nil
+	}
+	s = strings.TrimSpace(s)
+	if !jsonNumberSyntax.MatchString(s) {
+		return v, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return v, nil
+	}
+	return f, nil
+}
+
+// splitWords
+//
+// splits an identifier into words, on separators ('_', '-', '.' and spaces) and on changes of case:
+// "HTTPServer_id" is split into "HTTP", "Server", "id".
+func splitWords(s string) []string {
+	var words []string
+	runes := []rune(s)
+	start := -1
+
+	for i, r := range runes {
+		if r == '_' || r == '-' || r == '.' || unicode.IsSpace(r) {
+			if start >= 0 {
+				words = append(words, string(runes[start:i]))
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+			continue
+		}
+
+		prev := runes[i-1]
+		boundary := false
+		switch {
+		case unicode.IsUpper(r) && (unicode.IsLower(prev) || unicode.IsDigit(prev)):
+			// "camelCase", "v2Beta"
+			boundary = true
+		case unicode.IsUpper(r) && unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1]):
+			// "HTTPServer"
+			boundary = true
+		}
+		if boundary {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	if start >= 0 {
+		words = append(words, string(runes[start:]))
+	}
+	return words
+}
+
+func capitalize(word string) string {
+	runes := []rune(strings.ToLower(word))
+	if len(runes) > 0 {
+		runes[0] = unicode.ToUpper(runes[0])
+	}
+	return string(runes)
+}
+
+// SnakeCase converts an identifier to snake_case: "userID" becomes "user_id".
+func SnakeCase(s string) string {
+	words := splitWords(s)
+	for i, w := range words {
+		words[i] = strings.ToLower(w)
+	}
+	return strings.Join(words, "_")
+}
+
+// ScreamingSnakeCase converts an identifier to SCREAMING_SNAKE_CASE: "userID" becomes "USER_ID".
+func ScreamingSnakeCase(s string) string {
+	return strings.ToUpper(SnakeCase(s))
+}
+
+// KebabCase converts an identifier to kebab-case: "userID" becomes "user-id".
+func KebabCase(s string) string {
+	words := splitWords(s)
+	for i, w := range words {
+		words[i] = strings.ToLower(w)
+	}
+	return strings.Join(words, "-")
+}
+
+// CamelCase converts an identifier to camelCase: "user_id" becomes "userId".
+func CamelCase(s string) string {
+	words := splitWords(s)
+	for i, w := range words {
+		if i == 0 {
+			words[i] = strings.ToLower(w)
+		} else {
+			words[i] = capitalize(w)
+		}
+	}
+	return strings.Join(words, "")
+}
+
+// PascalCase converts an identifier to PascalCase: "user_id" becomes "UserId".
+func PascalCase(s string) string {
+	words := splitWords(s)
+	for i, w := range words {
+		words[i] = capitalize(w)
+	}
+	return strings.Join(words, "")
+}
@@ -0,0 +1,143 @@
+package ordmap
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestTransform(t *testing.T) {
+	doc := mustAny(t, `{"userID":"12","displayName":null,"HTTPHeaders":[{"headerName":"X","headerValue":"1.5"},null],"createdAt":"2024"}`)
+
+	res, err := Transform(doc, TransformFuncs{
+		Key:    RenameKeys(SnakeCase),
+		Value:  CoerceNumericStrings,
+		Filter: DropNulls,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `{"user_id":12,"http_headers":[{"header_name":"X","header_value":1.5}],"created_at":2024}`, jsonMarshalString(t, res))
+
+	// the original document is left untouched:
+	assert.Equal(t, `{"userID":"12","displayName":null,"HTTPHeaders":[{"headerName":"X","headerValue":"1.5"},null],"createdAt":"2024"}`, jsonMarshalString(t, doc))
+}
+
+func TestCoerceNumericStrings(t *testing.T) {
+	converted := map[string]float64{
+		"12":      12,
+		" -1.5 ":  -1.5,
+		"0":       0,
+		"2.5e3":   2500,
+		"1E-2":    0.01,
+		"-0.0":    0,
+		"1234567": 1234567,
+	}
+	for input, expected := range converted {
+		v, err := CoerceNumericStrings(nil, input)
+		require.NoError(t, err)
+		assert.Equal(t, expected, v, "input: %q", input)
+	}
+
+	unchanged := []string{
+		"", " ", "NaN", "nan", "Inf", "-Inf", "+Inf", "infinity", "0x10", "0X1p-2", "1_000",
+		"+1", "01", ".5", "5.", "1e", "1e400", "-1e400", "12abc",
+	}
+	for _, input := range unchanged {
+		v, err := CoerceNumericStrings(nil, input)
+		require.NoError(t, err)
+		assert.Equal(t, input, v, "input: %q", input)
+	}
+
+	// the result of a transform can always be encoded as JSON:
+	doc := mustAny(t, `{"a":"NaN","b":"Inf","c":"1e999","d":"7"}`)
+	res, err := Transform(doc, TransformFuncs{Value: CoerceNumericStrings})
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":"NaN","b":"Inf","c":"1e999","d":7}`, jsonMarshalString(t, res))
+}
+
+func TestTransform_Paths(t *testing.T) {
+	doc := mustAny(t, `{"a":{"b":[1,2]},"c":3}`)
+
+	var paths []string
+	_, err := Transform(doc, TransformFuncs{
+		Key: func(path Path, key any) (any, error) {
+			return strings.ToUpper(key.(string)), nil
+		},
+		Value: func(path Path, v any) (any, error) {
+			paths = append(paths, path.String())
+			return v, nil
+		},
+	})
+	require.NoError(t, err)
+	// children are transformed before their parent, paths refer to the original keys:
+	assert.Equal(t, []string{"a.b[0]", "a.b[1]", "a.b", "a", "c", ""}, paths)
+
+	myErr := errors.New("my error")
+	_, err = Transform(doc, TransformFuncs{
+		Value: func(path Path, v any) (any, error) {
+			if path.String() == "a.b[1]" {
+				return nil, myErr
+			}
+			return v, nil
+		},
+	})
+	assert.ErrorIs(t, err, myErr)
+}
+
+func TestTransform_Collisions(t *testing.T) {
+	doc := mustAny(t, `{"user_id":1,"name":"x","userId":2}`)
+	fns := TransformFuncs{Key: RenameKeys(CamelCase)}
+
+	_, err := Transform(doc, fns)
+	assert.Error(t, err)
+
+	fns.OnCollision = CollisionKeepFirst
+	res, err := Transform(doc, fns)
+	require.NoError(t, err)
+	assert.Equal(t, `{"userId":1,"name":"x"}`, jsonMarshalString(t, res))
+
+	fns.OnCollision = CollisionKeepLast
+	res, err = Transform(doc, fns)
+	require.NoError(t, err)
+	assert.Equal(t, `{"userId":2,"name":"x"}`, jsonMarshalString(t, res))
+
+	// keys of a json object must remain strings:
+	_, err = Transform(doc, TransformFuncs{Key: func(path Path, key any) (any, error) { return 1, nil }})
+	assert.Error(t, err)
+}
+
+func TestTransform_YAML(t *testing.T) {
+	var doc Any
+	err := yaml.Unmarshal([]byte("FirstKey: 1\n12: two\nnested:\n  some-key: ~\n"), &doc)
+	require.NoError(t, err)
+
+	res, err := Transform(doc, TransformFuncs{Key: RenameKeys(KebabCase), Filter: DropNulls})
+	require.NoError(t, err)
+
+	out, err := yaml.Marshal(res)
+	require.NoError(t, err)
+	assert.Equal(t, "first-key: 1\n12: two\nnested: {}\n", string(out))
+}
+
+func TestCaseConventions(t *testing.T) {
+	type testCase struct{ input, snake, camel, pascal, kebab, screaming string }
+	table := []testCase{
+		{"userID", "user_id", "userId", "UserId", "user-id", "USER_ID"},
+		{"HTTPServer", "http_server", "httpServer", "HttpServer", "http-server", "HTTP_SERVER"},
+		{"user_name", "user_name", "userName", "UserName", "user-name", "USER_NAME"},
+		{"X-Request-Id", "x_request_id", "xRequestId", "XRequestId", "x-request-id", "X_REQUEST_ID"},
+		{"apiV2Beta", "api_v2_beta", "apiV2Beta", "ApiV2Beta", "api-v2-beta", "API_V2_BETA"},
+		{"already", "already", "already", "Already", "already", "ALREADY"},
+		{"", "", "", "", "", ""},
+	}
+	for _, tc := range table {
+		assert.Equal(t, tc.snake, SnakeCase(tc.input), "input: %s", tc.input)
+		assert.Equal(t, tc.camel, CamelCase(tc.input), "input: %s", tc.input)
+		assert.Equal(t, tc.pascal, PascalCase(tc.input), "input: %s", tc.input)
+		assert.Equal(t, tc.kebab, KebabCase(tc.input), "input: %s", tc.input)
+		assert.Equal(t, tc.screaming, ScreamingSnakeCase(tc.input), "input: %s", tc.input)
+	}
+}
@@ -0,0 +1,105 @@
+package ordmap
+
+import (
+	"errors"
+	"reflect"
+)
+
+var (
+	// SkipChildren can be returned by a WalkFunc called on an object or an array,
+	// to indicate that its children should not be visited.
+	SkipChildren = errors.New("skip children")
+
+	// Stop can be returned by a WalkFunc to stop the walk; `Walk()` then returns nil.
+	Stop = errors.New("stop walk")
+)
+
+// WalkFunc is the type of the functions called by `Walk()` for each visited value.
+type WalkFunc func(path Path, v any) error
+
+// Walker holds the functions called when walking a tree of values.
+//
+// Pre is called on a value before its children are visited, Post is called after.
+// Either may be nil.
+type Walker struct {
+	Pre  WalkFunc
+	Post WalkFunc
+}
+
+// Walk visits 'v' and all its children, calling 'fn' on each value before visiting its children.
+//
+// Objects (`*Map` values) and arrays (`[]any`) are walked recursively, the entries of objects
+// are visited in their insertion order. An `Any` is replaced with the value it wraps.
+//
+// If 'fn' returns `SkipChildren`, the children of the current value are skipped.
+// If 'fn' returns `Stop`, the walk stops and Walk returns nil. Any other error stops the walk,
+// and is returned by Walk.
+func Walk(v any, fn WalkFunc) error {
+	return Walker{Pre: fn}.Walk(v)
+}
+
+// Walk visits 'v' and all its children, see the package level `Walk()` function.
+//
+// If Pre returns `SkipChildren`, the children are not visited, but Post is still called on the value.
+func (w Walker) Walk(v any) error {
+	err := w.walk(nil, v)
+	if errors.Is(err, Stop) {
+		return nil
+	}
+	return err
+}
+
+func (w Walker) walk(path Path, v any) error {
+	switch x := v.(type) {
+	case Any:
+		v = x.v
+	case *Any:
+		v = x.v
+	}
+
+	skip := false
+	if w.Pre != nil {
+		err := w.Pre(path, v)
+		switch {
+		case errors.Is(err, SkipChildren):
+			skip = true
+		case err != nil:
+			return err
+		}
+	}
+
+	if !skip {
+		err := w.walkChildren(path, v)
+		if err != nil {
+			return err
+		}
+	}
+
+	if w.Post != nil {
+		err := w.Post(path, v)
+		if err != nil && !errors.Is(err, SkipChildren) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w Walker) walkChildren(path Path, v any) error {
+	switch x := v.(type) {
+	case []any:
+		for i, elt := range x {
+			err := w.walk(path.Index(i), elt)
+			if err != nil {
+				return err
+			}
+		}
+	case anyMap:
+		if reflect.ValueOf(x).IsNil() {
+			return nil
+		}
+		return x.eachAny(func(k, elt any) error {
+			return w.walk(path.Key(k), elt)
+		})
+	}
+	return nil
+}
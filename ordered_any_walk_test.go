@@ -0,0 +1,98 @@
+package ordmap
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestWalk(t *testing.T) {
+	x := mustAny(t, `{"z":1,"y":[true,{"b":null,"a":"s"}],"x":{}}`)
+
+	var visited []string
+	err := Walk(x, func(path Path, v any) error {
+		visited = append(visited, fmt.Sprintf("%s=%T", path.JSONPointer(), v))
+		return nil
+	})
+	require.NoError(t, err)
+
+	expected := []string{
+		"=*ordmap.Map[string,interface {}]",
+		"/z=float64",
+		"/y=[]interface {}",
+		"/y/0=bool",
+		"/y/1=*ordmap.Map[string,interface {}]",
+		"/y/1/b=<nil>",
+		"/y/1/a=string",
+		"/x=*ordmap.Map[string,interface {}]",
+	}
+	assert.Equal(t, expected, visited)
+}
+
+func TestWalk_YAML(t *testing.T) {
+	var x Any
+	err := yaml.Unmarshal([]byte("b: [1, 2]\n12: {c: d}\n"), &x)
+	require.NoError(t, err)
+
+	var visited []string
+	err = Walk(&x, func(path Path, v any) error {
+		visited = append(visited, path.String())
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"", "b", "b[0]", "b[1]", "12", "12.c"}, visited)
+}
+
+func TestWalk_SkipAndStop(t *testing.T) {
+	x := mustAny(t, `{"a":{"skipped":1},"b":[1,2,3],"c":"not visited"}`)
+
+	var visited []string
+	err := Walk(x, func(path Path, v any) error {
+		visited = append(visited, path.String())
+		switch path.String() {
+		case "a":
+			return SkipChildren
+		case "b[1]":
+			return Stop
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"", "a", "b", "b[0]", "b[1]"}, visited)
+
+	myErr := errors.New("my error")
+	err = Walk(x, func(path Path, v any) error {
+		if path.String() == "b" {
+			return myErr
+		}
+		return nil
+	})
+	assert.Equal(t, myErr, err)
+}
+
+func TestWalker_PrePost(t *testing.T) {
+	x := mustAny(t, `{"a":{"b":[1]},"c":2}`)
+
+	var sb strings.Builder
+	w := Walker{
+		Pre: func(path Path, v any) error {
+			fmt.Fprintf(&sb, "<%s>", path)
+			if path.String() == "a.b" {
+				return SkipChildren
+			}
+			return nil
+		},
+		Post: func(path Path, v any) error {
+			fmt.Fprintf(&sb, "</%s>", path)
+			return nil
+		},
+	}
+	err := w.Walk(x)
+	require.NoError(t, err)
+	assert.Equal(t, "<><a><a.b></a.b></a><c></c></>", sb.String())
+}
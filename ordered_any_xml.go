@@ -0,0 +1,438 @@
+package ordmap
+
+import (
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The convention used to map XML elements on Any values:
+//   - an element is an entry of an object, whose key is the name of the element, including its
+//     namespace prefix as written in the document ("soap:Body"),
+//   - an element without attributes nor child elements has its text as value, or nil if it is empty,
+//   - other elements are `*Map[string, any]` objects: attributes come first, under their name
+//     prefixed with `XMLAttrPrefix` ("@id", "@xmlns:soap"), followed by the child elements in
+//     document order, and by the text of the element under `XMLTextKey` if it is not empty,
+//   - repeated child elements with the same name are grouped in an array, at the position of the
+//     first one,
+//   - all values are strings; comments and processing instructions are dropped.
+//
+// When child elements are present, the text of the element is the concatenation of its text
+// nodes, with leading and trailing spaces removed.
+const (
+	XMLAttrPrefix = "@"
+	XMLTextKey    = "#text"
+)
+
+// DecodeXML reads the XML document from 'r', and returns it as an object with a single key: the root element.
+//
+// See `XMLAttrPrefix` for the description of the convention.
+func DecodeXML(r io.Reader) (Any, error) {
+	d := xml.NewDecoder(r)
+	xr := &xmlReader{next: d.RawToken, raw: true}
+
+	var res *Map[string, any]
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Any{}, fmt.Errorf("error when decoding xml: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if res != nil {
+				return Any{}, errors.New("error when decoding xml: more than one root element")
+			}
+			v, err := xr.readElement(t)
+			if err != nil {
+				return Any{}, fmt.Errorf("error when decoding xml: %w", err)
+			}
+			res = &Map[string, any]{}
+			res.Set(xr.nameOf(t.Name), v)
+		case xml.CharData:
+			if len(strings.TrimSpace(string(t))) > 0 {
+				return Any{}, errors.New("error when decoding xml: text outside of the root element")
+			}
+		case xml.EndElement:
+			return Any{}, fmt.Errorf("error when decoding xml: unexpected end element </%s>", xr.nameOf(t.Name))
+		}
+	}
+	if res == nil {
+		return Any{}, fmt.Errorf("error when decoding xml: %w", io.ErrUnexpectedEOF)
+	}
+	return Any{v: res}, nil
+}
+
+// EncodeXML writes 'v' to 'w' as an XML document. 'v' must be an object with a single key: the root element.
+//
+// See `XMLAttrPrefix` for the description of the convention. Numbers, booleans and timestamps are
+// formatted as text, Go values which are not objects, arrays or scalars (such as structs) are
+// written with `encoding/xml`.
+func EncodeXML(w io.Writer, v any) error {
+	return EncodeXMLIndent(w, v, "", "")
+}
+
+// EncodeXMLIndent is the same as `EncodeXML()`, where each element begins on a new line starting
+// with 'prefix' followed by one or more copies of 'indent' according to the nesting depth.
+func EncodeXMLIndent(w io.Writer, v any, prefix, indent string) error {
+	root, ok := resolveXMLValue(v).(anyMap)
+	if !ok || reflect.ValueOf(root).IsNil() || root.Len() != 1 {
+		return errors.New("error when encoding xml: expected an object with a single key")
+	}
+
+	e := xml.NewEncoder(w)
+	e.Indent(prefix, indent)
+	err := root.eachAny(func(k, v any) error {
+		name, ok := k.(string)
+		if !ok {
+			return fmt.Errorf("error when encoding xml: invalid root element name %v", k)
+		}
+		if _, isArray := resolveXMLValue(v).([]any); isArray {
+			return fmt.Errorf("error when encoding xml: more than one root element %q", name)
+		}
+		return writeXMLElement(e, name, name, v)
+	})
+	if err != nil {
+		return err
+	}
+	return e.Flush()
+}
+
+// MarshalXML writes the content of 'x' as the content of the element 'start'.
+func (x Any) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return writeXMLContent(e, start.Name.Local, start, x.v)
+}
+
+// UnmarshalXML stores the content of the element 'start' in 'x'.
+func (x *Any) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	xr := &xmlReader{next: d.Token}
+	v, err := xr.readElement(start)
+	if err != nil {
+		return fmt.Errorf("error when decoding xml: %w", err)
+	}
+	x.v = v
+	return nil
+}
+
+// xmlReader converts the tokens of an element into a value.
+type xmlReader struct {
+	next func() (xml.Token, error)
+	// raw is true if tokens come from xml.Decoder.RawToken: names hold the prefixes as written,
+	// and end elements are not checked by the decoder.
+	raw bool
+	// scopes maps namespace URLs to their prefix, for tokens which come from xml.Decoder.Token
+	scopes []map[string]string
+}
+
+func (r *xmlReader) nameOf(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+	if r.raw || n.Space == "xmlns" {
+		return n.Space + ":" + n.Local
+	}
+	if n.Space == "http://www.w3.org/XML/1998/namespace" {
+		return "xml:" + n.Local
+	}
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		if prefix, ok := r.scopes[i][n.Space]; ok {
+			if prefix == "" {
+				return n.Local
+			}
+			return prefix + ":" + n.Local
+		}
+	}
+	// the namespace was declared outside of the decoded element
+	return n.Local
+}
+
+func (r *xmlReader) readElement(start xml.StartElement) (any, error) {
+	if !r.raw {
+		scope := map[string]string{}
+		for _, attr := range start.Attr {
+			switch {
+			case attr.Name.Space == "xmlns":
+				scope[attr.Value] = attr.Name.Local
+			case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+				scope[attr.Value] = ""
+			}
+		}
+		r.scopes = append(r.scopes, scope)
+		defer func() { r.scopes = r.scopes[:len(r.scopes)-1] }()
+	}
+
+	res := &Map[string, any]{}
+	for _, attr := range start.Attr {
+		name := XMLAttrPrefix + r.nameOf(attr.Name)
+		if _, exists := res.Get2(name); exists {
+			return nil, fmt.Errorf("duplicate attribute %q", name)
+		}
+		res.Set(name, attr.Value)
+	}
+	hasChildren := false
+	var text strings.Builder
+
+	for {
+		tok, err := r.next()
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			hasChildren = true
+			name := r.nameOf(t.Name)
+			child, err := r.readElement(t)
+			if err != nil {
+				return nil, err
+			}
+			prev, exists := res.Get2(name)
+			switch {
+			case !exists:
+				res.Set(name, child)
+			case isXMLRepeated(prev):
+				res.Set(name, append(prev.([]any), child))
+			default:
+				res.Set(name, []any{prev, child})
+			}
+
+		case xml.CharData:
+			text.Write(t)
+
+		case xml.EndElement:
+			if r.raw && t.Name != start.Name {
+				return nil, fmt.Errorf("element <%s> closed by </%s>", r.nameOf(start.Name), r.nameOf(t.Name))
+			}
+			s := text.String()
+			if hasChildren {
+				s = strings.TrimSpace(s)
+			}
+			if res.Len() == 0 {
+				if s == "" {
+					return nil, nil
+				}
+				return s, nil
+			}
+			if s != "" {
+				res.Set(XMLTextKey, s)
+			}
+			return res, nil
+		}
+	}
+}
+
+// isXMLRepeated tells if 'v' is the array of a repeated element: other values of elements
+// are never arrays.
+func isXMLRepeated(v any) bool {
+	_, ok := v.([]any)
+	return ok
+}
+
+func resolveXMLValue(v any) any {
+	for {
+		switch x := v.(type) {
+		case Any:
+			v = x.v
+		case *Any:
+			if x == nil {
+				return nil
+			}
+			v = x.v
+		case *YAMLAnchor:
+			v = x.Value
+		case *YAMLScalar:
+			v = x.Value
+		default:
+			if rv := reflect.ValueOf(v); rv.IsValid() && rv.Kind() != reflect.Pointer && reflect.PointerTo(rv.Type()).Implements(anyMapType) {
+				p := reflect.New(rv.Type())
+				p.Elem().Set(rv)
+				return p.Interface()
+			}
+			return v
+		}
+	}
+}
+
+// writeXMLElement writes the element 'name' with the content 'v'.
+func writeXMLElement(e *xml.Encoder, where string, name string, v any) error {
+	if name == "" || strings.HasPrefix(name, XMLAttrPrefix) {
+		return fmt.Errorf("error when encoding xml %s: invalid element name %q", where, name)
+	}
+	return writeXMLContent(e, where, xml.StartElement{Name: xml.Name{Local: name}}, v)
+}
+
+// writeXMLContent writes the element 'start', with the attributes and content described by 'v'.
+func writeXMLContent(e *xml.Encoder, where string, start xml.StartElement, v any) error {
+	v = resolveXMLValue(v)
+
+	switch x := v.(type) {
+	case nil:
+		err := e.EncodeToken(start)
+		if err != nil {
+			return err
+		}
+		return e.EncodeToken(start.End())
+
+	case anyMap:
+		if reflect.ValueOf(x).IsNil() {
+			return writeXMLContent(e, where, start, nil)
+		}
+		return writeXMLMap(e, where, start, x)
+
+	case []any:
+		return fmt.Errorf("error when encoding xml %s: unexpected array", where)
+	}
+
+	if s, ok, err := xmlText(v); ok || err != nil {
+		if err != nil {
+			return fmt.Errorf("error when encoding xml %s: %w", where, err)
+		}
+		err := e.EncodeToken(start)
+		if err != nil {
+			return err
+		}
+		if s != "" {
+			err = e.EncodeToken(xml.CharData(s))
+			if err != nil {
+				return err
+			}
+		}
+		return e.EncodeToken(start.End())
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Map {
+		m, err := toAnyValue(where, rv)
+		if err != nil {
+			return err
+		}
+		return writeXMLContent(e, where, start, m)
+	}
+	return e.EncodeElement(v, start)
+}
+
+func writeXMLMap(e *xml.Encoder, where string, start xml.StartElement, m anyMap) error {
+	type child struct {
+		name  string
+		value any
+	}
+	var children []child
+
+	err := m.eachAny(func(k, v any) error {
+		name, err := mapKeyString(reflect.ValueOf(k))
+		if err != nil {
+			return fmt.Errorf("error when encoding xml %s: %w", where, err)
+		}
+		if !strings.HasPrefix(name, XMLAttrPrefix) {
+			children = append(children, child{name: name, value: v})
+			return nil
+		}
+
+		s, ok, err := xmlText(resolveXMLValue(v))
+		if err != nil {
+			return fmt.Errorf("error when encoding xml %s.%s: %w", where, name, err)
+		}
+		if !ok {
+			return fmt.Errorf("error when encoding xml %s.%s: attribute value of type %T is not a scalar", where, name, v)
+		}
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: strings.TrimPrefix(name, XMLAttrPrefix)}, Value: s})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = e.EncodeToken(start)
+	if err != nil {
+		return err
+	}
+	for _, c := range children {
+		childWhere := where + "." + c.name
+		if c.name == XMLTextKey {
+			s, ok, err := xmlText(resolveXMLValue(c.value))
+			if err != nil {
+				return fmt.Errorf("error when encoding xml %s: %w", childWhere, err)
+			}
+			if !ok {
+				return fmt.Errorf("error when encoding xml %s: text of type %T is not a scalar", childWhere, c.value)
+			}
+			err = e.EncodeToken(xml.CharData(s))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if elts, ok := resolveXMLValue(c.value).([]any); ok {
+			for i, elt := range elts {
+				err := writeXMLElement(e, fmt.Sprintf("%s[%d]", childWhere, i), c.name, elt)
+				if err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		err := writeXMLElement(e, childWhere, c.name, c.value)
+		if err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// xmlText
+//
+// formats the scalar 'v' as text, and returns false if 'v' is not a scalar.
+func xmlText(v any) (string, bool, error) {
+	switch x := v.(type) {
+	case nil:
+		return "", true, nil
+	case string:
+		return x, true, nil
+	case []byte:
+		return string(x), true, nil
+	case bool:
+		return strconv.FormatBool(x), true, nil
+	case float64:
+		return strconv.FormatFloat(x, 'g', -1, 64), true, nil
+	case float32:
+		return strconv.FormatFloat(float64(x), 'g', -1, 32), true, nil
+	case json.Number:
+		return string(x), true, nil
+	case time.Time:
+		return x.Format(time.RFC3339Nano), true, nil
+	case xml.Marshaler:
+		return "", false, nil
+	case encoding.TextMarshaler:
+		bs, err := x.MarshalText()
+		return string(bs), true, err
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), true, nil
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), true, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(rv.Uint(), 10), true, nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'g', -1, 64), true, nil
+	}
+	return "", false, nil
+}
@@ -0,0 +1,125 @@
+package ordmap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testXMLDocument = `<?xml version="1.0" encoding="UTF-8"?>
+<!-- order service -->
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" version="1.2">
+  <soap:Body>
+    <order id="42" xml:lang="fr">
+      <item sku="b-1">Banana</item>
+      <note>ship &amp; bill</note>
+      <item sku="a-2">Apple</item>
+      <empty/>
+      <address><city>Lyon</city><zip>69001</zip></address>
+    </order>
+  </soap:Body>
+</soap:Envelope>
+`
+
+func TestDecodeXML(t *testing.T) {
+	x, err := DecodeXML(strings.NewReader(testXMLDocument))
+	require.NoError(t, err)
+
+	expected := `{"soap:Envelope":{` +
+		`"@xmlns:soap":"http://schemas.xmlsoap.org/soap/envelope/","@version":"1.2",` +
+		`"soap:Body":{"order":{"@id":"42","@xml:lang":"fr",` +
+		`"item":[{"@sku":"b-1","#text":"Banana"},{"@sku":"a-2","#text":"Apple"}],` +
+		`"note":"ship \u0026 bill",` +
+		`"empty":null,` +
+		`"address":{"city":"Lyon","zip":"69001"}}}}}`
+	assert.Equal(t, expected, jsonMarshalString(t, x))
+
+	// text of leaf elements is kept as is, text next to child elements is trimmed:
+	x, err = DecodeXML(strings.NewReader("<a><b> x </b>\n  text <c/>\n</a>"))
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":{"b":" x ","c":null,"#text":"text"}}`, jsonMarshalString(t, x))
+
+	for _, input := range []string{
+		"",
+		"<a>",
+		"<a></b>",
+		"<a/><b/>",
+		"text<a/>",
+		"<a x='1' x='2'/>",
+		"</a>",
+	} {
+		_, err := DecodeXML(strings.NewReader(input))
+		assert.Error(t, err, "input: %q", input)
+	}
+}
+
+func TestEncodeXML(t *testing.T) {
+	x, err := DecodeXML(strings.NewReader(testXMLDocument))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = EncodeXMLIndent(&buf, x, "", "  ")
+	require.NoError(t, err)
+
+	expected := `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" version="1.2">
+  <soap:Body>
+    <order id="42" xml:lang="fr">
+      <item sku="b-1">Banana</item>
+      <item sku="a-2">Apple</item>
+      <note>ship &amp; bill</note>
+      <empty></empty>
+      <address>
+        <city>Lyon</city>
+        <zip>69001</zip>
+      </address>
+    </order>
+  </soap:Body>
+</soap:Envelope>`
+	assert.Equal(t, expected, buf.String())
+
+	back, err := DecodeXML(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, jsonMarshalString(t, x), jsonMarshalString(t, back))
+
+	// scalars are formatted as text:
+	buf.Reset()
+	err = EncodeXML(&buf, mustAny(t, `{"r":{"@n":1.5,"b":true,"list":[1,2],"s":"<&>"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, `<r n="1.5"><b>true</b><list>1</list><list>2</list><s>&lt;&amp;&gt;</s></r>`, buf.String())
+
+	for _, input := range []string{
+		`{}`,
+		`{"a":1,"b":2}`,
+		`[1]`,
+		`{"a":[1,2]}`,
+		`{"a":{"@x":[1]}}`,
+		`{"a":{"b":[[1]]}}`,
+		`{"a":{"":1}}`,
+	} {
+		err := EncodeXML(&buf, mustAny(t, input))
+		assert.Error(t, err, "input: %s", input)
+	}
+}
+
+func TestOrderedAny_XMLHooks(t *testing.T) {
+	type Envelope struct {
+		XMLName xml.Name `xml:"envelope"`
+		Header  string   `xml:"header"`
+		Payload Any      `xml:"payload"`
+	}
+
+	input := `<envelope><header>h</header><payload kind="k"><z>1</z><a>2</a><z>3</z></payload></envelope>`
+	var env Envelope
+	err := xml.Unmarshal([]byte(input), &env)
+	require.NoError(t, err)
+	assert.Equal(t, "h", env.Header)
+	assert.Equal(t, `{"@kind":"k","z":["1","3"],"a":"2"}`, jsonMarshalString(t, env.Payload))
+
+	out, err := xml.Marshal(env)
+	require.NoError(t, err)
+	assert.Equal(t, `<envelope><header>h</header><payload kind="k"><z>1</z><z>3</z><a>2</a></payload></envelope>`, string(out))
+}
@@ -1,47 +1,348 @@
 package ordmap
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"reflect"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
+// DefaultMaxAliasExpansion is the maximum number of nodes which can be decoded through
+// aliases, when `YAMLOptions.MaxAliasExpansion` is 0.
+const DefaultMaxAliasExpansion = 100000
+
+// YAMLOptions controls how YAML nodes are decoded into an Any.
+type YAMLOptions struct {
+	// MaxAliasExpansion is the maximum number of nodes which can be decoded through aliases,
+	// to protect against documents which expand exponentially ("billion laughs").
+	// 0 means `DefaultMaxAliasExpansion`, a negative value means no limit.
+	MaxAliasExpansion int
+
+	// PreserveAliases decodes anchored nodes as a `*YAMLAnchor`, and aliases to this node as
+	// the same `*YAMLAnchor`. Marshaling the Any back to YAML re-emits the anchors and aliases.
+	//
+	// If false, aliases are expanded: each alias is decoded as a copy of the anchored value.
+	PreserveAliases bool
+
+	// ComplexKeys decodes mapping keys which are sequences or mappings (`? [a, b] : value`)
+	// as a `ComplexKey`. If false, such keys are an error.
+	ComplexKeys bool
+
+	// PreserveScalars decodes scalar values as a `*YAMLScalar`, which keeps the original text,
+	// tag and style of the scalar, so that untouched scalars are re-emitted exactly as they were
+	// written (`0x1F`, `1.0`, `"007"`, ...). Mapping keys are not wrapped.
+	PreserveScalars bool
+}
+
+// YAMLAnchor holds a YAML value which has an anchor, see `YAMLOptions.PreserveAliases`.
+type YAMLAnchor struct {
+	Name  string
+	Value any
+}
+
+func (a *YAMLAnchor) MarshalYAML() (any, error) {
+	return a.Value, nil
+}
+
+func (a *YAMLAnchor) MarshalJSON() ([]byte, error) {
+	return Any{v: a.Value}.MarshalJSON()
+}
+
+// YAMLScalar holds a YAML scalar value along with its original representation,
+// see `YAMLOptions.PreserveScalars`.
+type YAMLScalar struct {
+	// Value is the decoded value. If Value is changed, the scalar is encoded from Value
+	// instead of its original representation.
+	Value any
+
+	// Tag is the tag of the scalar, explicit or resolved: "!!int", "!!str", "!!timestamp" ...
+	Tag string
+	// Text is the original text of the scalar, without quotes.
+	Text  string
+	Style yaml.Style
+
+	orig any
+}
+
+// NewYAMLScalar returns a YAMLScalar which will be encoded as 'text', with the given tag and style,
+// as long as its Value is not changed.
+func NewYAMLScalar(value any, tag string, text string, style yaml.Style) *YAMLScalar {
+	return &YAMLScalar{Value: value, Tag: tag, Text: text, Style: style, orig: value}
+}
+
+// Modified returns true if Value has been changed since the scalar was decoded.
+func (s *YAMLScalar) Modified() bool {
+	if f, ok := s.Value.(float64); ok && math.IsNaN(f) {
+		g, ok := s.orig.(float64)
+		return !ok || !math.IsNaN(g)
+	}
+	return !reflect.DeepEqual(s.Value, s.orig)
+}
+
+func (s *YAMLScalar) MarshalYAML() (any, error) {
+	if s.Modified() {
+		return s.Value, nil
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: s.Tag, Value: s.Text, Style: s.Style}, nil
+}
+
+func (s *YAMLScalar) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Value)
+}
+
+func (s *YAMLScalar) deepClone(c *cloner) any {
+	res := *s
+	c.seen[s] = &res
+	return &res
+}
+
+// ComplexKey is a mapping key which is a sequence or a mapping, see `YAMLOptions.ComplexKeys`.
+//
+// It holds the canonical form of the key: its flow representation, e.g. "[a, b]" or "{x: 1, y: 2}",
+// so that two keys with the same content in the same order are equal.
+type ComplexKey struct {
+	text string
+}
+
+// NewComplexKey returns the ComplexKey for 'v', which is typically a []any or a *Map.
+func NewComplexKey(v any) (ComplexKey, error) {
+	node, err := encodeYAMLNode(v)
+	if err != nil {
+		return ComplexKey{}, fmt.Errorf("error when encoding complex key: %w", err)
+	}
+	return complexKeyFromNode(node)
+}
+
+func complexKeyFromNode(node *yaml.Node) (ComplexKey, error) {
+	flow := flowYAMLNode(node)
+	bs, err := yaml.Marshal(flow)
+	if err != nil {
+		return ComplexKey{}, fmt.Errorf("error when encoding complex key: %w", err)
+	}
+	return ComplexKey{text: strings.TrimSuffix(string(bs), "\n")}, nil
+}
+
+// flowYAMLNode
+//
+// returns a copy of 'node', without comments, anchors and aliases, where all sequences and
+// mappings use the flow style, and scalars of the core schema use the style chosen by the encoder
+// (`a`, `'a'` and `"a"` have the same canonical form).
+func flowYAMLNode(node *yaml.Node) *yaml.Node {
+	node = resolveYAMLAlias(node)
+	if node.Kind == yaml.ScalarNode {
+		switch node.ShortTag() {
+		case "!!str", "!!int", "!!float", "!!bool", "!!null":
+			var v any
+			if err := node.Decode(&v); err == nil {
+				if n, err := encodeYAMLNode(v); err == nil {
+					return n
+				}
+			}
+		}
+	}
+
+	res := &yaml.Node{Kind: node.Kind, Style: node.Style, Tag: node.Tag, Value: node.Value}
+	if node.Kind == yaml.SequenceNode || node.Kind == yaml.MappingNode {
+		res.Style = yaml.FlowStyle
+		res.Content = make([]*yaml.Node, len(node.Content))
+		for i, child := range node.Content {
+			res.Content[i] = flowYAMLNode(child)
+		}
+	}
+	return res
+}
+
+// String returns the canonical flow representation of the key.
+func (k ComplexKey) String() string {
+	return k.text
+}
+
+// Value decodes the key.
+func (k ComplexKey) Value() (Any, error) {
+	var node yaml.Node
+	err := yaml.Unmarshal([]byte(k.text), &node)
+	if err != nil {
+		return Any{}, err
+	}
+	var x Any
+	err = x.UnmarshalYAMLWith(&node, YAMLOptions{ComplexKeys: true})
+	return x, err
+}
+
+func (k ComplexKey) MarshalYAML() (any, error) {
+	var node yaml.Node
+	err := yaml.Unmarshal([]byte(k.text), &node)
+	if err != nil {
+		return nil, fmt.Errorf("invalid complex key %q: %w", k.text, err)
+	}
+	if len(node.Content) == 0 {
+		return nil, nil
+	}
+	return node.Content[0], nil
+}
+
+// MarshalText returns the canonical form of the key, so that a ComplexKey is encoded as a string in JSON.
+func (k ComplexKey) MarshalText() ([]byte, error) {
+	return []byte(k.text), nil
+}
+
 func (x Any) MarshalYAML() (interface{}, error) {
-	return x.v, nil
+	if !containsYAMLAnchor(x.v) {
+		return x.v, nil
+	}
+
+	enc := &yamlAnchorEncoder{nodes: make(map[*YAMLAnchor]*yaml.Node)}
+	return enc.encode(x.v)
 }
 
 func (x *Any) UnmarshalYAML(node *yaml.Node) error {
+	return x.UnmarshalYAMLWith(node, YAMLOptions{})
+}
+
+// UnmarshalYAMLWith is the same as `UnmarshalYAML()`, with options.
+func (x *Any) UnmarshalYAMLWith(node *yaml.Node, opts YAMLOptions) error {
 	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			x.v = nil
+			return nil
+		}
 		node = node.Content[0]
 	}
 
+	d := newYAMLDecoder(opts)
+	v, err := d.decode(node)
+	if err != nil {
+		return err
+	}
+
+	x.v = v
+	return nil
+}
+
+// yamlDecoder
+//
+// holds the state needed to decode a tree of yaml nodes into an Any:
+// options, count of nodes expanded through aliases and anchors decoded so far.
+type yamlDecoder struct {
+	opts YAMLOptions
+
+	inAlias  int
+	expanded int
+	resolved map[*yaml.Node]bool
+
+	anchors map[*yaml.Node]*YAMLAnchor
+}
+
+func newYAMLDecoder(opts YAMLOptions) *yamlDecoder {
+	if opts.MaxAliasExpansion == 0 {
+		opts.MaxAliasExpansion = DefaultMaxAliasExpansion
+	}
+	return &yamlDecoder{
+		opts:     opts,
+		resolved: make(map[*yaml.Node]bool),
+		anchors:  make(map[*yaml.Node]*YAMLAnchor),
+	}
+}
+
+func (d *yamlDecoder) decode(node *yaml.Node) (any, error) {
+	if d.inAlias > 0 {
+		d.expanded++
+		if d.opts.MaxAliasExpansion > 0 && d.expanded > d.opts.MaxAliasExpansion {
+			return nil, fmt.Errorf("error when decoding alias: document expands to more than %d nodes through aliases", d.opts.MaxAliasExpansion)
+		}
+	}
+
 	var v any
 	var err error
 	switch node.Kind {
 	case yaml.DocumentNode:
 		err = errors.New("unexpected document node")
 	case yaml.AliasNode:
-		err = errors.New("unexpected alias node")
+		return d.decodeAlias(node)
 
 	case yaml.MappingNode:
-		v, err = yamlUnmarshalAnyObject(node)
+		v, err = d.decodeObject(node)
 	case yaml.SequenceNode:
-		v, err = yamlUnmarshalAnyArray(node)
-	default:
+		v, err = d.decodeArray(node)
+	case yaml.ScalarNode:
 		err = node.Decode(&v)
+		if err == nil && d.opts.PreserveScalars {
+			v = NewYAMLScalar(v, node.ShortTag(), node.Value, node.Style)
+		}
+
+	default:
+		err = fmt.Errorf("unexpected node kind: %s", strYamlKind(node.Kind))
 	}
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	x.v = v
-	return nil
+	if d.opts.PreserveAliases && node.Anchor != "" && d.inAlias == 0 {
+		anchor := &YAMLAnchor{Name: node.Anchor, Value: v}
+		d.anchors[node] = anchor
+		return anchor, nil
+	}
+	return v, nil
+}
+
+func (d *yamlDecoder) decodeAlias(node *yaml.Node) (any, error) {
+	target := node.Alias
+	if target == nil {
+		return nil, fmt.Errorf("error when decoding alias: unknown anchor %q", node.Value)
+	}
+
+	if anchor, ok := d.anchors[target]; ok {
+		return anchor, nil
+	}
+
+	if d.resolved[target] {
+		return nil, fmt.Errorf("error when decoding alias: anchor %q contains itself", node.Value)
+	}
+	d.resolved[target] = true
+	d.inAlias++
+	v, err := d.decode(target)
+	d.inAlias--
+	d.resolved[target] = false
+
+	return v, err
+}
+
+// isMergeKey
+//
+// returns true if 'node' is the "<<" key of a merge, following the same rules as yaml.v3.
+func isMergeKey(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.Value == "<<" &&
+		(node.Tag == "" || node.Tag == "!" || node.ShortTag() == "!!merge")
 }
 
-func yamlUnmarshalAnyObject(node *yaml.Node) (*Map[any, any], error) {
+func (d *yamlDecoder) decodeObject(node *yaml.Node) (*Map[any, any], error) {
 	if node.Kind != yaml.MappingNode {
-		return nil, fmt.Errorf("error when decoding object: expected mapping node, got %v", node.Kind)
+		return nil, fmt.Errorf("error when decoding object: expected mapping node, got %v", strYamlKind(node.Kind))
+	}
+	if len(node.Content)%2 != 0 {
+		return nil, errors.New("error when decoding object: odd number of nodes in mapping")
+	}
+
+	// keys listed explicitly in the mapping take precedence over merged keys,
+	// wherever the merge appears in the mapping:
+	keys := make([]any, len(node.Content)/2)
+	explicit := make(map[any]bool)
+	for i := 0; i < len(node.Content); i += 2 {
+		keyNode := node.Content[i]
+		if isMergeKey(keyNode) {
+			continue
+		}
+
+		key, err := d.decodeKey(keyNode)
+		if err != nil {
+			return nil, err
+		}
+		keys[i/2] = key
+		explicit[key] = true
 	}
 
 	var m Map[any, any]
@@ -49,68 +350,125 @@ func yamlUnmarshalAnyObject(node *yaml.Node) (*Map[any, any], error) {
 		keyNode := node.Content[i]
 		valueNode := node.Content[i+1]
 
-		if keyNode.Kind != yaml.ScalarNode {
-			return nil, fmt.Errorf("error when decoding object key: expected scalar node, got %v", strYamlKind(keyNode.Kind))
+		if isMergeKey(keyNode) {
+			err := d.merge(&m, valueNode, explicit)
+			if err != nil {
+				return nil, err
+			}
+			continue
 		}
 
-		var (
-			key   any
-			value any
-			err   error
-		)
-		err = keyNode.Decode(&key)
+		if valueNode.Kind == yaml.DocumentNode {
+			return nil, fmt.Errorf("error when decoding object value: expected a value node, got %v", strYamlKind(valueNode.Kind))
+		}
+		value, err := d.decode(valueNode)
 		if err != nil {
-			return nil, fmt.Errorf("error when decoding object key: %w", err)
+			return nil, err
 		}
 
-		switch valueNode.Kind {
-		case yaml.DocumentNode:
-			return nil, fmt.Errorf("error when decoding object value: expected a value node, got %v", strYamlKind(keyNode.Kind))
-		case yaml.AliasNode:
-			return nil, fmt.Errorf("error when decoding object value: unhadled alias node")
-
-		case yaml.MappingNode:
-			value, err = yamlUnmarshalAnyObject(valueNode)
-		case yaml.SequenceNode:
-			value, err = yamlUnmarshalAnyArray(valueNode)
+		m.Set(keys[i/2], value)
+	}
+	return &m, nil
+}
 
-		case yaml.ScalarNode:
-			err = valueNode.Decode(&value)
+func (d *yamlDecoder) decodeKey(keyNode *yaml.Node) (any, error) {
+	keyNode = resolveYAMLAlias(keyNode)
+	if keyNode.Kind == yaml.SequenceNode || keyNode.Kind == yaml.MappingNode {
+		if !d.opts.ComplexKeys {
+			return nil, fmt.Errorf("error when decoding object key: expected scalar node, got %v (see YAMLOptions.ComplexKeys)", strYamlKind(keyNode.Kind))
 		}
+		return d.decodeComplexKey(keyNode)
+	}
+	if keyNode.Kind != yaml.ScalarNode {
+		return nil, fmt.Errorf("error when decoding object key: expected scalar node, got %v", strYamlKind(keyNode.Kind))
+	}
 
+	var key any
+	err := keyNode.Decode(&key)
+	if err != nil {
+		return nil, fmt.Errorf("error when decoding object key: %w", err)
+	}
+	return key, nil
+}
+
+// decodeComplexKey
+//
+// decodes a sequence or mapping key. The key is decoded first, so that nested keys and aliases
+// are checked, and counted in the alias expansion.
+func (d *yamlDecoder) decodeComplexKey(keyNode *yaml.Node) (any, error) {
+	kd := *d
+	kd.opts.PreserveAliases = false
+	_, err := kd.decode(keyNode)
+	d.expanded = kd.expanded
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := complexKeyFromNode(keyNode)
+	if err != nil {
+		return nil, fmt.Errorf("error when decoding object key: %w", err)
+	}
+	return key, nil
+}
+
+// merge
+//
+// applies a merge key ("<<") on 'm': the entries of the merged mappings are added to 'm',
+// unless their key is listed in 'explicit', or has already been merged from a previous mapping.
+func (d *yamlDecoder) merge(m *Map[any, any], valueNode *yaml.Node, explicit map[any]bool) error {
+	target := valueNode
+	if target.Kind == yaml.AliasNode && target.Alias != nil {
+		target = target.Alias
+	}
+
+	var sources []*yaml.Node
+	switch target.Kind {
+	case yaml.MappingNode:
+		sources = []*yaml.Node{valueNode}
+	case yaml.SequenceNode:
+		sources = target.Content
+	default:
+		return fmt.Errorf("error when decoding merge key: expected a mapping or a sequence of mappings, got %v", strYamlKind(target.Kind))
+	}
+
+	for _, src := range sources {
+		v, err := d.decode(src)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if anchor, ok := v.(*YAMLAnchor); ok {
+			v = anchor.Value
+		}
+		obj, ok := v.(*Map[any, any])
+		if !ok {
+			return fmt.Errorf("error when decoding merge key: expected a mapping, got %T", v)
 		}
 
-		m.Set(key, value)
+		for _, k := range obj.keys {
+			if explicit[k] {
+				continue
+			}
+			if _, ok := m.Get2(k); ok {
+				continue
+			}
+			m.Set(k, obj.m[k])
+		}
 	}
-	return &m, nil
+	return nil
 }
 
-func yamlUnmarshalAnyArray(node *yaml.Node) ([]any, error) {
+func (d *yamlDecoder) decodeArray(node *yaml.Node) ([]any, error) {
 	if node.Kind != yaml.SequenceNode {
-		return nil, fmt.Errorf("error when decoding array: expected sequence node, got %v", node.Kind)
+		return nil, fmt.Errorf("error when decoding array: expected sequence node, got %v", strYamlKind(node.Kind))
 	}
 
 	var res []any
 	for _, valueNode := range node.Content {
-		var value any
-		var err error
-		switch valueNode.Kind {
-		case yaml.DocumentNode:
+		if valueNode.Kind == yaml.DocumentNode {
 			return nil, fmt.Errorf("error when decoding array value: expected a value node, got %v", strYamlKind(valueNode.Kind))
-		case yaml.AliasNode:
-			return nil, fmt.Errorf("error when decoding array value: unhandled alias node")
-
-		case yaml.MappingNode:
-			value, err = yamlUnmarshalAnyObject(valueNode)
-		case yaml.SequenceNode:
-			value, err = yamlUnmarshalAnyArray(valueNode)
-
-		case yaml.ScalarNode:
-			err = valueNode.Decode(&value)
 		}
 
+		value, err := d.decode(valueNode)
 		if err != nil {
 			return nil, err
 		}
@@ -120,3 +478,89 @@ func yamlUnmarshalAnyArray(node *yaml.Node) ([]any, error) {
 
 	return res, nil
 }
+
+// containsYAMLAnchor
+//
+// returns true if 'v' contains a *YAMLAnchor in its objects or arrays.
+func containsYAMLAnchor(v any) bool {
+	found := false
+	_ = Walk(v, func(path Path, v any) error {
+		if _, ok := v.(*YAMLAnchor); ok {
+			found = true
+			return Stop
+		}
+		return nil
+	})
+	return found
+}
+
+// yamlAnchorEncoder
+//
+// converts a tree of values containing *YAMLAnchor values into a tree of yaml nodes,
+// where the first occurrence of each anchor is emitted as an anchored node, and the
+// following occurrences as aliases.
+type yamlAnchorEncoder struct {
+	nodes map[*YAMLAnchor]*yaml.Node
+}
+
+func (e *yamlAnchorEncoder) encode(v any) (*yaml.Node, error) {
+	switch x := v.(type) {
+	case Any:
+		return e.encode(x.v)
+
+	case *YAMLAnchor:
+		if target, ok := e.nodes[x]; ok {
+			return &yaml.Node{Kind: yaml.AliasNode, Value: x.Name, Alias: target}, nil
+		}
+		node, err := e.encode(x.Value)
+		if err != nil {
+			return nil, err
+		}
+		node.Anchor = x.Name
+		e.nodes[x] = node
+		return node, nil
+
+	case []any:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, elt := range x {
+			n, err := e.encode(elt)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, n)
+		}
+		return node, nil
+
+	case anyMap:
+		if reflect.ValueOf(x).IsNil() {
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+		}
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		err := x.eachAny(func(k, v any) error {
+			kn, err := e.encode(k)
+			if err != nil {
+				return err
+			}
+			vn, err := e.encode(v)
+			if err != nil {
+				return err
+			}
+			node.Content = append(node.Content, kn, vn)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return node, nil
+	}
+
+	node := &yaml.Node{}
+	err := node.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	if node.Kind == yaml.DocumentNode {
+		node = node.Content[0]
+	}
+	return node, nil
+}
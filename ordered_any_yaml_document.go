@@ -0,0 +1,994 @@
+package ordmap
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// YAMLDocument is an editable YAML document.
+//
+// It keeps the tree of yaml nodes of the original document, so that comments, quoting,
+// flow or block styles and anchors survive `Set()` and `Delete()`. `Bytes()` applies the
+// edits on the original bytes: the parts of the document which were not edited are
+// returned byte-for-byte.
+//
+// Only the first document of a multi-document stream is read.
+type YAMLDocument struct {
+	src  []byte
+	root *yaml.Node
+
+	// origins holds the position, style and children of the nodes of the original document,
+	// changed holds the original nodes whose value was replaced by `Set()`.
+	origins map[*yaml.Node]yamlOrigin
+	changed map[*yaml.Node]bool
+}
+
+type yamlOrigin struct {
+	kind    yaml.Kind
+	style   yaml.Style
+	line    int
+	column  int
+	value   string
+	content []*yaml.Node
+}
+
+// ParseYAMLDocument parses 'data' into an editable YAMLDocument.
+func ParseYAMLDocument(data []byte) (*YAMLDocument, error) {
+	var root yaml.Node
+	err := yaml.Unmarshal(data, &root)
+	if err != nil {
+		return nil, err
+	}
+	if root.Kind == 0 {
+		root.Kind = yaml.DocumentNode
+	}
+
+	src := make([]byte, len(data))
+	copy(src, data)
+	d := &YAMLDocument{
+		src:     src,
+		root:    &root,
+		origins: make(map[*yaml.Node]yamlOrigin),
+		changed: make(map[*yaml.Node]bool),
+	}
+	d.recordOrigins(&root)
+	return d, nil
+}
+
+func (d *YAMLDocument) recordOrigins(node *yaml.Node) {
+	if _, ok := d.origins[node]; ok {
+		return
+	}
+	d.origins[node] = yamlOrigin{
+		kind:    node.Kind,
+		style:   node.Style,
+		line:    node.Line,
+		column:  node.Column,
+		value:   node.Value,
+		content: append([]*yaml.Node(nil), node.Content...),
+	}
+	for _, c := range node.Content {
+		d.recordOrigins(c)
+	}
+}
+
+func (d *YAMLDocument) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.DocumentNode {
+		node = &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{node}}
+	}
+	d.src = nil
+	d.root = node
+	d.origins = make(map[*yaml.Node]yamlOrigin)
+	d.changed = make(map[*yaml.Node]bool)
+	return nil
+}
+
+// MarshalYAML returns the tree of nodes of the document, with its comments and styles.
+//
+// The yaml encoder re-indents the whole document, use `Bytes()` to keep the unedited
+// parts of the document as they were.
+func (d *YAMLDocument) MarshalYAML() (any, error) {
+	if d.content() == nil {
+		return nil, nil
+	}
+	return d.content(), nil
+}
+
+func (d *YAMLDocument) content() *yaml.Node {
+	if d.root == nil || len(d.root.Content) == 0 {
+		return nil
+	}
+	return d.root.Content[0]
+}
+
+// Get returns the value found at 'path', decoded the same way as `Any.UnmarshalYAML()`.
+// The empty path designates the whole document.
+func (d *YAMLDocument) Get(path Path) (Any, error) {
+	node, err := d.lookup(path)
+	if err != nil {
+		return Any{}, err
+	}
+	if node == nil {
+		return Any{}, nil
+	}
+
+	v, err := newYAMLDecoder(YAMLOptions{}).decode(node)
+	if err != nil {
+		return Any{}, fmt.Errorf("error when decoding %q: %w", path, err)
+	}
+	return Any{v: v}, nil
+}
+
+// Set sets the value at 'path' to 'v'.
+//
+// Missing keys are appended to their mapping, creating intermediate mappings if needed,
+// an index equal to the length of a sequence appends to the sequence.
+// When an existing value is replaced, its comments are kept, and so is its quoting style
+// if the new value is a string replacing a string.
+func (d *YAMLDocument) Set(path Path, v any) error {
+	newNode, err := encodeYAMLNode(v)
+	if err != nil {
+		return fmt.Errorf("error when encoding value for %q: %w", path, err)
+	}
+
+	if len(path) == 0 {
+		if old := d.content(); old != nil {
+			d.replace(nil, old, newNode)
+			return nil
+		}
+		d.root.Content = []*yaml.Node{newNode}
+		return nil
+	}
+
+	if d.content() == nil {
+		d.root.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+
+	parent := d.content()
+	for i, e := range path {
+		parent = resolveYAMLAlias(parent)
+		last := i == len(path)-1
+
+		switch parent.Kind {
+		case yaml.MappingNode:
+			idx := yamlKeyIndex(parent, e)
+			if idx < 0 {
+				keyNode, err := encodeYAMLNode(e.key)
+				if err != nil {
+					return fmt.Errorf("error when encoding key %q: %w", path[:i+1], err)
+				}
+				child := newNode
+				if !last {
+					child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+				}
+				parent.Content = append(parent.Content, keyNode, child)
+				parent = child
+				continue
+			}
+			if last {
+				d.replace(parent.Content[idx], parent.Content[idx+1], newNode)
+				return nil
+			}
+			parent = parent.Content[idx+1]
+
+		case yaml.SequenceNode:
+			idx, err := parseArrayIndex(e.token(), len(parent.Content), last)
+			if err != nil {
+				return fmt.Errorf("error when setting %q: %w", path[:i+1], err)
+			}
+			if idx == len(parent.Content) {
+				parent.Content = append(parent.Content, newNode)
+				return nil
+			}
+			if last {
+				d.replace(nil, parent.Content[idx], newNode)
+				return nil
+			}
+			parent = parent.Content[idx]
+
+		default:
+			return fmt.Errorf("error when setting %q: cannot set a child of a %s", path[:i+1], strYamlKind(parent.Kind))
+		}
+	}
+	return nil
+}
+
+// Delete removes the entry or the sequence element at 'path'.
+func (d *YAMLDocument) Delete(path Path) error {
+	if len(path) == 0 {
+		d.root.Content = nil
+		return nil
+	}
+
+	parent, err := d.lookup(path.Parent())
+	if err != nil {
+		return err
+	}
+	if parent == nil {
+		return fmt.Errorf("error when deleting %q: empty document", path)
+	}
+	parent = resolveYAMLAlias(parent)
+
+	e := path[len(path)-1]
+	switch parent.Kind {
+	case yaml.MappingNode:
+		idx := yamlKeyIndex(parent, e)
+		if idx < 0 {
+			return fmt.Errorf("error when deleting %q: key %q not found", path, e.token())
+		}
+		parent.Content = append(parent.Content[:idx:idx], parent.Content[idx+2:]...)
+
+	case yaml.SequenceNode:
+		idx, err := parseArrayIndex(e.token(), len(parent.Content), false)
+		if err != nil {
+			return fmt.Errorf("error when deleting %q: %w", path, err)
+		}
+		parent.Content = append(parent.Content[:idx:idx], parent.Content[idx+1:]...)
+
+	default:
+		return fmt.Errorf("error when deleting %q: cannot delete a child of a %s", path, strYamlKind(parent.Kind))
+	}
+	return nil
+}
+
+// Bytes returns the YAML representation of the document.
+//
+// The edits are applied on the original bytes, and the rest of the document is returned
+// as it was:
+//   - a scalar replaced by a scalar is rewritten in place,
+//   - a deleted entry or sequence element is removed along with its lines (and the comment
+//     lines right above it),
+//   - new entries and sequence elements are inserted after the last line of their last
+//     sibling, at the indentation of their siblings,
+//   - a flow collection which was edited is rewritten on its line,
+//   - a value replaced by a value of another kind is rewritten along with its key.
+//
+// When an edit can not be applied this way (e.g. a new value which can not be written inside a
+// flow collection, or a replaced document root), the tree of nodes is encoded again: comments
+// and styles are kept, but the indentation is normalized (to the indentation detected in the
+// original document) and blank lines are dropped.
+func (d *YAMLDocument) Bytes() ([]byte, error) {
+	if d.src != nil {
+		if res, ok := d.splice(); ok {
+			return res, nil
+		}
+	}
+
+	if d.content() == nil {
+		return []byte{}, nil
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(detectYAMLIndent(d.src))
+	err := enc.Encode(d.root)
+	if err != nil {
+		return nil, err
+	}
+	err = enc.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// lookup
+//
+// returns the node at 'path', following aliases on the way.
+func (d *YAMLDocument) lookup(path Path) (*yaml.Node, error) {
+	node := d.content()
+	if node == nil {
+		if len(path) > 0 {
+			return nil, fmt.Errorf("error when looking up %q: empty document", path)
+		}
+		return nil, nil
+	}
+
+	for i, e := range path {
+		node = resolveYAMLAlias(node)
+		switch node.Kind {
+		case yaml.MappingNode:
+			idx := yamlKeyIndex(node, e)
+			if idx < 0 {
+				return nil, fmt.Errorf("error when looking up %q: key %q not found", path[:i+1], e.token())
+			}
+			node = node.Content[idx+1]
+
+		case yaml.SequenceNode:
+			idx, err := parseArrayIndex(e.token(), len(node.Content), false)
+			if err != nil {
+				return nil, fmt.Errorf("error when looking up %q: %w", path[:i+1], err)
+			}
+			node = node.Content[idx]
+
+		default:
+			return nil, fmt.Errorf("error when looking up %q: cannot look up a child of a %s", path[:i+1], strYamlKind(node.Kind))
+		}
+	}
+	return node, nil
+}
+
+// replace
+//
+// replaces the value 'old' (the value of 'keyNode' when it is the value of a mapping entry) with 'newNode'.
+// 'old' is updated in place, and recorded as changed so that it can be spliced in the original bytes.
+func (d *YAMLDocument) replace(keyNode, old, newNode *yaml.Node) {
+	d.changed[old] = true
+
+	if old.Kind == yaml.ScalarNode && newNode.Kind == yaml.ScalarNode {
+
+		style := newNode.Style
+		quoted := old.Style & (yaml.DoubleQuotedStyle | yaml.SingleQuotedStyle)
+		if quoted != 0 && old.ShortTag() == "!!str" && newNode.ShortTag() == "!!str" && !strings.Contains(newNode.Value, "\n") {
+			style = quoted
+		}
+		old.Tag = newNode.Tag
+		old.Value = newNode.Value
+		old.Style = style
+		return
+	}
+
+	newNode.HeadComment = old.HeadComment
+	newNode.LineComment = old.LineComment
+	newNode.FootComment = old.FootComment
+	if keyNode != nil && newNode.Kind != yaml.ScalarNode && newNode.Style&yaml.FlowStyle == 0 && keyNode.LineComment == "" {
+		// the line comment of a block collection is emitted after its first entry,
+		// keep it on the line of the key:
+		keyNode.LineComment = newNode.LineComment
+		newNode.LineComment = ""
+	}
+	newNode.Anchor = old.Anchor
+	*old = *newNode
+}
+
+// splice
+//
+// applies the edits of the document on the original bytes.
+// Returns false if one of the edits can not be located in the original bytes, or if the result
+// does not decode to the same values as the tree of nodes.
+func (d *YAMLDocument) splice() ([]byte, bool) {
+	sp := &yamlSplicer{
+		doc:        d,
+		lineStarts: []int{0},
+		indent:     detectYAMLIndent(d.src),
+	}
+	for i, c := range d.src {
+		if c == '\n' {
+			sp.lineStarts = append(sp.lineStarts, i+1)
+		}
+	}
+
+	if !sp.walk(d.root) {
+		return nil, false
+	}
+
+	res := make([]byte, len(d.src))
+	copy(res, d.src)
+	if len(sp.edits) == 0 {
+		return res, true
+	}
+
+	// apply the edits from the end of the document, so that the offsets of the remaining edits
+	// stay valid. Edits recorded later are applied first at the same offset: the entries inserted
+	// in a parent mapping come after the ones inserted in its last child.
+	edits := sp.edits
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].start != edits[j].start {
+			return edits[i].start > edits[j].start
+		}
+		if edits[i].end != edits[j].end {
+			return edits[i].end > edits[j].end
+		}
+		return i > j
+	})
+	for _, e := range edits {
+		res = append(res[:e.start:e.start], append([]byte(e.text), res[e.end:]...)...)
+	}
+
+	// check that the spliced document decodes to the edited values (a plain scalar
+	// which is valid in a block mapping may not be valid inside a flow sequence, ...):
+	if d.content() == nil {
+		return nil, false
+	}
+	var check yaml.Node
+	if err := yaml.Unmarshal(res, &check); err != nil || len(check.Content) == 0 {
+		return nil, false
+	}
+	expected, err1 := newYAMLDecoder(YAMLOptions{}).decode(d.content())
+	actual, err2 := newYAMLDecoder(YAMLOptions{}).decode(check.Content[0])
+	if err1 != nil || err2 != nil || !reflect.DeepEqual(expected, actual) {
+		return nil, false
+	}
+	return res, true
+}
+
+// yamlSplicer
+//
+// compares the tree of nodes of a YAMLDocument with its original nodes, and collects the edits
+// to apply on the original bytes.
+type yamlSplicer struct {
+	doc        *YAMLDocument
+	lineStarts []int
+	indent     int
+	edits      []yamlEdit
+}
+
+type yamlEdit struct {
+	start, end int
+	text       string
+}
+
+// yamlEntry
+//
+// is an entry of a block mapping (key and value) or an element of a block sequence (nil key).
+// 'line' and 'column' locate the key, or the '-' of a sequence element, in the original bytes.
+type yamlEntry struct {
+	key    *yaml.Node
+	value  *yaml.Node
+	line   int
+	column int
+}
+
+func (sp *yamlSplicer) edit(start, end int, text string) {
+	sp.edits = append(sp.edits, yamlEdit{start: start, end: end, text: text})
+}
+
+// walk
+//
+// collects the edits of the original node 'node' and of its children.
+func (sp *yamlSplicer) walk(node *yaml.Node) bool {
+	o := sp.doc.origins[node]
+
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if !sameYAMLNodes(node.Content, o.content) {
+			return false
+		}
+		for _, c := range node.Content {
+			if !sp.value(nil, c) {
+				return false
+			}
+		}
+		return true
+
+	case yaml.MappingNode, yaml.SequenceNode:
+		if o.style&yaml.FlowStyle == 0 {
+			return sp.block(node, o)
+		}
+		if !sameYAMLNodes(node.Content, o.content) {
+			start, end, ok := sp.inlineSpan(o)
+			if !ok {
+				return false
+			}
+			text, ok := renderYAMLInline(node)
+			if !ok {
+				return false
+			}
+			sp.edit(start, end, text)
+			return true
+		}
+		for i, c := range node.Content {
+			if node.Kind == yaml.MappingNode && i%2 == 0 {
+				continue
+			}
+			if !sp.value(nil, c) {
+				return false
+			}
+		}
+		return true
+	}
+	return true
+}
+
+// value
+//
+// collects the edits of 'node', the original value of 'e' ('e' is nil for the document root
+// and in flow collections).
+func (sp *yamlSplicer) value(e *yamlEntry, node *yaml.Node) bool {
+	if !sp.doc.changed[node] {
+		return sp.walk(node)
+	}
+
+	// a scalar, or a flow collection, replaced by a value which fits on its line:
+	o := sp.doc.origins[node]
+	if start, end, ok := sp.inlineSpan(o); ok {
+		if text, ok := renderYAMLInline(node); ok {
+			sp.edit(start, end, text)
+			return true
+		}
+	}
+
+	// otherwise, the whole entry is written again:
+	if e == nil {
+		return false
+	}
+	start, end, ok := sp.entryLines(e, false)
+	if !ok {
+		return false
+	}
+	key := e.key
+	if key != nil {
+		k := *key
+		k.HeadComment = ""
+		key = &k
+	}
+	v := *node
+	if key == nil {
+		v.HeadComment = ""
+	}
+	text, ok := sp.render(key != nil, []*yamlEntry{{key: key, value: &v}}, e.column-1)
+	if !ok {
+		return false
+	}
+	sp.edit(start, end, text)
+	return true
+}
+
+// block
+//
+// collects the edits of the block mapping or block sequence 'node': deleted entries are removed,
+// new entries are inserted after the last original entry.
+func (sp *yamlSplicer) block(node *yaml.Node, o yamlOrigin) bool {
+	isMap := node.Kind == yaml.MappingNode
+	step := 1
+	if isMap {
+		step = 2
+	}
+
+	var entries []*yamlEntry
+	for i := 0; i+step-1 < len(o.content); i += step {
+		e := &yamlEntry{value: o.content[i]}
+		if isMap {
+			e.key, e.value = o.content[i], o.content[i+1]
+			ko := sp.doc.origins[e.key]
+			e.line, e.column = ko.line, ko.column
+		} else {
+			vo := sp.doc.origins[e.value]
+			e.line, e.column = vo.line, sp.dashColumn(vo)
+		}
+		entries = append(entries, e)
+	}
+
+	cur := node.Content
+	for _, e := range entries {
+		first := e.value
+		if isMap {
+			first = e.key
+		}
+		if len(cur) >= step && cur[0] == first {
+			if cur[step-1] != e.value || !sp.value(e, e.value) {
+				return false
+			}
+			cur = cur[step:]
+			continue
+		}
+
+		start, end, ok := sp.entryLines(e, true)
+		if !ok {
+			return false
+		}
+		sp.edit(start, end, "")
+	}
+
+	if len(cur) == 0 {
+		return true
+	}
+	if len(entries) == 0 {
+		return false
+	}
+	var added []*yamlEntry
+	for i := 0; i+step-1 < len(cur); i += step {
+		if _, ok := sp.doc.origins[cur[i]]; ok {
+			return false
+		}
+		e := &yamlEntry{value: cur[i]}
+		if isMap {
+			e.key, e.value = cur[i], cur[i+1]
+		}
+		added = append(added, e)
+	}
+
+	last := entries[len(entries)-1]
+	end, ok := sp.entryEnd(last)
+	if !ok {
+		return false
+	}
+	text, ok := sp.render(isMap, added, last.column-1)
+	if !ok {
+		return false
+	}
+	if end == len(sp.doc.src) && !sp.atLineStart(end) {
+		text = "\n" + text
+	}
+	sp.edit(end, end, text)
+	return true
+}
+
+// atLineStart
+//
+// tells if the offset 'pos' of the original bytes is at the start of a line once the edits
+// collected so far are applied (e.g: the last line lacks a '\n', but has been deleted).
+func (sp *yamlSplicer) atLineStart(pos int) bool {
+	for {
+		moved := false
+		for _, e := range sp.edits {
+			if e.end != pos || (e.start == pos && e.text == "") {
+				continue
+			}
+			if e.text != "" {
+				return e.text[len(e.text)-1] == '\n'
+			}
+			pos, moved = e.start, true
+			break
+		}
+		if !moved {
+			break
+		}
+	}
+	return pos == 0 || sp.doc.src[pos-1] == '\n'
+}
+
+// entryLines
+//
+// returns the range of the lines of the entry 'e' in the original bytes: see `entryEnd()`, and,
+// if 'withHead' is set, the comment lines right above it at the same indentation.
+// The key (or the '-') must be the first thing on its line.
+func (sp *yamlSplicer) entryLines(e *yamlEntry, withHead bool) (int, int, bool) {
+	end, ok := sp.entryEnd(e)
+	if !ok {
+		return 0, 0, false
+	}
+	lineStart := sp.lineStarts[e.line-1]
+	keyStart, _ := runeOffset(sp.doc.src, lineStart, e.column-1)
+	if len(bytes.TrimLeft(sp.doc.src[lineStart:keyStart], " ")) != 0 {
+		return 0, 0, false
+	}
+
+	start := lineStart
+	for l := e.line - 1; withHead && l >= 1; l-- {
+		text := sp.lineText(l)
+		trimmed := bytes.TrimLeft(text, " ")
+		if len(trimmed) == 0 || trimmed[0] != '#' || len(text)-len(trimmed) != keyStart-lineStart {
+			break
+		}
+		start = sp.lineStarts[l-1]
+	}
+	return start, end, true
+}
+
+// entryEnd
+//
+// returns the offset of the end of the last line of the entry 'e' in the original bytes.
+// The entry spans the line of its key (or of its '-'), and the following lines which are
+// indented deeper. Trailing blank lines, and trailing comments which are not indented deeper,
+// are not part of the entry.
+func (sp *yamlSplicer) entryEnd(e *yamlEntry) (int, bool) {
+	src := sp.doc.src
+	if e.line < 1 || e.line > len(sp.lineStarts) || e.column < 1 {
+		return 0, false
+	}
+	lineStart := sp.lineStarts[e.line-1]
+	keyStart, ok := runeOffset(src, lineStart, e.column-1)
+	if !ok {
+		return 0, false
+	}
+	indent := keyStart - lineStart
+
+	last := e.line
+	for l := e.line + 1; l <= len(sp.lineStarts); l++ {
+		text := sp.lineText(l)
+		trimmed := bytes.TrimLeft(text, " ")
+		n := len(text) - len(trimmed)
+		if len(bytes.TrimSpace(trimmed)) == 0 || (trimmed[0] == '#' && n <= indent) {
+			continue
+		}
+		if trimmed[0] != '#' {
+			// a block sequence at the indentation of a key is the value of the key:
+			seqOfKey := e.key != nil && n == indent && trimmed[0] == '-' && (len(trimmed) == 1 || trimmed[1] == ' ')
+			if n < indent || (n == indent && !seqOfKey) {
+				break
+			}
+		}
+		last = l
+	}
+
+	if last < len(sp.lineStarts) {
+		return sp.lineStarts[last], true
+	}
+	return len(src), true
+}
+
+// lineText
+//
+// returns the text of the line 'l' (1-based), without its line ending.
+func (sp *yamlSplicer) lineText(l int) []byte {
+	src := sp.doc.src
+	start := sp.lineStarts[l-1]
+	end := len(src)
+	if l < len(sp.lineStarts) {
+		end = sp.lineStarts[l] - 1
+	}
+	return bytes.TrimSuffix(src[start:end], []byte("\r"))
+}
+
+// dashColumn
+//
+// returns the column of the '-' which introduces the sequence element 'o', or 0.
+func (sp *yamlSplicer) dashColumn(o yamlOrigin) int {
+	if o.line < 1 || o.line > len(sp.lineStarts) {
+		return 0
+	}
+	lineStart := sp.lineStarts[o.line-1]
+	i, ok := runeOffset(sp.doc.src, lineStart, o.column-1)
+	if !ok {
+		return 0
+	}
+	i--
+	for i >= lineStart && sp.doc.src[i] == ' ' {
+		i--
+	}
+	if i < lineStart || sp.doc.src[i] != '-' {
+		return 0
+	}
+	return utf8.RuneCount(sp.doc.src[lineStart:i]) + 1
+}
+
+// inlineSpan
+//
+// returns the range of the original scalar or flow collection 'o', when it is written on a single line.
+func (sp *yamlSplicer) inlineSpan(o yamlOrigin) (int, int, bool) {
+	if o.line < 1 || o.line > len(sp.lineStarts) {
+		return 0, 0, false
+	}
+	start, ok := runeOffset(sp.doc.src, sp.lineStarts[o.line-1], o.column-1)
+	if !ok {
+		return 0, 0, false
+	}
+
+	var end int
+	switch {
+	case o.kind == yaml.ScalarNode:
+		end, ok = yamlScalarEnd(sp.doc.src, start, o)
+	case o.style&yaml.FlowStyle != 0:
+		end, ok = yamlFlowEnd(sp.doc.src, start)
+	default:
+		ok = false
+	}
+	return start, end, ok
+}
+
+// render
+//
+// returns the yaml representation of the mapping entries (or sequence elements) 'entries',
+// indented by 'indent' spaces.
+func (sp *yamlSplicer) render(isMap bool, entries []*yamlEntry, indent int) (string, bool) {
+	node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	if isMap {
+		node = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	}
+	for _, e := range entries {
+		if isMap {
+			node.Content = append(node.Content, e.key)
+		}
+		node.Content = append(node.Content, e.value)
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(sp.indent)
+	if err := enc.Encode(node); err != nil {
+		return "", false
+	}
+	if err := enc.Close(); err != nil {
+		return "", false
+	}
+
+	prefix := strings.Repeat(" ", indent)
+	var res strings.Builder
+	for _, line := range strings.SplitAfter(buf.String(), "\n") {
+		if strings.TrimSpace(line) != "" {
+			res.WriteString(prefix)
+		}
+		res.WriteString(line)
+	}
+	return res.String(), true
+}
+
+func sameYAMLNodes(a, b []*yaml.Node) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// runeOffset
+//
+// returns the byte offset of the 'n'-th rune after 'start', on the same line.
+func runeOffset(src []byte, start int, n int) (int, bool) {
+	i := start
+	for ; n > 0; n-- {
+		if i >= len(src) || src[i] == '\n' {
+			return 0, false
+		}
+		_, size := utf8.DecodeRune(src[i:])
+		i += size
+	}
+	return i, true
+}
+
+// yamlScalarEnd
+//
+// returns the offset of the end of the single line scalar 'o', which starts at 'start' in 'src'.
+func yamlScalarEnd(src []byte, start int, o yamlOrigin) (int, bool) {
+	line := src[start:]
+	if idx := bytes.IndexByte(line, '\n'); idx >= 0 {
+		line = line[:idx]
+	}
+
+	switch o.style {
+	case 0:
+		if o.value == "" || !bytes.HasPrefix(line, []byte(o.value)) {
+			return 0, false
+		}
+		return start + len(o.value), true
+
+	case yaml.DoubleQuotedStyle:
+		if len(line) == 0 || line[0] != '"' {
+			return 0, false
+		}
+		for i := 1; i < len(line); i++ {
+			switch line[i] {
+			case '\\':
+				i++
+			case '"':
+				return start + i + 1, true
+			}
+		}
+
+	case yaml.SingleQuotedStyle:
+		if len(line) == 0 || line[0] != '\'' {
+			return 0, false
+		}
+		for i := 1; i < len(line); i++ {
+			if line[i] != '\'' {
+				continue
+			}
+			if i+1 < len(line) && line[i+1] == '\'' {
+				i++
+				continue
+			}
+			return start + i + 1, true
+		}
+	}
+
+	// tagged, literal or folded scalars, or scalars on several lines:
+	return 0, false
+}
+
+// yamlFlowEnd
+//
+// returns the offset of the end of the flow collection which starts at 'start' in 'src',
+// if it ends on the same line.
+func yamlFlowEnd(src []byte, start int) (int, bool) {
+	if start >= len(src) || (src[start] != '[' && src[start] != '{') {
+		return 0, false
+	}
+	depth := 0
+	for i := start; i < len(src) && src[i] != '\n'; i++ {
+		switch src[i] {
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+			if depth == 0 {
+				return i + 1, true
+			}
+		case '"':
+			for i++; i < len(src) && src[i] != '"' && src[i] != '\n'; i++ {
+				if src[i] == '\\' {
+					i++
+				}
+			}
+		case '\'':
+			for i++; i < len(src) && src[i] != '\n'; i++ {
+				if src[i] == '\'' {
+					if i+1 < len(src) && src[i+1] == '\'' {
+						i++
+						continue
+					}
+					break
+				}
+			}
+		case '#':
+			if src[i-1] == ' ' || src[i-1] == '\t' {
+				return 0, false
+			}
+		}
+	}
+	return 0, false
+}
+
+// renderYAMLInline
+//
+// returns the representation of the scalar or flow collection 'node' (without its comments),
+// or false if it does not fit on one line.
+func renderYAMLInline(node *yaml.Node) (string, bool) {
+	n := *node
+	n.HeadComment, n.LineComment, n.FootComment = "", "", ""
+	bs, err := yaml.Marshal(&n)
+	if err != nil {
+		return "", false
+	}
+	s := strings.TrimSuffix(string(bs), "\n")
+	if strings.Contains(s, "\n") {
+		return "", false
+	}
+	return s, true
+}
+
+// detectYAMLIndent
+//
+// returns the smallest indentation used in 'src', or the default indentation of the yaml encoder.
+func detectYAMLIndent(src []byte) int {
+	indent := 0
+	for _, line := range bytes.Split(src, []byte("\n")) {
+		trimmed := bytes.TrimLeft(line, " ")
+		if len(trimmed) == 0 || trimmed[0] == '#' {
+			continue
+		}
+		n := len(line) - len(trimmed)
+		if n > 0 && (indent == 0 || n < indent) {
+			indent = n
+		}
+	}
+	if indent < 2 {
+		return 4
+	}
+	return indent
+}
+
+func resolveYAMLAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+// yamlKeyIndex
+//
+// returns the index in 'node.Content' of the key designated by 'e', or -1.
+// String keys of 'e' match the text of scalar keys, other keys are compared to the decoded keys.
+func yamlKeyIndex(node *yaml.Node, e PathElem) int {
+	if e.isIndex {
+		e = KeyElem(e.token())
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode := resolveYAMLAlias(node.Content[i])
+		if keyNode.Kind != yaml.ScalarNode || isMergeKey(keyNode) {
+			continue
+		}
+		if s, ok := e.key.(string); ok {
+			if keyNode.Value == s {
+				return i
+			}
+			continue
+		}
+
+		var key any
+		if err := keyNode.Decode(&key); err == nil && reflect.TypeOf(key) == reflect.TypeOf(e.key) && key == e.key {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,270 @@
+package ordmap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const testYAMLDocument = `# deployment settings
+name: "web"   # quoted on purpose
+
+replicas: 3
+image:   nginx:1.25
+ports: [80, 443]
+
+# labels are sorted by hand
+labels:
+   zone: eu-west
+   tier:  'front'
+`
+
+func mustParseYAMLDocument(t *testing.T, input string) *YAMLDocument {
+	t.Helper()
+	doc, err := ParseYAMLDocument([]byte(input))
+	require.NoError(t, err)
+	return doc
+}
+
+func mustPath(t *testing.T, s string) Path {
+	t.Helper()
+	p, err := ParsePath(s)
+	require.NoError(t, err)
+	return p
+}
+
+func yamlDocumentString(t *testing.T, doc *YAMLDocument) string {
+	t.Helper()
+	bs, err := doc.Bytes()
+	require.NoError(t, err)
+	return string(bs)
+}
+
+func TestYAMLDocument_Untouched(t *testing.T) {
+	doc := mustParseYAMLDocument(t, testYAMLDocument)
+	assert.Equal(t, testYAMLDocument, yamlDocumentString(t, doc))
+
+	x, err := doc.Get(nil)
+	require.NoError(t, err)
+	assert.Equal(t, []any{"name", "replicas", "image", "ports", "labels"}, x.V().(*Map[any, any]).Keys())
+
+	v, err := doc.Get(mustPath(t, "labels.tier"))
+	require.NoError(t, err)
+	assert.Equal(t, "front", v.V())
+
+	v, err = doc.Get(mustPath(t, "ports[1]"))
+	require.NoError(t, err)
+	assert.Equal(t, 443, v.V())
+
+	_, err = doc.Get(mustPath(t, "labels.missing"))
+	assert.Error(t, err)
+	_, err = doc.Get(mustPath(t, "ports[2]"))
+	assert.Error(t, err)
+	_, err = doc.Get(mustPath(t, "replicas.x"))
+	assert.Error(t, err)
+}
+
+func TestYAMLDocument_SpliceScalars(t *testing.T) {
+	doc := mustParseYAMLDocument(t, testYAMLDocument)
+
+	require.NoError(t, doc.Set(mustPath(t, "name"), "api"))
+	require.NoError(t, doc.Set(mustPath(t, "replicas"), 5))
+	require.NoError(t, doc.Set(mustPath(t, "image"), "nginx:1.27"))
+	require.NoError(t, doc.Set(mustPath(t, "ports[1]"), 8443))
+	require.NoError(t, doc.Set(mustPath(t, "labels.tier"), "back"))
+	require.NoError(t, doc.Set(mustPath(t, "labels.zone"), "true"))
+
+	// only the edited scalars are rewritten, quoting styles are kept,
+	// and strings which would be read as another type are quoted:
+	expected := `# deployment settings
+name: "api"   # quoted on purpose
+
+replicas: 5
+image:   nginx:1.27
+ports: [80, 8443]
+
+# labels are sorted by hand
+labels:
+   zone: "true"
+   tier:  'back'
+`
+	assert.Equal(t, expected, yamlDocumentString(t, doc))
+
+	// a value which is not valid in a flow sequence falls back to encoding the nodes:
+	require.NoError(t, doc.Set(mustPath(t, "ports[0]"), "a, b"))
+	out := yamlDocumentString(t, doc)
+	assert.Contains(t, out, `ports: ['a, b', 8443]`)
+	var back Any
+	require.NoError(t, yaml.Unmarshal([]byte(out), &back))
+	assert.Equal(t, []any{"a, b", 8443}, back.V().(*Map[any, any]).Get("ports"))
+}
+
+func TestYAMLDocument_Restructure(t *testing.T) {
+	doc := mustParseYAMLDocument(t, testYAMLDocument)
+
+	require.NoError(t, doc.Delete(mustPath(t, "replicas")))
+	require.NoError(t, doc.Set(mustPath(t, "labels.app"), "web"))
+	require.NoError(t, doc.Set(mustPath(t, "resources.limits.cpu"), "500m"))
+	require.NoError(t, doc.Set(mustPath(t, "ports[2]"), 8080))
+
+	// the edits are spliced in the original bytes, untouched lines are kept as they were,
+	// new entries use the indentation of their siblings:
+	expected := `# deployment settings
+name: "web"   # quoted on purpose
+
+image:   nginx:1.25
+ports: [80, 443, 8080]
+
+# labels are sorted by hand
+labels:
+   zone: eu-west
+   tier:  'front'
+   app: web
+resources:
+   limits:
+      cpu: 500m
+`
+	assert.Equal(t, expected, yamlDocumentString(t, doc))
+
+	assert.Error(t, doc.Delete(mustPath(t, "replicas")))
+	assert.Error(t, doc.Delete(mustPath(t, "ports[3]")))
+	assert.Error(t, doc.Set(mustPath(t, "name.first"), "x"))
+	assert.Error(t, doc.Set(mustPath(t, "ports[5]"), 1))
+}
+
+func TestYAMLDocument_SpliceStructure(t *testing.T) {
+	input := `# services
+services:
+  # the web frontend
+  web:
+    image:  nginx   # pinned
+    ports:
+    - 80
+    - 443
+
+  # the database
+  db:
+    image:  postgres
+    env:
+      - name: USER
+        value:   admin
+    volumes: [data]   # named volume
+version: 3
+`
+	doc := mustParseYAMLDocument(t, input)
+
+	require.NoError(t, doc.Delete(mustPath(t, "services.db")))
+	require.NoError(t, doc.Set(mustPath(t, "services.web.ports[2]"), 8080))
+	require.NoError(t, doc.Delete(mustPath(t, "services.web.ports[0]")))
+	require.NoError(t, doc.Set(mustPath(t, "services.cache.image"), "redis"))
+
+	// the deleted entry is removed with its head comment, the blank line before it is kept:
+	expected := `# services
+services:
+  # the web frontend
+  web:
+    image:  nginx   # pinned
+    ports:
+    - 443
+    - 8080
+
+  cache:
+    image: redis
+version: 3
+`
+	assert.Equal(t, expected, yamlDocumentString(t, doc))
+
+	doc = mustParseYAMLDocument(t, input)
+	require.NoError(t, doc.Set(mustPath(t, "services.db.env[0].secret"), true))
+	require.NoError(t, doc.Set(mustPath(t, "services.db.env[1]"), map[string]any{"name": "DB"}))
+	require.NoError(t, doc.Set(mustPath(t, "services.db.volumes[1]"), "logs"))
+	require.NoError(t, doc.Set(mustPath(t, "services.web.ports"), "80-443"))
+	require.NoError(t, doc.Set(mustPath(t, "version"), []any{3, 4}))
+
+	// a replaced block value is written again with its key, the other lines are left untouched:
+	expected = `# services
+services:
+  # the web frontend
+  web:
+    image:  nginx   # pinned
+    ports: 80-443
+
+  # the database
+  db:
+    image:  postgres
+    env:
+      - name: USER
+        value:   admin
+        secret: true
+      - name: DB
+    volumes: [data, logs]   # named volume
+version:
+  - 3
+  - 4
+`
+	assert.Equal(t, expected, yamlDocumentString(t, doc))
+}
+
+func TestYAMLDocument_SpliceFallback(t *testing.T) {
+	// no final newline:
+	doc := mustParseYAMLDocument(t, "a:   1\nb: 2")
+	require.NoError(t, doc.Set(mustPath(t, "c"), 3))
+	assert.Equal(t, "a:   1\nb: 2\nc: 3\n", yamlDocumentString(t, doc))
+
+	// ... and the line lacking it is deleted:
+	doc = mustParseYAMLDocument(t, "a:   1\nb: 2")
+	require.NoError(t, doc.Delete(mustPath(t, "b")))
+	require.NoError(t, doc.Set(mustPath(t, "new.z"), 1))
+	assert.Equal(t, "a:   1\nnew:\n    z: 1\n", yamlDocumentString(t, doc))
+
+	// a replaced document root is encoded again:
+	doc = mustParseYAMLDocument(t, "a:   1\n")
+	require.NoError(t, doc.Set(nil, []any{1}))
+	assert.Equal(t, "- 1\n", yamlDocumentString(t, doc))
+
+	doc = mustParseYAMLDocument(t, "a:   1\n")
+	require.NoError(t, doc.Delete(nil))
+	assert.Equal(t, "", yamlDocumentString(t, doc))
+}
+
+func TestYAMLDocument_SetValues(t *testing.T) {
+	doc := mustParseYAMLDocument(t, "a: 1 # one\nb: [x]\n")
+
+	m := &Map[string, any]{}
+	m.Set("z", 1)
+	m.Set("y", []any{true})
+	require.NoError(t, doc.Set(mustPath(t, "a"), m))
+	require.NoError(t, doc.Set(mustPath(t, "b[0]"), NewAny(nil)))
+
+	expected := `a: # one
+    z: 1
+    "y":
+        - true
+b: [null]
+`
+	assert.Equal(t, expected, yamlDocumentString(t, doc))
+
+	// a YAMLDocument can be embedded in another document:
+	out, err := yaml.Marshal(map[string]any{"doc": doc})
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "# one")
+
+	var other YAMLDocument
+	require.NoError(t, yaml.Unmarshal([]byte("k: v # comment\n"), &other))
+	require.NoError(t, other.Set(mustPath(t, "k"), "w"))
+	assert.Equal(t, "k: w # comment\n", yamlDocumentString(t, &other))
+}
+
+func TestYAMLDocument_Empty(t *testing.T) {
+	doc := mustParseYAMLDocument(t, "")
+	assert.Equal(t, "", yamlDocumentString(t, doc))
+
+	x, err := doc.Get(nil)
+	require.NoError(t, err)
+	assert.Nil(t, x.V())
+
+	require.NoError(t, doc.Set(mustPath(t, "a.b"), 1))
+	assert.Equal(t, "a:\n    b: 1\n", yamlDocumentString(t, doc))
+}
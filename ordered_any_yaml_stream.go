@@ -0,0 +1,101 @@
+package ordmap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// YAMLStreamDecoder reads the documents of a YAML stream ("---" separated documents,
+// such as Kubernetes manifests) one at a time.
+type YAMLStreamDecoder struct {
+	dec   *yaml.Decoder
+	opts  YAMLOptions
+	index int
+}
+
+// NewYAMLStreamDecoder returns a YAMLStreamDecoder which reads from 'r'.
+func NewYAMLStreamDecoder(r io.Reader) *YAMLStreamDecoder {
+	return NewYAMLStreamDecoderWith(r, YAMLOptions{})
+}
+
+// NewYAMLStreamDecoderWith is the same as `NewYAMLStreamDecoder()`, with options applied to
+// each document decoded by `Next()`.
+func NewYAMLStreamDecoderWith(r io.Reader, opts YAMLOptions) *YAMLStreamDecoder {
+	return &YAMLStreamDecoder{dec: yaml.NewDecoder(r), opts: opts}
+}
+
+// Next decodes the next document of the stream. An empty document is decoded as a nil value.
+//
+// Next returns io.EOF when there are no more documents.
+func (d *YAMLStreamDecoder) Next() (Any, error) {
+	var x Any
+	node, err := d.next()
+	if err != nil {
+		return Any{}, err
+	}
+	err = x.UnmarshalYAMLWith(node, d.opts)
+	if err != nil {
+		return Any{}, fmt.Errorf("error when decoding yaml document %d: %w", d.index-1, err)
+	}
+	return x, nil
+}
+
+// Decode decodes the next document of the stream into 'v', for example a `*Map[string, any]`.
+//
+// Decode returns io.EOF when there are no more documents.
+func (d *YAMLStreamDecoder) Decode(v any) error {
+	node, err := d.next()
+	if err != nil {
+		return err
+	}
+	err = node.Decode(v)
+	if err != nil {
+		return fmt.Errorf("error when decoding yaml document %d: %w", d.index-1, err)
+	}
+	return nil
+}
+
+func (d *YAMLStreamDecoder) next() (*yaml.Node, error) {
+	var node yaml.Node
+	err := d.dec.Decode(&node)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("error when reading yaml document %d: %w", d.index, err)
+	}
+	d.index++
+	return &node, nil
+}
+
+// DecodeYAMLStream decodes all the documents of the YAML stream read from 'r', in order.
+func DecodeYAMLStream(r io.Reader) ([]Any, error) {
+	dec := NewYAMLStreamDecoder(r)
+
+	var res []Any
+	for {
+		x, err := dec.Next()
+		if err == io.EOF {
+			return res, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, x)
+	}
+}
+
+// EncodeYAMLStream writes 'docs' to 'w' as a YAML stream, separating documents with "---".
+func EncodeYAMLStream(w io.Writer, docs ...any) error {
+	enc := yaml.NewEncoder(w)
+	for i, doc := range docs {
+		err := enc.Encode(doc)
+		if err != nil {
+			return fmt.Errorf("error when encoding yaml document %d: %w", i, err)
+		}
+	}
+	return enc.Close()
+}
@@ -0,0 +1,79 @@
+package ordmap
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testYAMLStream = `apiVersion: v1
+kind: Service
+metadata:
+    name: web
+---
+apiVersion: apps/v1
+kind: Deployment
+spec:
+    replicas: 2
+    selector:
+        app: web
+---
+- z
+- a
+`
+
+func TestDecodeYAMLStream(t *testing.T) {
+	docs, err := DecodeYAMLStream(strings.NewReader(testYAMLStream))
+	require.NoError(t, err)
+	require.Len(t, docs, 3)
+
+	assert.Equal(t, []any{"apiVersion", "kind", "metadata"}, docs[0].V().(*Map[any, any]).Keys())
+	assert.Equal(t, "Deployment", docs[1].V().(*Map[any, any]).Get("kind"))
+	assert.Equal(t, []any{"z", "a"}, docs[2].V())
+
+	// round trip:
+	var buf bytes.Buffer
+	err = EncodeYAMLStream(&buf, docs[0], docs[1], docs[2])
+	require.NoError(t, err)
+	assert.Equal(t, testYAMLStream, buf.String())
+
+	// empty documents:
+	docs, err = DecodeYAMLStream(strings.NewReader("---\n---\na: 1\n"))
+	require.NoError(t, err)
+	require.Len(t, docs, 2)
+	assert.Nil(t, docs[0].V())
+
+	docs, err = DecodeYAMLStream(strings.NewReader(""))
+	require.NoError(t, err)
+	assert.Empty(t, docs)
+
+	_, err = DecodeYAMLStream(strings.NewReader("a: 1\n---\nb: [\n"))
+	assert.ErrorContains(t, err, "document 1")
+}
+
+func TestYAMLStreamDecoder(t *testing.T) {
+	dec := NewYAMLStreamDecoder(strings.NewReader(testYAMLStream))
+
+	var m Map[string, any]
+	err := dec.Decode(&m)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"apiVersion", "kind", "metadata"}, m.Keys())
+
+	x, err := dec.Next()
+	require.NoError(t, err)
+	assert.Equal(t, []any{"apiVersion", "kind", "spec"}, x.V().(*Map[any, any]).Keys())
+
+	err = dec.Decode(&m)
+	assert.ErrorContains(t, err, "document 2")
+
+	_, err = dec.Next()
+	assert.Equal(t, io.EOF, err)
+
+	dec = NewYAMLStreamDecoderWith(strings.NewReader("a: &a [1]\nb: *a\n"), YAMLOptions{MaxAliasExpansion: 1})
+	_, err = dec.Next()
+	assert.ErrorContains(t, err, "aliases")
+}
@@ -0,0 +1,341 @@
+package ordmap
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func yamlEncodeString(t *testing.T, v any) string {
+	t.Helper()
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	err := enc.Encode(v)
+	require.NoError(t, err)
+	return buf.String()
+}
+
+func TestOrderedAny_YAMLAliases(t *testing.T) {
+	payload := `
+base: &base
+  z: 1
+  a: [1, 2]
+copy: *base
+list:
+  - &item x
+  - *item
+*item : key alias
+`
+	var x Any
+	err := yaml.Unmarshal([]byte(payload), &x)
+	require.NoError(t, err)
+
+	expected := `base:
+  z: 1
+  a:
+    - 1
+    - 2
+copy:
+  z: 1
+  a:
+    - 1
+    - 2
+list:
+  - x
+  - x
+x: key alias
+`
+	// aliases are expanded:
+	assert.Equal(t, expected, yamlEncodeString(t, x))
+
+	// each alias is a distinct copy:
+	root := x.V().(*Map[any, any])
+	root.Get("copy").(*Map[any, any]).Set("z", 2)
+	assert.Equal(t, 1, root.Get("base").(*Map[any, any]).Get("z"))
+}
+
+func TestOrderedAny_YAMLMergeKeys(t *testing.T) {
+	payload := `
+defaults: &defaults
+  adapter: postgres
+  host: localhost
+  port: 5432
+extra: &extra
+  host: example.com
+  pool: 5
+dev:
+  database: dev_db
+  <<: *defaults
+  port: 5433
+prod:
+  <<: [*extra, *defaults]
+  database: prod_db
+inline:
+  <<: {a: 1, b: 2}
+  b: 3
+`
+	var x Any
+	err := yaml.Unmarshal([]byte(payload), &x)
+	require.NoError(t, err)
+
+	root := x.V().(*Map[any, any])
+
+	// merged keys are inserted at the position of the merge key,
+	// keys of the mapping override merged keys:
+	dev := root.Get("dev").(*Map[any, any])
+	assert.Equal(t, []any{"database", "adapter", "host", "port"}, dev.Keys())
+	assert.Equal(t, 5433, dev.Get("port"))
+
+	// in a sequence of merged mappings, the first mappings take precedence:
+	prod := root.Get("prod").(*Map[any, any])
+	assert.Equal(t, []any{"host", "pool", "adapter", "port", "database"}, prod.Keys())
+	assert.Equal(t, "example.com", prod.Get("host"))
+
+	inline := root.Get("inline").(*Map[any, any])
+	assert.Equal(t, []any{"a", "b"}, inline.Keys())
+	assert.Equal(t, 3, inline.Get("b"))
+
+	_, err = yamlDecodeAny("a:\n  <<: 12\n", YAMLOptions{})
+	assert.Error(t, err)
+	_, err = yamlDecodeAny("a:\n  <<: [12]\n", YAMLOptions{})
+	assert.Error(t, err)
+}
+
+func yamlDecodeAny(payload string, opts YAMLOptions) (Any, error) {
+	var node yaml.Node
+	err := yaml.Unmarshal([]byte(payload), &node)
+	if err != nil {
+		return Any{}, err
+	}
+	var x Any
+	err = x.UnmarshalYAMLWith(&node, opts)
+	return x, err
+}
+
+func TestOrderedAny_YAMLAliasExpansionLimit(t *testing.T) {
+	payload := `
+a: &a ["lol","lol","lol","lol","lol","lol","lol","lol","lol"]
+b: &b [*a,*a,*a,*a,*a,*a,*a,*a,*a]
+c: &c [*b,*b,*b,*b,*b,*b,*b,*b,*b]
+d: &d [*c,*c,*c,*c,*c,*c,*c,*c,*c]
+e: &e [*d,*d,*d,*d,*d,*d,*d,*d,*d]
+f: &f [*e,*e,*e,*e,*e,*e,*e,*e,*e]
+g: &g [*f,*f,*f,*f,*f,*f,*f,*f,*f]
+`
+	_, err := yamlDecodeAny(payload, YAMLOptions{})
+	assert.ErrorContains(t, err, "aliases")
+
+	_, err = yamlDecodeAny(payload, YAMLOptions{MaxAliasExpansion: 100})
+	assert.ErrorContains(t, err, "aliases")
+
+	small := "a: &a [1, 2]\nb: [*a, *a]\n"
+	_, err = yamlDecodeAny(small, YAMLOptions{MaxAliasExpansion: 6})
+	assert.NoError(t, err)
+	_, err = yamlDecodeAny(small, YAMLOptions{MaxAliasExpansion: 5})
+	assert.Error(t, err)
+	_, err = yamlDecodeAny(small, YAMLOptions{MaxAliasExpansion: -1})
+	assert.NoError(t, err)
+}
+
+func TestOrderedAny_YAMLPreserveAliases(t *testing.T) {
+	payload := `defaults: &defaults
+  host: localhost
+  port: 5432
+dev: *defaults
+list:
+  - &name foo
+  - *name
+merged:
+  <<: *defaults
+  port: 1
+`
+	x, err := yamlDecodeAny(payload, YAMLOptions{PreserveAliases: true})
+	require.NoError(t, err)
+
+	root := x.V().(*Map[any, any])
+	anchor, ok := root.Get("defaults").(*YAMLAnchor)
+	require.True(t, ok)
+	assert.Equal(t, "defaults", anchor.Name)
+	assert.Same(t, anchor, root.Get("dev"))
+
+	list := root.Get("list").([]any)
+	assert.Same(t, list[0], list[1])
+
+	// merge keys are always expanded:
+	merged := root.Get("merged").(*Map[any, any])
+	assert.Equal(t, []any{"host", "port"}, merged.Keys())
+	assert.Equal(t, 1, merged.Get("port"))
+
+	// anchors and aliases are re-emitted:
+	expected := `defaults: &defaults
+  host: localhost
+  port: 5432
+dev: *defaults
+list:
+  - &name foo
+  - *name
+merged:
+  host: localhost
+  port: 1
+`
+	assert.Equal(t, expected, yamlEncodeString(t, x))
+
+	// in json, aliases are expanded:
+	assert.Equal(t, `{"defaults":{"host":"localhost","port":5432},"dev":{"host":"localhost","port":5432},"list":["foo","foo"],"merged":{"host":"localhost","port":1}}`,
+		jsonMarshalString(t, normalizeYAMLKeys(t, x)))
+}
+
+// normalizeYAMLKeys
+//
+// converts the root *Map[any, any] of a yaml document to a *Map[string, any], for json encoding
+func normalizeYAMLKeys(t *testing.T, x Any) *Map[string, any] {
+	res := &Map[string, any]{}
+	root := x.V().(*Map[any, any])
+	for _, k := range root.Keys() {
+		v := root.Get(k)
+		if m, ok := v.(*Map[any, any]); ok {
+			v = normalizeYAMLKeys(t, NewAny(m))
+		}
+		if a, ok := v.(*YAMLAnchor); ok {
+			if m, ok := a.Value.(*Map[any, any]); ok {
+				v = &YAMLAnchor{Name: a.Name, Value: normalizeYAMLKeys(t, NewAny(m))}
+			}
+		}
+		res.Set(k.(string), v)
+	}
+	return res
+}
+
+func TestOrderedAny_YAMLComplexKeys(t *testing.T) {
+	payload := `? [a, b]
+: sequence key
+? {y: 1, x: [2, 3]}
+: mapping key
+? - long
+  - block: sequence
+: block key
+plain: value
+`
+	_, err := yamlDecodeAny(payload, YAMLOptions{})
+	assert.ErrorContains(t, err, "ComplexKeys")
+
+	x, err := yamlDecodeAny(payload, YAMLOptions{ComplexKeys: true})
+	require.NoError(t, err)
+
+	root := x.V().(*Map[any, any])
+	keys := root.Keys()
+	require.Len(t, keys, 4)
+	assert.Equal(t, "[a, b]", keys[0].(ComplexKey).String())
+	// "y" is quoted by the yaml encoder:
+	assert.Equal(t, `{"y": 1, x: [2, 3]}`, keys[1].(ComplexKey).String())
+	assert.Equal(t, "[long, {block: sequence}]", keys[2].(ComplexKey).String())
+	assert.Equal(t, "plain", keys[3])
+
+	// keys can be looked up with their canonical form:
+	k, err := NewComplexKey([]any{"a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, "sequence key", root.Get(k))
+
+	m := &Map[string, any]{}
+	m.Set("y", 1)
+	m.Set("x", []any{2, 3})
+	k, err = NewComplexKey(m)
+	require.NoError(t, err)
+	assert.Equal(t, "mapping key", root.Get(k))
+
+	kv, err := keys[1].(ComplexKey).Value()
+	require.NoError(t, err)
+	assert.Equal(t, []any{"y", "x"}, kv.V().(*Map[any, any]).Keys())
+
+	// re-emit:
+	expected := `? [a, b]
+: sequence key
+? {"y": 1, x: [2, 3]}
+: mapping key
+? [long, {block: sequence}]
+: block key
+plain: value
+`
+	assert.Equal(t, expected, yamlEncodeString(t, x))
+
+	// in json, complex keys are strings:
+	assert.Equal(t, `{"[a, b]":"sequence key"}`, jsonMarshalString(t, mustOnlyKey(root, keys[0])))
+
+	// aliases in keys are expanded:
+	x, err = yamlDecodeAny("a: &s [1, '2']\n? *s\n: alias key\n? [1, \"2\"]\n: same key\n", YAMLOptions{ComplexKeys: true})
+	require.NoError(t, err)
+	root = x.V().(*Map[any, any])
+	require.Equal(t, 2, root.Len())
+	assert.Equal(t, `[1, "2"]`, root.Keys()[1].(ComplexKey).String())
+	assert.Equal(t, "same key", root.Get(root.Keys()[1]))
+}
+
+func mustOnlyKey(m *Map[any, any], k any) *Map[any, any] {
+	res := &Map[any, any]{}
+	res.Set(k, m.Get(k))
+	return res
+}
+
+func TestOrderedAny_YAMLPreserveScalars(t *testing.T) {
+	payload := `hex: 0x1F
+float: 1.0
+quoted: "007"
+single: 'it''s'
+bool: yes
+date: 2024-01-01
+tagged: !!str 123
+custom: !env HOME
+list:
+    - 1e3
+    - ~
+`
+	x, err := yamlDecodeAny(payload, YAMLOptions{PreserveScalars: true})
+	require.NoError(t, err)
+
+	root := x.V().(*Map[any, any])
+	hex := root.Get("hex").(*YAMLScalar)
+	assert.Equal(t, 31, hex.Value)
+	assert.Equal(t, "!!int", hex.Tag)
+	assert.Equal(t, "0x1F", hex.Text)
+	assert.False(t, hex.Modified())
+
+	quoted := root.Get("quoted").(*YAMLScalar)
+	assert.Equal(t, "007", quoted.Value)
+	assert.Equal(t, yaml.DoubleQuotedStyle, quoted.Style)
+
+	// untouched scalars round trip exactly:
+	out, err := yaml.Marshal(x)
+	require.NoError(t, err)
+	assert.Equal(t, payload, string(out))
+
+	// json uses the decoded values:
+	assert.Equal(t, `{"hex":31,"float":1,"quoted":"007","single":"it's","bool":"yes","date":"2024-01-01T00:00:00Z","tagged":"123","custom":"HOME","list":[1000,null]}`,
+		jsonMarshalString(t, normalizeYAMLKeys(t, x)))
+
+	// modified scalars are encoded from their value:
+	hex.Value = 32
+	quoted.Value = "008"
+	out, err = yaml.Marshal(x)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "hex: 32\nfloat: 1.0\nquoted: \"008\"\n")
+
+	// clones are independent:
+	clone := x.DeepClone()
+	clone.V().(*Map[any, any]).Get("float").(*YAMLScalar).Value = 2.0
+	assert.Equal(t, 1.0, root.Get("float").(*YAMLScalar).Value)
+
+	// DecodeInto uses the decoded values:
+	var target struct {
+		Hex  int      `yaml:"hex"`
+		List []any    `yaml:"list"`
+		Bool string   `yaml:"bool"`
+		Tags []string `yaml:"tags"`
+	}
+	require.NoError(t, x.DecodeInto(&target))
+	assert.Equal(t, 32, target.Hex)
+	assert.Equal(t, "yes", target.Bool)
+}
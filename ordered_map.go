@@ -9,11 +9,30 @@ package ordmap
 //   - `yaml.Unmarshaler` (from package `gopkg.in/yaml.v3`)
 //
 // in a way that preserves the order of the keys in the source data.
+//
+// Unmarshaling an object into a Map replaces its content, see `UnmarshalMerge()`
+// to merge several documents into the same Map.
 type Map[K comparable, V any] struct {
 	m    map[K]V
 	keys []K
 }
 
+// Pair is a key/value pair of a Map.
+type Pair[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+// FromPairs returns a Map with the entries 'pairs', in order. When a key is repeated, the last
+// value wins, at the position of the first occurrence.
+func FromPairs[K comparable, V any](pairs ...Pair[K, V]) *Map[K, V] {
+	res := &Map[K, V]{}
+	for _, p := range pairs {
+		res.Set(p.Key, p.Value)
+	}
+	return res
+}
+
 func (m *Map[K, V]) Get(key K) V {
 	return m.m[key]
 }
@@ -75,8 +94,48 @@ func (m *Map[K, V]) Clone() *Map[K, V] {
 	return res
 }
 
+// CloneFunc returns a copy of the map, where each value is copied using 'clone'.
+//
+// 'clone' is called on the values in the order of the keys.
+func (m *Map[K, V]) CloneFunc(clone func(V) V) *Map[K, V] {
+	res := m.Clone()
+	for _, k := range res.keys {
+		res.m[k] = clone(res.m[k])
+	}
+	return res
+}
+
 func (m *Map[K, V]) Keys() []K {
 	res := make([]K, len(m.keys))
 	copy(res, m.keys)
 	return res
 }
+
+// MergeTarget is the target returned by `UnmarshalMerge()`.
+type MergeTarget[K comparable, V any] struct {
+	m *Map[K, V]
+}
+
+// UnmarshalMerge returns a target for `json.Unmarshal()` or `yaml.Unmarshal()` which merges
+// the decoded object into 'm' instead of replacing its content, for layered loading:
+// keys already present in 'm' keep their position and get the decoded value, new keys are
+// appended in the order of the document.
+//
+// Values are replaced, not merged recursively. A null document leaves 'm' untouched.
+func UnmarshalMerge[K comparable, V any](m *Map[K, V]) *MergeTarget[K, V] {
+	return &MergeTarget[K, V]{m: m}
+}
+
+// applyDecoded
+//
+// replaces the content of 'm' with 'res', or merges 'res' into 'm'.
+func (m *Map[K, V]) applyDecoded(res *Map[K, V], merge bool) {
+	if !merge {
+		m.m = res.m
+		m.keys = res.keys
+		return
+	}
+	for _, k := range res.keys {
+		m.Set(k, res.m[k])
+	}
+}
@@ -0,0 +1,104 @@
+package ordmap
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// mapBinaryVersion is the version of the layout written by `Map.MarshalBinary()`.
+const mapBinaryVersion = 1
+
+var registerGobOnce sync.Once
+
+// RegisterGob registers with `gob.Register()` the types of the values decoded from JSON and YAML
+// (`*Map[string, any]`, `*Map[any, any]` and `[]any`), so that they can be stored in interface
+// values of a gob stream.
+//
+// `Map.MarshalBinary()` and `Map.UnmarshalBinary()` call it on their first use; call it before
+// encoding or decoding such values with a gob encoder of your own. It is safe to call it several
+// times.
+func RegisterGob() {
+	registerGobOnce.Do(func() {
+		gob.Register(&Map[string, any]{})
+		gob.Register(&Map[any, any]{})
+		gob.Register([]any{})
+	})
+}
+
+// mapBinary is the content of the gob stream of the binary layout.
+type mapBinary[K comparable, V any] struct {
+	Keys   []K
+	Values []V
+}
+
+// MarshalBinary implements `encoding.BinaryMarshaler`, and returns a snapshot of 'm' which keeps
+// the order of its keys.
+//
+// Layout (version 1): one byte holding the version of the layout, followed by a gob stream with
+// the keys then the values of the Map, in order.
+//
+// Compatibility: UnmarshalBinary accepts all the versions of the layout up to the one it writes,
+// and rejects newer versions with an error. Changes to the types K and V follow the rules of
+// `encoding/gob` (struct fields can be added and removed). As with gob, concrete types stored in
+// interface values must be registered with `gob.Register()`; `*Map[string, any]`, `*Map[any, any]`
+// and `[]any` are registered by `RegisterGob()`.
+func (m Map[K, V]) MarshalBinary() ([]byte, error) {
+	RegisterGob()
+
+	var buf bytes.Buffer
+	buf.WriteByte(mapBinaryVersion)
+
+	content := mapBinary[K, V]{Keys: m.keys, Values: make([]V, len(m.keys))}
+	for i, k := range m.keys {
+		content.Values[i] = m.m[k]
+	}
+	err := gob.NewEncoder(&buf).Encode(content)
+	if err != nil {
+		return nil, fmt.Errorf("error when encoding map: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements `encoding.BinaryUnmarshaler`, and replaces the content of 'm'
+// with the snapshot 'data' written by `MarshalBinary()`. 'm' is left untouched if an error occurs.
+func (m *Map[K, V]) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		return errors.New("error when decoding map: empty data")
+	}
+	if data[0] != mapBinaryVersion {
+		return fmt.Errorf("error when decoding map: unsupported layout version %d", data[0])
+	}
+
+	RegisterGob()
+	var content mapBinary[K, V]
+	err := gob.NewDecoder(bytes.NewReader(data[1:])).Decode(&content)
+	if err != nil {
+		return fmt.Errorf("error when decoding map: %w", err)
+	}
+	if len(content.Keys) != len(content.Values) {
+		return fmt.Errorf("error when decoding map: %d keys for %d values", len(content.Keys), len(content.Values))
+	}
+
+	res := Map[K, V]{}
+	for i, k := range content.Keys {
+		if _, exists := res.Get2(k); exists {
+			return fmt.Errorf("error when decoding map: duplicate key %v", k)
+		}
+		res.Set(k, content.Values[i])
+	}
+	m.applyDecoded(&res, false)
+	return nil
+}
+
+// GobEncode implements `gob.GobEncoder`, with the layout of `MarshalBinary()`.
+func (m Map[K, V]) GobEncode() ([]byte, error) {
+	return m.MarshalBinary()
+}
+
+// GobDecode implements `gob.GobDecoder`, see `UnmarshalBinary()`.
+func (m *Map[K, V]) GobDecode(data []byte) error {
+	return m.UnmarshalBinary(data)
+}
@@ -0,0 +1,100 @@
+package ordmap
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderedMapMarshalBinary(t *testing.T) {
+	var m Map[string, int]
+	m.Set("z", 1)
+	m.Set("a", 2)
+	m.Set("m", 3)
+
+	data, err := m.MarshalBinary()
+	require.NoError(t, err)
+	assert.Equal(t, byte(1), data[0])
+
+	var back Map[string, int]
+	back.Set("old", 0)
+	err = back.UnmarshalBinary(data)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"z", "a", "m"}, back.Keys())
+	assert.Equal(t, 2, back.Get("a"))
+
+	// empty map:
+	data, err = Map[string, int]{}.MarshalBinary()
+	require.NoError(t, err)
+	err = back.UnmarshalBinary(data)
+	require.NoError(t, err)
+	assert.Equal(t, 0, back.Len())
+
+	// errors leave the map untouched:
+	back.Set("x", 1)
+	for _, input := range [][]byte{
+		nil,
+		{2},
+		{1, 0xff},
+	} {
+		err := back.UnmarshalBinary(input)
+		assert.Error(t, err, "input: %v", input)
+	}
+	assert.Equal(t, []string{"x"}, back.Keys())
+
+	var wrongType Map[int, int]
+	data, _ = m.MarshalBinary()
+	assert.Error(t, wrongType.UnmarshalBinary(data))
+}
+
+func TestOrderedMapGob(t *testing.T) {
+	type Entry struct {
+		Name  string
+		Attrs Map[string, any]
+		Index *Map[int, []string]
+	}
+
+	var doc Any
+	err := json.Unmarshal([]byte(`{"z":1,"a":{"y":[true,null,"s"],"b":{}}}`), &doc)
+	require.NoError(t, err)
+
+	e := Entry{Name: "e", Attrs: *doc.V().(*Map[string, any]), Index: &Map[int, []string]{}}
+	e.Index.Set(3, []string{"c"})
+	e.Index.Set(1, nil)
+
+	var buf bytes.Buffer
+	err = gob.NewEncoder(&buf).Encode(e)
+	require.NoError(t, err)
+
+	var back Entry
+	err = gob.NewDecoder(&buf).Decode(&back)
+	require.NoError(t, err)
+	assert.Equal(t, "e", back.Name)
+	assert.Equal(t, `{"z":1,"a":{"y":[true,null,"s"],"b":{}}}`, jsonMarshalString(t, back.Attrs))
+	assert.Equal(t, []int{3, 1}, back.Index.Keys())
+	assert.Equal(t, []string{"c"}, back.Index.Get(3))
+}
+
+func TestRegisterGob(t *testing.T) {
+	RegisterGob()
+	RegisterGob()
+
+	var doc Any
+	err := json.Unmarshal([]byte(`{"z":[1],"a":{}}`), &doc)
+	require.NoError(t, err)
+
+	// a Map held directly in an interface value:
+	var buf bytes.Buffer
+	v := doc.V()
+	err = gob.NewEncoder(&buf).Encode(&v)
+	require.NoError(t, err)
+
+	var back any
+	err = gob.NewDecoder(&buf).Decode(&back)
+	require.NoError(t, err)
+	assert.Equal(t, `{"z":[1],"a":{}}`, jsonMarshalString(t, back))
+}
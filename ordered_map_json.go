@@ -8,7 +8,17 @@ import (
 	"io"
 )
 
+// UnmarshalJSON replaces the content of 'm' with the decoded object. 'm' is left untouched if
+// an error occurs. Use `UnmarshalMerge()` to merge the object into the existing content.
 func (m *Map[K, V]) UnmarshalJSON(p []byte) error {
+	return m.unmarshalJSON(p, false)
+}
+
+func (t *MergeTarget[K, V]) UnmarshalJSON(p []byte) error {
+	return t.m.unmarshalJSON(p, true)
+}
+
+func (m *Map[K, V]) unmarshalJSON(p []byte, merge bool) error {
 	buff := jsonBuff{p: p}
 
 	// call '.peek()' once to make sure we "eat up" all leading space
@@ -18,8 +28,9 @@ func (m *Map[K, V]) UnmarshalJSON(p []byte) error {
 		return fmt.Errorf("error when decoding map: %w", io.ErrUnexpectedEOF)
 	}
 	if bytes.Equal(buff.tail(), []byte("null")) {
-		m.m = nil
-		m.keys = nil
+		if !merge {
+			m.Clear()
+		}
 		return nil
 	}
 
@@ -35,10 +46,12 @@ func (m *Map[K, V]) UnmarshalJSON(p []byte) error {
 		if !buff.eof() {
 			return fmt.Errorf("error when decoding map: extra trailing data")
 		}
+		m.applyDecoded(&Map[K, V]{}, merge)
 		return nil
 	}
 
 	var (
+		res   Map[K, V]
 		key   K
 		value V
 
@@ -89,7 +102,7 @@ decodeLoop:
 			if err != nil {
 				return fmt.Errorf("error when decoding value: %w", err)
 			}
-			m.Set(key, value)
+			res.Set(key, value)
 
 			tok = buff.peek()
 			if tok == '}' {
@@ -113,6 +126,7 @@ decodeLoop:
 	if state != stateObjectCompleted {
 		return fmt.Errorf("error when decoding map: %w", io.ErrUnexpectedEOF)
 	}
+	m.applyDecoded(&res, merge)
 	return nil
 }
 
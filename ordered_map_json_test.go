@@ -181,3 +181,34 @@ func FuzzMapJsonDecoder(f *testing.F) {
 		}
 	})
 }
+
+func TestOrderedMapUnmarshalJson_ReplaceAndMerge(t *testing.T) {
+	var m Map[string, int]
+	require.NoError(t, json.Unmarshal([]byte(`{"b":1,"a":2}`), &m))
+
+	// decoding again replaces the content:
+	require.NoError(t, json.Unmarshal([]byte(`{"c":3,"a":4}`), &m))
+	assert.Equal(t, []string{"c", "a"}, m.Keys())
+	assert.Equal(t, 0, m.Get("b"))
+
+	require.NoError(t, json.Unmarshal([]byte(`{}`), &m))
+	assert.Equal(t, 0, m.Len())
+
+	// errors leave the map untouched:
+	require.NoError(t, json.Unmarshal([]byte(`{"x":1}`), &m))
+	assert.Error(t, json.Unmarshal([]byte(`{"y":2,"z":"3"}`), &m))
+	assert.Equal(t, []string{"x"}, m.Keys())
+
+	// merge: existing keys keep their position, new keys are appended:
+	var layered Map[string, int]
+	require.NoError(t, json.Unmarshal([]byte(`{"b":1,"a":2}`), UnmarshalMerge(&layered)))
+	require.NoError(t, json.Unmarshal([]byte(`{"c":3,"b":4}`), UnmarshalMerge(&layered)))
+	require.NoError(t, json.Unmarshal([]byte(`null`), UnmarshalMerge(&layered)))
+	require.NoError(t, json.Unmarshal([]byte(`{}`), UnmarshalMerge(&layered)))
+	assert.Equal(t, []string{"b", "a", "c"}, layered.Keys())
+	assert.Equal(t, 4, layered.Get("b"))
+
+	// null clears the map when replacing:
+	require.NoError(t, json.Unmarshal([]byte(`null`), &layered))
+	assert.Equal(t, 0, layered.Len())
+}
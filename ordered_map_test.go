@@ -1,6 +1,7 @@
 package ordmap
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -30,3 +31,35 @@ func TestGetSetDelete(t *testing.T) {
 	assert.Equal(t, 5, m.Get("c"))
 	assert.Equal(t, []string{"a", "b", "c"}, m.Keys())
 }
+
+func TestCloneFunc(t *testing.T) {
+	var m Map[string, []int]
+	m.Set("b", []int{1})
+	m.Set("a", []int{2, 3})
+
+	var visited []string
+	c := m.CloneFunc(func(v []int) []int {
+		visited = append(visited, fmt.Sprint(v))
+		return append([]int(nil), v...)
+	})
+	assert.Equal(t, []string{"[1]", "[2 3]"}, visited)
+	assert.Equal(t, []string{"b", "a"}, c.Keys())
+
+	c.Get("a")[0] = 12
+	c.Set("c", nil)
+	assert.Equal(t, []int{2, 3}, m.Get("a"))
+	assert.Equal(t, []string{"b", "a"}, m.Keys())
+
+	// a shallow Clone shares the values:
+	s := m.Clone()
+	s.Get("a")[0] = 12
+	assert.Equal(t, []int{12, 3}, m.Get("a"))
+}
+
+func TestFromPairs(t *testing.T) {
+	m := FromPairs(Pair[string, int]{Key: "b", Value: 1}, Pair[string, int]{Key: "a", Value: 2}, Pair[string, int]{Key: "b", Value: 3})
+	assert.Equal(t, []string{"b", "a"}, m.Keys())
+	assert.Equal(t, 3, m.Get("b"))
+
+	assert.Equal(t, 0, FromPairs[string, int]().Len())
+}
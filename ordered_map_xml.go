@@ -0,0 +1,45 @@
+package ordmap
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+// MarshalXML writes the entries of 'm' as the content of the element 'start', following the
+// convention described by `XMLAttrPrefix`: keys which start with "@" are written as attributes,
+// the "#text" key as text, other keys as child elements in the order of the Map, and arrays as
+// repeated elements. Keys are formatted as strings the same way `encoding/json` formats the
+// keys of go maps.
+//
+// Values which are not objects, arrays or scalars, such as structs, are written with `encoding/xml`.
+func (m Map[K, V]) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return writeXMLContent(e, start.Name.Local, start, &m)
+}
+
+// UnmarshalXML replaces the content of 'm' with the attributes, child elements and text of the
+// element 'start', following the convention described by `XMLAttrPrefix`. Values are converted
+// from text to the type V with `DecodeOptions.WeaklyTyped` set, so that "12" can be decoded as an
+// int, and a single element as a slice. 'm' is left untouched if an error occurs.
+func (m *Map[K, V]) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	return m.unmarshalXML(d, start, false)
+}
+
+func (t *MergeTarget[K, V]) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	return t.m.unmarshalXML(d, start, true)
+}
+
+func (m *Map[K, V]) unmarshalXML(d *xml.Decoder, start xml.StartElement, merge bool) error {
+	xr := &xmlReader{next: d.Token}
+	v, err := xr.readElement(start)
+	if err != nil {
+		return fmt.Errorf("error when decoding xml: %w", err)
+	}
+
+	var res Map[K, V]
+	err = Any{v: v}.DecodeIntoWith(&res, DecodeOptions{WeaklyTyped: true})
+	if err != nil {
+		return fmt.Errorf("error when decoding map: %w", err)
+	}
+	m.applyDecoded(&res, merge)
+	return nil
+}
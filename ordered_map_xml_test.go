@@ -0,0 +1,83 @@
+package ordmap
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderedMapMarshalXml(t *testing.T) {
+	type Point struct {
+		X int `xml:"x,attr"`
+		Y int `xml:"y,attr"`
+	}
+	type Shape struct {
+		XMLName xml.Name              `xml:"shape"`
+		Points  Map[string, Point]    `xml:"points"`
+		Labels  Map[string, []string] `xml:"labels"`
+	}
+
+	s := Shape{}
+	s.Points.Set("start", Point{X: 1, Y: 2})
+	s.Points.Set("end", Point{X: 3, Y: 4})
+	s.Labels.Set("@lang", []string{"en"})
+	s.Labels.Set("tag", []string{"b", "a"})
+
+	_, err := xml.Marshal(s)
+	assert.Error(t, err, "attribute values must be scalars")
+
+	s.Labels = Map[string, []string]{}
+	s.Labels.Set("tag", []string{"b", "a"})
+	out, err := xml.Marshal(s)
+	require.NoError(t, err)
+	assert.Equal(t, `<shape><points><start x="1" y="2"></start><end x="3" y="4"></end></points><labels><tag>b</tag><tag>a</tag></labels></shape>`, string(out))
+}
+
+func TestOrderedMapUnmarshalXml(t *testing.T) {
+	type Config struct {
+		XMLName xml.Name              `xml:"config"`
+		Limits  Map[string, int]      `xml:"limits"`
+		Hosts   Map[string, []string] `xml:"hosts"`
+	}
+
+	input := `<config>
+  <limits unit="3"><z>10</z><a>20</a></limits>
+  <hosts><web>w1</web><db>d1</db><web>w2</web></hosts>
+</config>`
+
+	var cfg Config
+	err := xml.Unmarshal([]byte(input), &cfg)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"@unit", "z", "a"}, cfg.Limits.Keys())
+	assert.Equal(t, 20, cfg.Limits.Get("a"))
+	assert.Equal(t, []string{"web", "db"}, cfg.Hosts.Keys())
+	assert.Equal(t, []string{"w1", "w2"}, cfg.Hosts.Get("web"))
+	assert.Equal(t, []string{"d1"}, cfg.Hosts.Get("db"))
+
+	out, err := xml.Marshal(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, `<config><limits unit="3"><z>10</z><a>20</a></limits><hosts><web>w1</web><web>w2</web><db>d1</db></hosts></config>`, string(out))
+
+	// namespaces declared in the element keep their prefix:
+	var m Map[string, string]
+	err = xml.Unmarshal([]byte(`<m xmlns:p="urn:p" xmlns="urn:d"><p:a>1</p:a><b>2</b></m>`), &m)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"@xmlns:p", "@xmlns", "p:a", "b"}, m.Keys())
+
+	// replace and merge:
+	err = xml.Unmarshal([]byte(`<m><c>3</c></m>`), UnmarshalMerge(&m))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"@xmlns:p", "@xmlns", "p:a", "b", "c"}, m.Keys())
+
+	var li Map[string, int]
+	li.Set("x", 1)
+	err = xml.Unmarshal([]byte(`<m><a>nan</a></m>`), &li)
+	assert.Error(t, err)
+	assert.Equal(t, []string{"x"}, li.Keys(), "map is left untouched on error")
+
+	err = xml.Unmarshal([]byte(`<m><a>5</a></m>`), &li)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, li.Keys())
+}
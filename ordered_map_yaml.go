@@ -2,6 +2,9 @@ package ordmap
 
 import (
 	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -24,13 +27,28 @@ func strYamlKind(kind yaml.Kind) string {
 	}
 }
 
+// UnmarshalYAML replaces the content of 'm' with the decoded mapping. 'm' is left untouched if
+// an error occurs. Use `UnmarshalMerge()` to merge the mapping into the existing content.
 func (m *Map[K, V]) UnmarshalYAML(value *yaml.Node) error {
+	return m.unmarshalYAML(value, false)
+}
+
+func (t *MergeTarget[K, V]) UnmarshalYAML(value *yaml.Node) error {
+	return t.m.unmarshalYAML(value, true)
+}
+
+func (m *Map[K, V]) unmarshalYAML(value *yaml.Node, merge bool) error {
+	if value.Kind == yaml.ScalarNode && value.ShortTag() == "!!null" {
+		if !merge {
+			m.Clear()
+		}
+		return nil
+	}
 	if value.Kind != yaml.MappingNode {
 		return fmt.Errorf("invalid yaml value: expected a mapping, got a %s", strYamlKind(value.Kind))
 	}
 
-	myMap := make(map[K]V)
-	var myKeys []K
+	var res Map[K, V]
 	for i := 0; i < len(value.Content); i += 2 {
 		keyNode := value.Content[i]
 		valueNode := value.Content[i+1]
@@ -45,54 +63,126 @@ func (m *Map[K, V]) UnmarshalYAML(value *yaml.Node) error {
 			return fmt.Errorf("failed to decode value at index %d: %w", i+1, err)
 		}
 
-		myMap[key] = val
-		myKeys = append(myKeys, key)
+		res.Set(key, val)
 	}
 
-	m.m = myMap
-	m.keys = myKeys
+	m.applyDecoded(&res, merge)
 	return nil
 }
 
+// MarshalYAML returns a mapping node, with the entries of 'm' in order.
+//
+// Keys and values are encoded directly into nodes with `yaml.Node.Encode()`, so the tags, styles
+// and comments of nodes returned by nested `MarshalYAML()` implementations are kept.
 func (m Map[K, V]) MarshalYAML() (any, error) {
-	if len(m.keys) == 0 {
-		return struct{}{}, nil
+	node := &yaml.Node{
+		Kind:    yaml.MappingNode,
+		Tag:     "!!map",
+		Content: make([]*yaml.Node, 0, len(m.keys)*2),
 	}
 
-	node := &yaml.Node{}
-	node.Kind = yaml.MappingNode
-	node.Content = make([]*yaml.Node, 0, len(m.keys)*2)
-
 	for _, key := range m.keys {
-		var keyNode yaml.Node
-		var valueNode yaml.Node
-
-		keyBytes, err := yaml.Marshal(key)
+		keyNode, err := encodeYAMLNode(key)
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal key: %w", err)
 		}
-		err = yaml.Unmarshal(keyBytes, &keyNode)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal key: %w", err)
-		}
-		if keyNode.Kind == yaml.DocumentNode {
-			keyNode = *keyNode.Content[0]
-		}
 
-		valueBytes, err := yaml.Marshal(m.m[key])
+		valueNode, err := encodeYAMLNode(m.m[key])
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal value: %w", err)
 		}
-		err = yaml.Unmarshal(valueBytes, &valueNode)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal value: %w", err)
+
+		node.Content = append(node.Content, keyNode, valueNode)
+	}
+
+	return node, nil
+}
+
+// encodeYAMLNode
+//
+// encodes 'v' into a yaml node.
+//
+// `yaml.Node.Encode()` renders its value as text and parses the text back, so the common cases
+// are built directly: scalars which need no quoting, []any, *yaml.Node, and values implementing
+// yaml.Marshaler (such as Map and Any), whose result is encoded recursively.
+// Other values are delegated to `yaml.Node.Encode()`.
+func encodeYAMLNode(v any) (*yaml.Node, error) {
+	switch x := v.(type) {
+	case nil:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(x)}, nil
+	case int:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(x)}, nil
+	case int64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.FormatInt(x, 10)}, nil
+	case string:
+		if isPlainYAMLString(x) {
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: x}, nil
+		}
+
+	case []any:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: make([]*yaml.Node, 0, len(x))}
+		for _, elt := range x {
+			n, err := encodeYAMLNode(elt)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, n)
 		}
-		if valueNode.Kind == yaml.DocumentNode {
-			valueNode = *valueNode.Content[0]
+		return node, nil
+
+	case *yaml.Node:
+		if x != nil {
+			if x.Kind == yaml.DocumentNode && len(x.Content) > 0 {
+				return x.Content[0], nil
+			}
+			return x, nil
 		}
 
-		node.Content = append(node.Content, &keyNode, &valueNode)
+	case yaml.Marshaler:
+		if rv := reflect.ValueOf(x); rv.Kind() == reflect.Pointer && rv.IsNil() {
+			break
+		}
+		res, err := x.MarshalYAML()
+		if err != nil {
+			return nil, err
+		}
+		return encodeYAMLNode(res)
 	}
 
+	node := &yaml.Node{}
+	err := node.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	if node.Kind == yaml.DocumentNode {
+		node = node.Content[0]
+	}
 	return node, nil
 }
+
+// isPlainYAMLString
+//
+// returns true if 's' is a string that the yaml encoder writes as a plain scalar:
+// a word which does not resolve to another type, and which is not one of the YAML 1.1 booleans
+// that the encoder quotes.
+func isPlainYAMLString(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_':
+		case i > 0 && (c >= '0' && c <= '9' || c == '-' || c == '.' || c == '/'):
+		default:
+			return false
+		}
+	}
+	switch strings.ToLower(s) {
+	case "y", "yes", "n", "no", "on", "off", "true", "false", "null":
+		return false
+	}
+	return true
+}
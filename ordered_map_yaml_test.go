@@ -2,6 +2,8 @@ package ordmap
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
 	"strings"
 	"testing"
 
@@ -86,3 +88,98 @@ bbb:
 	expected = strings.TrimSpace(expected)
 	assert.Equal(t, expected, got)
 }
+
+type testYAMLSecret string
+
+func (s testYAMLSecret) MarshalYAML() (any, error) {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!secret", Style: yaml.DoubleQuotedStyle, Value: string(s), LineComment: "# encrypted"}, nil
+}
+
+func TestOrderedMapMarshalYaml_Nodes(t *testing.T) {
+	inner := &Map[string, any]{}
+	inner.Set("password", testYAMLSecret("s3cr3t"))
+	inner.Set("flow", &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle, Content: []*yaml.Node{
+		{Kind: yaml.ScalarNode, Value: "a"},
+		{Kind: yaml.ScalarNode, Value: "b"},
+	}})
+	inner.Set("nil", (*Map[string, int])(nil))
+
+	m := &Map[any, any]{}
+	m.Set("db", inner)
+	m.Set("yes", "no")
+	m.Set(12, []any{1.5, "multi\nline", true, nil})
+	m.Set("empty", &Map[string, any]{})
+
+	out, err := yaml.Marshal(m)
+	require.NoError(t, err)
+
+	expected := `db:
+    password: !secret "s3cr3t" # encrypted
+    flow: [a, b]
+    nil: null
+"yes": "no"
+12:
+    - 1.5
+    - |-
+      multi
+      line
+    - true
+    - null
+empty: {}
+`
+	assert.Equal(t, expected, string(out))
+}
+
+func benchmarkYAMLMap(size int) *Map[string, any] {
+	m := &Map[string, any]{}
+	for i := 0; i < size; i++ {
+		entry := &Map[string, any]{}
+		entry.Set("name", fmt.Sprintf("item-%d", i))
+		entry.Set("count", i)
+		entry.Set("tags", []string{"a", "b"})
+		m.Set(fmt.Sprintf("key%d", i), entry)
+	}
+	return m
+}
+
+func BenchmarkMap_MarshalYAML(b *testing.B) {
+	for _, size := range []int{10, 1000} {
+		m := benchmarkYAMLMap(size)
+		b.Run(fmt.Sprint(size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, err := yaml.Marshal(m)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestOrderedMapUnmarshalYaml_ReplaceAndMerge(t *testing.T) {
+	var m Map[string, int]
+	require.NoError(t, yaml.Unmarshal([]byte("b: 1\na: 2\n"), &m))
+	require.NoError(t, yaml.Unmarshal([]byte("c: 3\na: 4\n"), &m))
+	assert.Equal(t, []string{"c", "a"}, m.Keys())
+
+	assert.Error(t, yaml.Unmarshal([]byte("y: 2\nz: [3]\n"), &m))
+	assert.Equal(t, []string{"c", "a"}, m.Keys())
+
+	// merge, mixing yaml and json layers:
+	var layered Map[string, int]
+	require.NoError(t, yaml.Unmarshal([]byte("b: 1\na: 2\n"), UnmarshalMerge(&layered)))
+	require.NoError(t, json.Unmarshal([]byte(`{"c":3,"b":4}`), UnmarshalMerge(&layered)))
+	require.NoError(t, yaml.Unmarshal([]byte("a: 5\nd: 6\n"), UnmarshalMerge(&layered)))
+	assert.Equal(t, []string{"b", "a", "c", "d"}, layered.Keys())
+	assert.Equal(t, []int{4, 5, 3, 6}, []int{layered.Get("b"), layered.Get("a"), layered.Get("c"), layered.Get("d")})
+
+	// duplicate keys are listed once:
+	var dup Map[string, int]
+	var node yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte("a: 1\nb: 2\n"), &node))
+	node.Content[0].Content[2].Value = "a"
+	require.NoError(t, node.Decode(&dup))
+	assert.Equal(t, []string{"a"}, dup.Keys())
+	assert.Equal(t, 2, dup.Get("a"))
+}
@@ -0,0 +1,589 @@
+package ordmap
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PlistFormat is the format of an Apple property list.
+type PlistFormat int
+
+const (
+	// PlistXML is the XML format ("<plist version="1.0">...").
+	PlistXML PlistFormat = iota
+	// PlistBinary is the binary format ("bplist00...").
+	PlistBinary
+)
+
+// PlistUID is a UID value of a property list, as used by NSKeyedArchiver. In the XML format,
+// it is represented as a dict with the single key "CF$UID".
+type PlistUID uint64
+
+// maxPlistDepth is the maximum nesting depth of arrays and dicts when decoding a property list.
+const maxPlistDepth = 1000
+
+// DetectPlistFormat returns the format of the property list 'data'.
+func DetectPlistFormat(data []byte) PlistFormat {
+	if bytes.HasPrefix(data, []byte("bplist")) {
+		return PlistBinary
+	}
+	return PlistXML
+}
+
+// UnmarshalPlist parses the property list 'data', in the XML or the binary format, and stores
+// the result in 'v'.
+//
+// Values are decoded as: `*Map[string, any]` for dict, which keeps the order of the keys of the
+// file, []any for array, string for string, int64 for integer (uint64 for values above
+// math.MaxInt64), float64 for real, bool for true and false, time.Time in UTC for date, []byte
+// for data, and `PlistUID` for UID.
+//
+// The decoded value is stored in 'v' with `Any.DecodeInto()`.
+func UnmarshalPlist(data []byte, v any) error {
+	var res any
+	var err error
+	if DetectPlistFormat(data) == PlistBinary {
+		res, err = parseBinaryPlist(data)
+	} else {
+		res, err = parseXMLPlist(data)
+	}
+	if err != nil {
+		return err
+	}
+	return Any{v: res}.DecodeInto(v)
+}
+
+// MarshalPlist returns the encoding of 'v' as a property list in the format 'format'.
+//
+// Objects and Maps are written as dict in the order of their keys, structs as dict in the order
+// of their fields, go maps as dict with sorted keys. Signed and unsigned integers are written
+// as integer, floats as real, time.Time as date, []byte as data, and `encoding.TextMarshaler`
+// values as string. Null values can not be represented.
+//
+// The XML format is indented with tabs, as written by the Apple tools. In the binary format,
+// equal scalars share the same object.
+func MarshalPlist(v any, format PlistFormat) ([]byte, error) {
+	root, err := resolvePlistValue("", v)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case PlistXML:
+		e := &plistXMLEncoder{}
+		e.buf.WriteString(xml.Header)
+		e.buf.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+		e.buf.WriteString(`<plist version="1.0">` + "\n")
+		err = e.writeValue("", root, 0)
+		if err != nil {
+			return nil, err
+		}
+		e.buf.WriteString("</plist>\n")
+		return e.buf.Bytes(), nil
+	case PlistBinary:
+		return encodeBinaryPlist(root)
+	}
+	return nil, fmt.Errorf("error when encoding plist: unknown format %d", format)
+}
+
+// resolvePlistValue
+//
+// converts 'v' into one of the values handled by the encoders: `*Map[string, any]`, `[]any`,
+// string, bool, int64, uint64 (above math.MaxInt64), float64, time.Time, []byte or PlistUID.
+func resolvePlistValue(where string, v any) (any, error) {
+	for {
+		switch x := v.(type) {
+		case nil:
+			return nil, fmt.Errorf("error when encoding plist %s: null values can not be represented in a plist", where)
+
+		case Any:
+			v = x.v
+			continue
+		case *Any:
+			if x == nil {
+				v = nil
+				continue
+			}
+			v = x.v
+			continue
+		case *YAMLAnchor:
+			v = x.Value
+			continue
+		case *YAMLScalar:
+			v = x.Value
+			continue
+
+		case *Map[string, any]:
+			if x == nil {
+				v = nil
+				continue
+			}
+			return x, nil
+		case *Map[any, any]:
+			if x == nil {
+				v = nil
+				continue
+			}
+			res := &Map[string, any]{}
+			for _, k := range x.Keys() {
+				key, err := mapKeyString(reflect.ValueOf(k))
+				if err != nil {
+					return nil, fmt.Errorf("error when encoding plist %s: %w", where, err)
+				}
+				res.Set(key, x.Get(k))
+			}
+			return res, nil
+
+		case []any, string, bool, int64, float64, time.Time, []byte, PlistUID:
+			return x, nil
+		case uint64:
+			if x <= math.MaxInt64 {
+				return int64(x), nil
+			}
+			return x, nil
+		case json.Number:
+			if i, err := x.Int64(); err == nil {
+				return i, nil
+			}
+			f, err := x.Float64()
+			if err != nil {
+				return nil, fmt.Errorf("error when encoding plist %s: %w", where, err)
+			}
+			return f, nil
+		case encoding.TextMarshaler:
+			if rv := reflect.ValueOf(x); rv.Kind() == reflect.Pointer && rv.IsNil() {
+				v = nil
+				continue
+			}
+			bs, err := x.MarshalText()
+			if err != nil {
+				return nil, fmt.Errorf("error when encoding plist %s: %w", where, err)
+			}
+			return string(bs), nil
+		}
+
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.String:
+			return rv.String(), nil
+		case reflect.Bool:
+			return rv.Bool(), nil
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return rv.Int(), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			v = rv.Uint()
+			continue
+		case reflect.Float32, reflect.Float64:
+			return rv.Float(), nil
+		case reflect.Pointer, reflect.Interface:
+			if rv.IsNil() {
+				v = nil
+				continue
+			}
+		}
+
+		// structs, go maps, slices and Maps of other types
+		res, err := toAnyValue(where, rv)
+		if err != nil {
+			return nil, err
+		}
+		if reflect.TypeOf(res) == reflect.TypeOf(v) {
+			return nil, fmt.Errorf("error when encoding plist %s: unsupported type %T", where, v)
+		}
+		v = res
+	}
+}
+
+type plistXMLEncoder struct {
+	buf bytes.Buffer
+}
+
+func (e *plistXMLEncoder) writeLine(level int, s string) {
+	for i := 0; i < level; i++ {
+		e.buf.WriteByte('\t')
+	}
+	e.buf.WriteString(s)
+	e.buf.WriteByte('\n')
+}
+
+func (e *plistXMLEncoder) writeValue(where string, v any, level int) error {
+	switch x := v.(type) {
+	case *Map[string, any]:
+		if x.Len() == 0 {
+			e.writeLine(level, "<dict/>")
+			return nil
+		}
+		e.writeLine(level, "<dict>")
+		for _, k := range x.Keys() {
+			key, err := plistXMLText(where, k)
+			if err != nil {
+				return err
+			}
+			e.writeLine(level+1, "<key>"+key+"</key>")
+			elem, err := resolvePlistValue(where+"."+k, x.Get(k))
+			if err != nil {
+				return err
+			}
+			err = e.writeValue(where+"."+k, elem, level+1)
+			if err != nil {
+				return err
+			}
+		}
+		e.writeLine(level, "</dict>")
+
+	case []any:
+		if len(x) == 0 {
+			e.writeLine(level, "<array/>")
+			return nil
+		}
+		e.writeLine(level, "<array>")
+		for i, item := range x {
+			w := fmt.Sprintf("%s[%d]", where, i)
+			elem, err := resolvePlistValue(w, item)
+			if err != nil {
+				return err
+			}
+			err = e.writeValue(w, elem, level+1)
+			if err != nil {
+				return err
+			}
+		}
+		e.writeLine(level, "</array>")
+
+	case string:
+		s, err := plistXMLText(where, x)
+		if err != nil {
+			return err
+		}
+		e.writeLine(level, "<string>"+s+"</string>")
+	case bool:
+		e.writeLine(level, "<"+strconv.FormatBool(x)+"/>")
+	case int64:
+		e.writeLine(level, "<integer>"+strconv.FormatInt(x, 10)+"</integer>")
+	case uint64:
+		e.writeLine(level, "<integer>"+strconv.FormatUint(x, 10)+"</integer>")
+	case float64:
+		e.writeLine(level, "<real>"+formatPlistReal(x)+"</real>")
+	case time.Time:
+		e.writeLine(level, "<date>"+x.UTC().Format("2006-01-02T15:04:05Z")+"</date>")
+	case []byte:
+		// base64 lines fit in 76 columns, with tabs counted as 8 columns
+		e.writeLine(level, "<data>")
+		width := 76 - 8*level
+		if width < 16 {
+			width = 16
+		}
+		chunk := width / 4 * 3
+		for i := 0; i < len(x); i += chunk {
+			end := i + chunk
+			if end > len(x) {
+				end = len(x)
+			}
+			e.writeLine(level, base64.StdEncoding.EncodeToString(x[i:end]))
+		}
+		e.writeLine(level, "</data>")
+	case PlistUID:
+		e.writeLine(level, "<dict>")
+		e.writeLine(level+1, "<key>CF$UID</key>")
+		e.writeLine(level+1, "<integer>"+strconv.FormatUint(uint64(x), 10)+"</integer>")
+		e.writeLine(level, "</dict>")
+	default:
+		return fmt.Errorf("error when encoding plist %s: unsupported type %T", where, v)
+	}
+	return nil
+}
+
+// plistXMLText escapes 's' as the text of an element.
+func plistXMLText(where, s string) (string, error) {
+	var sb strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '&':
+			sb.WriteString("&amp;")
+		case r == '<':
+			sb.WriteString("&lt;")
+		case r == '>':
+			sb.WriteString("&gt;")
+		case r == '\r':
+			sb.WriteString("&#13;")
+		case r < 0x20 && r != '\t' && r != '\n':
+			return "", fmt.Errorf("error when encoding plist %s: control character %U can not be represented in XML", where, r)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String(), nil
+}
+
+// formatPlistReal formats 'f' the same way as the shortest representation of Python, which is
+// used by plistlib: integral values keep a ".0", exponents are used below 1e-4 and from 1e16.
+func formatPlistReal(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "nan"
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	if f == 0 || math.Abs(f) >= 1e-4 && math.Abs(f) < 1e16 {
+		s := strconv.FormatFloat(f, 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return s
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// parseXMLPlist parses a property list in the XML format.
+func parseXMLPlist(data []byte) (any, error) {
+	r := &plistXMLReader{d: xml.NewDecoder(bytes.NewReader(data))}
+	start, err := r.nextStart()
+	if err == io.EOF {
+		return nil, errors.New("error when decoding plist: empty document")
+	}
+	if err != nil {
+		return nil, r.errorf("%w", err)
+	}
+
+	if start.Name.Local == "plist" {
+		start, err = r.nextStart()
+		if err != nil {
+			if err == errPlistEnd {
+				return nil, r.errorf("empty plist element")
+			}
+			return nil, r.errorf("%w", err)
+		}
+	}
+	res, err := r.readValue(start, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// only the closing plist element and white space can follow
+	for {
+		tok, err := r.d.Token()
+		if err == io.EOF {
+			return res, nil
+		}
+		if err != nil {
+			return nil, r.errorf("%w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			return nil, r.errorf("unexpected element <%s> after the value", t.Name.Local)
+		case xml.CharData:
+			if len(bytes.TrimSpace(t)) > 0 {
+				return nil, r.errorf("unexpected text %q after the value", string(t))
+			}
+		}
+	}
+}
+
+var errPlistEnd = errors.New("end of element")
+
+type plistXMLReader struct {
+	d *xml.Decoder
+}
+
+func (r *plistXMLReader) errorf(format string, args ...any) error {
+	line, _ := r.d.InputPos()
+	return fmt.Errorf("error when decoding plist at line %d: %w", line, fmt.Errorf(format, args...))
+}
+
+// nextStart returns the next start element, skipping white space, comments and directives.
+// It returns errPlistEnd at the end of the current element.
+func (r *plistXMLReader) nextStart() (xml.StartElement, error) {
+	for {
+		tok, err := r.d.Token()
+		if err != nil {
+			return xml.StartElement{}, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			return t, nil
+		case xml.EndElement:
+			return xml.StartElement{}, errPlistEnd
+		case xml.CharData:
+			if len(bytes.TrimSpace(t)) > 0 {
+				return xml.StartElement{}, fmt.Errorf("unexpected text %q", string(t))
+			}
+		}
+	}
+}
+
+// readText reads the text of a leaf element, up to its end.
+func (r *plistXMLReader) readText(start xml.StartElement) (string, error) {
+	var sb strings.Builder
+	for {
+		tok, err := r.d.Token()
+		if err != nil {
+			return "", r.errorf("%w", err)
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			sb.Write(t)
+		case xml.EndElement:
+			return sb.String(), nil
+		case xml.StartElement:
+			return "", r.errorf("unexpected element <%s> in <%s>", t.Name.Local, start.Name.Local)
+		}
+	}
+}
+
+func (r *plistXMLReader) readValue(start xml.StartElement, depth int) (any, error) {
+	if depth > maxPlistDepth {
+		return nil, r.errorf("exceeded max nesting depth %d", maxPlistDepth)
+	}
+
+	switch start.Name.Local {
+	case "dict":
+		res := &Map[string, any]{}
+		for {
+			keyStart, err := r.nextStart()
+			if err == errPlistEnd {
+				break
+			}
+			if err != nil {
+				return nil, r.errorf("%w", err)
+			}
+			if keyStart.Name.Local != "key" {
+				return nil, r.errorf("expected <key> in <dict>, got <%s>", keyStart.Name.Local)
+			}
+			key, err := r.readText(keyStart)
+			if err != nil {
+				return nil, err
+			}
+			if _, exists := res.Get2(key); exists {
+				return nil, r.errorf("duplicate key %q", key)
+			}
+			valueStart, err := r.nextStart()
+			if err != nil {
+				if err == errPlistEnd {
+					return nil, r.errorf("missing value for key %q", key)
+				}
+				return nil, r.errorf("%w", err)
+			}
+			v, err := r.readValue(valueStart, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			res.Set(key, v)
+		}
+		if res.Len() == 1 {
+			if n, ok := res.Get("CF$UID").(int64); ok && n >= 0 {
+				return PlistUID(n), nil
+			}
+		}
+		return res, nil
+
+	case "array":
+		res := []any{}
+		for {
+			itemStart, err := r.nextStart()
+			if err == errPlistEnd {
+				return res, nil
+			}
+			if err != nil {
+				return nil, r.errorf("%w", err)
+			}
+			v, err := r.readValue(itemStart, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, v)
+		}
+
+	case "true", "false":
+		s, err := r.readText(start)
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(s) != "" {
+			return nil, r.errorf("unexpected text in <%s>", start.Name.Local)
+		}
+		return start.Name.Local == "true", nil
+	}
+
+	s, err := r.readText(start)
+	if err != nil {
+		return nil, err
+	}
+	switch start.Name.Local {
+	case "string":
+		return s, nil
+	case "integer":
+		s = strings.TrimSpace(s)
+		if v, ok := parsePlistInteger(s); ok {
+			return v, nil
+		}
+		return nil, r.errorf("invalid integer %q", s)
+	case "real":
+		s = strings.TrimSpace(s)
+		switch strings.ToLower(s) {
+		case "nan":
+			return math.NaN(), nil
+		case "inf", "+inf", "infinity", "+infinity":
+			return math.Inf(1), nil
+		case "-inf", "-infinity":
+			return math.Inf(-1), nil
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, r.errorf("invalid real %q", s)
+		}
+		return f, nil
+	case "date":
+		t, err := time.Parse(time.RFC3339, strings.TrimSpace(s))
+		if err != nil {
+			return nil, r.errorf("invalid date %q", s)
+		}
+		return t.UTC(), nil
+	case "data":
+		clean := strings.Map(func(r rune) rune {
+			if r == ' ' || r == '\t' || r == '\n' || r == '\r' {
+				return -1
+			}
+			return r
+		}, s)
+		bs, err := base64.StdEncoding.DecodeString(clean)
+		if err != nil {
+			return nil, r.errorf("invalid data: %w", err)
+		}
+		return bs, nil
+	}
+	return nil, r.errorf("unknown element <%s>", start.Name.Local)
+}
+
+// parsePlistInteger
+//
+// parses the text of an <integer> element: a decimal integer, or a hexadecimal integer with
+// a "0x" prefix. Leading zeros do not denote octal, and '_' separators are not accepted.
+func parsePlistInteger(s string) (any, bool) {
+	base := 10
+	if len(s) > 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') {
+		base = 16
+		s = s[2:]
+		if s[0] == '+' || s[0] == '-' {
+			return nil, false
+		}
+	}
+	if i, err := strconv.ParseInt(s, base, 64); err == nil {
+		return i, true
+	}
+	if u, err := strconv.ParseUint(s, base, 64); err == nil {
+		return u, true
+	}
+	return nil, false
+}
@@ -0,0 +1,539 @@
+package ordmap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+	"unicode/utf16"
+)
+
+// plistEpoch is the reference date of the dates of binary property lists.
+var plistEpoch = time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// plistBinaryReader decodes the objects of a binary property list.
+type plistBinaryReader struct {
+	data    []byte
+	offsets []uint64
+	refSize int
+	// visiting holds the objects being decoded, to detect cycles, and budget limits the number
+	// of decoded values, as objects can be referenced several times
+	visiting map[uint64]bool
+	budget   int
+}
+
+// parseBinaryPlist parses a property list in the binary format.
+func parseBinaryPlist(data []byte) (any, error) {
+	if len(data) < 8+32 || !bytes.HasPrefix(data, []byte("bplist00")) {
+		return nil, errors.New("error when decoding binary plist: invalid header")
+	}
+
+	trailer := data[len(data)-32:]
+	offsetSize := int(trailer[6])
+	refSize := int(trailer[7])
+	numObjects := binary.BigEndian.Uint64(trailer[8:])
+	top := binary.BigEndian.Uint64(trailer[16:])
+	tableOffset := binary.BigEndian.Uint64(trailer[24:])
+
+	end := uint64(len(data) - 32)
+	switch {
+	case offsetSize != 1 && offsetSize != 2 && offsetSize != 4 && offsetSize != 8,
+		refSize != 1 && refSize != 2 && refSize != 4 && refSize != 8:
+		return nil, errors.New("error when decoding binary plist: invalid trailer")
+	case numObjects == 0 || top >= numObjects || tableOffset < 8 || tableOffset > end,
+		numObjects > (end-tableOffset)/uint64(offsetSize):
+		return nil, errors.New("error when decoding binary plist: invalid trailer")
+	}
+
+	r := &plistBinaryReader{
+		data:     data[:tableOffset],
+		offsets:  make([]uint64, numObjects),
+		refSize:  refSize,
+		visiting: make(map[uint64]bool),
+		budget:   len(data),
+	}
+	for i := range r.offsets {
+		p := tableOffset + uint64(i*offsetSize)
+		r.offsets[i] = readPlistUint(data[p : p+uint64(offsetSize)])
+		if r.offsets[i] < 8 || r.offsets[i] >= tableOffset {
+			return nil, fmt.Errorf("error when decoding binary plist: invalid offset of object %d", i)
+		}
+	}
+	return r.readObject(top, 0)
+}
+
+func readPlistUint(b []byte) uint64 {
+	var n uint64
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return n
+}
+
+func (r *plistBinaryReader) errorf(offset uint64, format string, args ...any) error {
+	return fmt.Errorf("error when decoding binary plist at offset %d: %w", offset, fmt.Errorf(format, args...))
+}
+
+// bytesAt returns the 'n' bytes at 'offset'.
+func (r *plistBinaryReader) bytesAt(offset, n uint64) ([]byte, error) {
+	if n > uint64(len(r.data)) || offset > uint64(len(r.data))-n {
+		return nil, r.errorf(offset, "object exceeds the object table")
+	}
+	return r.data[offset : offset+n], nil
+}
+
+// readSize reads the size encoded in the marker at 'offset', and returns the size and the
+// offset of the content.
+func (r *plistBinaryReader) readSize(offset uint64) (uint64, uint64, error) {
+	n := uint64(r.data[offset] & 0x0f)
+	if n != 0x0f {
+		return n, offset + 1, nil
+	}
+	head, err := r.bytesAt(offset+1, 1)
+	if err != nil {
+		return 0, 0, err
+	}
+	if head[0]&0xf0 != 0x10 || head[0]&0x0f > 3 {
+		return 0, 0, r.errorf(offset+1, "invalid size marker 0x%02x", head[0])
+	}
+	width := uint64(1) << (head[0] & 0x0f)
+	b, err := r.bytesAt(offset+2, width)
+	if err != nil {
+		return 0, 0, err
+	}
+	return readPlistUint(b), offset + 2 + width, nil
+}
+
+// readRefs reads 'n' object references at 'offset'.
+func (r *plistBinaryReader) readRefs(offset, n uint64) ([]uint64, error) {
+	if n > uint64(len(r.data)) {
+		return nil, r.errorf(offset, "object exceeds the object table")
+	}
+	b, err := r.bytesAt(offset, n*uint64(r.refSize))
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]uint64, n)
+	for i := range refs {
+		refs[i] = readPlistUint(b[i*r.refSize : (i+1)*r.refSize])
+		if refs[i] >= uint64(len(r.offsets)) {
+			return nil, r.errorf(offset, "invalid object reference %d", refs[i])
+		}
+	}
+	return refs, nil
+}
+
+func (r *plistBinaryReader) readObject(ref uint64, depth int) (any, error) {
+	offset := r.offsets[ref]
+	if depth > maxPlistDepth {
+		return nil, r.errorf(offset, "exceeded max nesting depth %d", maxPlistDepth)
+	}
+	r.budget--
+	if r.budget < 0 {
+		return nil, r.errorf(offset, "too many references to shared objects")
+	}
+
+	marker := r.data[offset]
+	switch marker >> 4 {
+	case 0x0:
+		switch marker {
+		case 0x08:
+			return false, nil
+		case 0x09:
+			return true, nil
+		}
+
+	case 0x1:
+		if marker&0x0f > 4 {
+			break
+		}
+		width := uint64(1) << (marker & 0x0f)
+		b, err := r.bytesAt(offset+1, width)
+		if err != nil {
+			return nil, err
+		}
+		if width < 16 {
+			// 1, 2 and 4 bytes integers are unsigned, 8 bytes integers are signed
+			return int64(readPlistUint(b)), nil
+		}
+		high, low := readPlistUint(b[:8]), readPlistUint(b[8:])
+		switch {
+		case high == 0 && low > math.MaxInt64:
+			return low, nil
+		case high == 0 || high == math.MaxUint64 && low > math.MaxInt64:
+			return int64(low), nil
+		}
+		return nil, r.errorf(offset, "integer overflows 64 bits")
+
+	case 0x2:
+		switch marker & 0x0f {
+		case 2:
+			b, err := r.bytesAt(offset+1, 4)
+			if err != nil {
+				return nil, err
+			}
+			return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+		case 3:
+			b, err := r.bytesAt(offset+1, 8)
+			if err != nil {
+				return nil, err
+			}
+			return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+		}
+
+	case 0x3:
+		if marker == 0x33 {
+			b, err := r.bytesAt(offset+1, 8)
+			if err != nil {
+				return nil, err
+			}
+			secs := math.Float64frombits(binary.BigEndian.Uint64(b))
+			if math.IsNaN(secs) || math.Abs(secs) > 1e14 {
+				return nil, r.errorf(offset, "invalid date")
+			}
+			whole, frac := math.Modf(secs)
+			return time.Unix(plistEpoch.Unix()+int64(whole), int64(math.Round(frac*1e9))).UTC(), nil
+		}
+
+	case 0x4, 0x5, 0x6:
+		n, start, err := r.readSize(offset)
+		if err != nil {
+			return nil, err
+		}
+		switch marker >> 4 {
+		case 0x4:
+			b, err := r.bytesAt(start, n)
+			if err != nil {
+				return nil, err
+			}
+			return append([]byte{}, b...), nil
+		case 0x5:
+			b, err := r.bytesAt(start, n)
+			if err != nil {
+				return nil, err
+			}
+			// bytes are ASCII, read as Latin-1 for robustness
+			runes := make([]rune, len(b))
+			for i, c := range b {
+				runes[i] = rune(c)
+			}
+			return string(runes), nil
+		default:
+			if n > uint64(len(r.data)) {
+				return nil, r.errorf(offset, "object exceeds the object table")
+			}
+			b, err := r.bytesAt(start, 2*n)
+			if err != nil {
+				return nil, err
+			}
+			units := make([]uint16, n)
+			for i := range units {
+				units[i] = binary.BigEndian.Uint16(b[2*i:])
+			}
+			return string(utf16.Decode(units)), nil
+		}
+
+	case 0x8:
+		b, err := r.bytesAt(offset+1, uint64(marker&0x0f)+1)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) > 8 {
+			return nil, r.errorf(offset, "UID overflows 64 bits")
+		}
+		return PlistUID(readPlistUint(b)), nil
+
+	case 0xa, 0xd:
+		n, start, err := r.readSize(offset)
+		if err != nil {
+			return nil, err
+		}
+		if r.visiting[ref] {
+			return nil, r.errorf(offset, "cycle of object references")
+		}
+		r.visiting[ref] = true
+		defer delete(r.visiting, ref)
+
+		if marker>>4 == 0xa {
+			refs, err := r.readRefs(start, n)
+			if err != nil {
+				return nil, err
+			}
+			res := make([]any, n)
+			for i, ref := range refs {
+				res[i], err = r.readObject(ref, depth+1)
+				if err != nil {
+					return nil, err
+				}
+			}
+			return res, nil
+		}
+
+		if n > uint64(len(r.data)) {
+			return nil, r.errorf(offset, "object exceeds the object table")
+		}
+		refs, err := r.readRefs(start, 2*n)
+		if err != nil {
+			return nil, err
+		}
+		res := &Map[string, any]{}
+		for i := uint64(0); i < n; i++ {
+			k, err := r.readObject(refs[i], depth+1)
+			if err != nil {
+				return nil, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, r.errorf(offset, "dict key of type %T, expected a string", k)
+			}
+			if _, exists := res.Get2(key); exists {
+				return nil, r.errorf(offset, "duplicate key %q", key)
+			}
+			v, err := r.readObject(refs[n+i], depth+1)
+			if err != nil {
+				return nil, err
+			}
+			res.Set(key, v)
+		}
+		return res, nil
+	}
+	return nil, r.errorf(offset, "unsupported object marker 0x%02x", marker)
+}
+
+// plistBinaryEncoder writes a binary property list.
+//
+// Objects are numbered depth-first, with the keys of a dict before its values, and equal
+// scalars (but not UIDs) share the same object, which is the layout written by Python's plistlib.
+type plistBinaryEncoder struct {
+	objects []any
+	// shared maps the scalars to their object number
+	shared map[plistScalarKey]int
+	// refs holds the object numbers of the elements of the containers
+	refs map[int][]int
+}
+
+type plistScalarKey struct {
+	kind byte
+	s    string
+	i    int64
+	f    float64
+}
+
+func plistKey(v any) (plistScalarKey, bool) {
+	switch x := v.(type) {
+	case string:
+		return plistScalarKey{kind: 's', s: x}, true
+	case bool:
+		if x {
+			return plistScalarKey{kind: 'b', i: 1}, true
+		}
+		return plistScalarKey{kind: 'b'}, true
+	case int64:
+		return plistScalarKey{kind: 'i', i: x}, true
+	case uint64:
+		return plistScalarKey{kind: 'u', i: int64(x)}, true
+	case float64:
+		return plistScalarKey{kind: 'f', f: x}, true
+	case time.Time:
+		return plistScalarKey{kind: 't', i: x.UnixNano()}, true
+	case []byte:
+		return plistScalarKey{kind: 'd', s: string(x)}, true
+	}
+	return plistScalarKey{}, false
+}
+
+func encodeBinaryPlist(root any) ([]byte, error) {
+	e := &plistBinaryEncoder{shared: make(map[plistScalarKey]int), refs: make(map[int][]int)}
+	_, err := e.flatten("", root, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	refSize := plistUintSize(uint64(len(e.objects)))
+	var buf bytes.Buffer
+	buf.WriteString("bplist00")
+	offsets := make([]uint64, len(e.objects))
+	for i, o := range e.objects {
+		offsets[i] = uint64(buf.Len())
+		err = e.writeObject(&buf, i, o, refSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tableOffset := uint64(buf.Len())
+	offsetSize := plistUintSize(tableOffset)
+	for _, o := range offsets {
+		writePlistUint(&buf, o, offsetSize)
+	}
+
+	trailer := make([]byte, 32)
+	trailer[6] = byte(offsetSize)
+	trailer[7] = byte(refSize)
+	binary.BigEndian.PutUint64(trailer[8:], uint64(len(e.objects)))
+	binary.BigEndian.PutUint64(trailer[16:], 0)
+	binary.BigEndian.PutUint64(trailer[24:], tableOffset)
+	buf.Write(trailer)
+	return buf.Bytes(), nil
+}
+
+// flatten numbers 'v' and its elements, and returns the object number of 'v'.
+func (e *plistBinaryEncoder) flatten(where string, v any, depth int) (int, error) {
+	if depth > maxPlistDepth {
+		return 0, fmt.Errorf("error when encoding plist %s: exceeded max nesting depth %d", where, maxPlistDepth)
+	}
+	if key, ok := plistKey(v); ok {
+		if n, exists := e.shared[key]; exists {
+			return n, nil
+		}
+		e.shared[key] = len(e.objects)
+	}
+	n := len(e.objects)
+	e.objects = append(e.objects, v)
+
+	var items []any
+	var names []string
+	switch x := v.(type) {
+	case *Map[string, any]:
+		for _, k := range x.Keys() {
+			items = append(items, k)
+			names = append(names, where)
+		}
+		for _, k := range x.Keys() {
+			items = append(items, x.Get(k))
+			names = append(names, where+"."+k)
+		}
+	case []any:
+		for i, item := range x {
+			items = append(items, item)
+			names = append(names, fmt.Sprintf("%s[%d]", where, i))
+		}
+	}
+	for i, item := range items {
+		item, err := resolvePlistValue(names[i], item)
+		if err != nil {
+			return 0, err
+		}
+		ref, err := e.flatten(names[i], item, depth+1)
+		if err != nil {
+			return 0, err
+		}
+		e.refs[n] = append(e.refs[n], ref)
+	}
+	return n, nil
+}
+
+// plistUintSize returns the number of bytes needed to write 'n': 1, 2, 4 or 8.
+func plistUintSize(n uint64) int {
+	switch {
+	case n < 1<<8:
+		return 1
+	case n < 1<<16:
+		return 2
+	case n < 1<<32:
+		return 4
+	}
+	return 8
+}
+
+func writePlistUint(buf *bytes.Buffer, n uint64, size int) {
+	for i := size - 1; i >= 0; i-- {
+		buf.WriteByte(byte(n >> (8 * i)))
+	}
+}
+
+// writePlistSize writes the marker 'marker' with the size 'n'.
+func writePlistSize(buf *bytes.Buffer, marker byte, n int) {
+	if n < 15 {
+		buf.WriteByte(marker | byte(n))
+		return
+	}
+	buf.WriteByte(marker | 0x0f)
+	writePlistInt(buf, uint64(n))
+}
+
+// writePlistInt writes the non-negative integer 'n' with the smallest width.
+func writePlistInt(buf *bytes.Buffer, n uint64) {
+	size := plistUintSize(n)
+	switch size {
+	case 1:
+		buf.WriteByte(0x10)
+	case 2:
+		buf.WriteByte(0x11)
+	case 4:
+		buf.WriteByte(0x12)
+	default:
+		buf.WriteByte(0x13)
+	}
+	writePlistUint(buf, n, size)
+}
+
+func (e *plistBinaryEncoder) writeObject(buf *bytes.Buffer, n int, v any, refSize int) error {
+	switch x := v.(type) {
+	case bool:
+		if x {
+			buf.WriteByte(0x09)
+		} else {
+			buf.WriteByte(0x08)
+		}
+	case int64:
+		if x < 0 {
+			buf.WriteByte(0x13)
+			writePlistUint(buf, uint64(x), 8)
+		} else {
+			writePlistInt(buf, uint64(x))
+		}
+	case uint64:
+		// above math.MaxInt64, written as a 128-bit integer
+		buf.WriteByte(0x14)
+		writePlistUint(buf, 0, 8)
+		writePlistUint(buf, x, 8)
+	case float64:
+		buf.WriteByte(0x23)
+		writePlistUint(buf, math.Float64bits(x), 8)
+	case time.Time:
+		buf.WriteByte(0x33)
+		secs := float64(x.Unix()-plistEpoch.Unix()) + float64(x.Nanosecond())/1e9
+		writePlistUint(buf, math.Float64bits(secs), 8)
+	case []byte:
+		writePlistSize(buf, 0x40, len(x))
+		buf.Write(x)
+	case string:
+		ascii := true
+		for i := 0; i < len(x); i++ {
+			if x[i] >= 0x80 {
+				ascii = false
+				break
+			}
+		}
+		if ascii {
+			writePlistSize(buf, 0x50, len(x))
+			buf.WriteString(x)
+			break
+		}
+		units := utf16.Encode([]rune(x))
+		writePlistSize(buf, 0x60, len(units))
+		for _, u := range units {
+			writePlistUint(buf, uint64(u), 2)
+		}
+	case PlistUID:
+		size := plistUintSize(uint64(x))
+		buf.WriteByte(0x80 | byte(size-1))
+		writePlistUint(buf, uint64(x), size)
+	case *Map[string, any]:
+		writePlistSize(buf, 0xd0, x.Len())
+		for _, ref := range e.refs[n] {
+			writePlistUint(buf, uint64(ref), refSize)
+		}
+	case []any:
+		writePlistSize(buf, 0xa0, len(x))
+		for _, ref := range e.refs[n] {
+			writePlistUint(buf, uint64(ref), refSize)
+		}
+	default:
+		return fmt.Errorf("error when encoding plist: unsupported type %T", v)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package ordmap
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The files in testdata/plist were written by Python's plistlib, with sort_keys=False.
+
+func plistInfo() *Map[string, any] {
+	icon := make([]byte, 100)
+	for i := range icon {
+		icon[i] = byte(i)
+	}
+	nested := &Map[string, any]{}
+	nested.Set("nested", "x")
+
+	m := &Map[string, any]{}
+	m.Set("CFBundleName", "Demo")
+	m.Set("CFBundleIdentifier", "com.example.demo")
+	m.Set("CFBundleVersion", "42")
+	m.Set("LSRequiresIPhoneOS", true)
+	m.Set("Disabled", false)
+	m.Set("Count", int64(3))
+	m.Set("Negative", int64(-5))
+	m.Set("Big", uint64(math.MaxUint64))
+	m.Set("Ratio", 1.5)
+	m.Set("WholeReal", 2.0)
+	m.Set("Tiny", 1e-07)
+	m.Set("Built", time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC))
+	m.Set("Icon", icon)
+	m.Set("Name ünïcode", "日本語 & <tags>")
+	m.Set("Empty", &Map[string, any]{})
+	m.Set("List", []any{"Demo", int64(3), 1.5, []any{}, nested, int64(1 << 20)})
+	return m
+}
+
+func TestPlist_Golden(t *testing.T) {
+	for file, format := range map[string]PlistFormat{
+		"info.plist":  PlistXML,
+		"info.bplist": PlistBinary,
+	} {
+		golden, err := os.ReadFile(filepath.Join("testdata", "plist", file))
+		require.NoError(t, err)
+		assert.Equal(t, format, DetectPlistFormat(golden), file)
+
+		var x Any
+		require.NoError(t, UnmarshalPlist(golden, &x), file)
+		assert.Equal(t, plistInfo(), x.V(), file)
+
+		out, err := MarshalPlist(x, format)
+		require.NoError(t, err, file)
+		assert.Equal(t, golden, out, file)
+	}
+}
+
+func TestPlist_UID(t *testing.T) {
+	golden, err := os.ReadFile(filepath.Join("testdata", "plist", "keyed.bplist"))
+	require.NoError(t, err)
+
+	var x Any
+	require.NoError(t, UnmarshalPlist(golden, &x))
+	m := x.V().(*Map[string, any])
+	assert.Equal(t, []string{"$archiver", "$top", "$objects", "$version"}, m.Keys())
+	assert.Equal(t, PlistUID(1), m.Get("$top").(*Map[string, any]).Get("root"))
+	object := m.Get("$objects").([]any)[1].(*Map[string, any])
+	assert.Equal(t, PlistUID(300), object.Get("$class"))
+
+	out, err := MarshalPlist(x, PlistBinary)
+	require.NoError(t, err)
+	assert.Equal(t, golden, out)
+
+	// UIDs are written as CF$UID dicts in the XML format:
+	out, err = MarshalPlist(x, PlistXML)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "<dict>\n\t\t<key>root</key>\n\t\t<dict>\n\t\t\t<key>CF$UID</key>\n\t\t\t<integer>1</integer>\n\t\t</dict>\n")
+	var back Any
+	require.NoError(t, UnmarshalPlist(out, &back))
+	assert.Equal(t, x, back)
+}
+
+func TestPlist_Struct(t *testing.T) {
+	type Info struct {
+		Name    string            `json:"CFBundleName"`
+		Version int               `json:"CFBundleVersion,string"`
+		Count   uint8             `json:"Count"`
+		Ratio   float32           `json:"Ratio"`
+		Built   time.Time         `json:"Built"`
+		Icon    []byte            `json:"Icon"`
+		Extra   map[string]string `json:"Extra,omitempty"`
+		Ignored string            `json:"-"`
+	}
+	in := Info{
+		Name:  "Demo",
+		Count: 3,
+		Ratio: 0.25,
+		Built: time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC),
+		Icon:  []byte{1, 2},
+		Extra: map[string]string{"b": "2", "a": "1"},
+	}
+
+	for _, format := range []PlistFormat{PlistXML, PlistBinary} {
+		out, err := MarshalPlist(in, format)
+		require.NoError(t, err)
+
+		var x Any
+		require.NoError(t, UnmarshalPlist(out, &x))
+		m := x.V().(*Map[string, any])
+		assert.Equal(t, []string{"CFBundleName", "CFBundleVersion", "Count", "Ratio", "Built", "Icon", "Extra"}, m.Keys())
+		assert.Equal(t, []string{"a", "b"}, m.Get("Extra").(*Map[string, any]).Keys())
+		assert.Equal(t, 0.25, m.Get("Ratio"))
+
+		var back Info
+		require.NoError(t, UnmarshalPlist(out, &back))
+		assert.Equal(t, in, back)
+	}
+
+	// a Map keeps its order:
+	om := &Map[string, int]{}
+	om.Set("z", 1)
+	om.Set("a", 2)
+	out, err := MarshalPlist(om, PlistBinary)
+	require.NoError(t, err)
+	var back Map[string, int]
+	require.NoError(t, UnmarshalPlist(out, &back))
+	assert.Equal(t, []string{"z", "a"}, back.Keys())
+
+	_, err = MarshalPlist(map[string]any{"a": nil}, PlistXML)
+	assert.ErrorContains(t, err, "null")
+	_, err = MarshalPlist(make(chan int), PlistBinary)
+	assert.Error(t, err)
+	_, err = MarshalPlist("a\x01", PlistXML)
+	assert.Error(t, err)
+}
+
+func TestPlist_Integers(t *testing.T) {
+	table := map[string]any{
+		"10":                   int64(10),
+		"010":                  int64(10), // not octal
+		" -5 ":                 int64(-5),
+		"+7":                   int64(7),
+		"0x1F":                 int64(31),
+		"0XfF":                 int64(255),
+		"0xFFFFFFFFFFFFFFFF":   uint64(math.MaxUint64),
+		"18446744073709551615": uint64(math.MaxUint64),
+	}
+	for input, expected := range table {
+		var x Any
+		require.NoError(t, UnmarshalPlist([]byte("<plist><integer>"+input+"</integer></plist>"), &x), input)
+		assert.Equal(t, expected, x.V(), input)
+	}
+}
+
+func TestPlist_Errors(t *testing.T) {
+	xmlInputs := []string{
+		"",
+		"<plist></plist>",
+		"<plist><dict><key>a</key></dict></plist>",
+		"<plist><dict><string>a</string><true/></dict></plist>",
+		"<plist><dict><key>a</key><true/><key>a</key><true/></dict></plist>",
+		"<plist><integer>x</integer></plist>",
+		"<plist><integer>1_000</integer></plist>",
+		"<plist><integer>0x</integer></plist>",
+		"<plist><integer>0x-1</integer></plist>",
+		"<plist><integer>0b101</integer></plist>",
+		"<plist><integer>0o17</integer></plist>",
+		"<plist><real>x</real></plist>",
+		"<plist><date>yesterday</date></plist>",
+		"<plist><data>!!</data></plist>",
+		"<plist><true>x</true></plist>",
+		"<plist><set/></plist>",
+		"<plist><true/><true/></plist>",
+		"<plist><string>a<b/></string></plist>",
+		"<plist><array>",
+	}
+	for _, input := range xmlInputs {
+		var x Any
+		assert.Error(t, UnmarshalPlist([]byte(input), &x), input)
+	}
+
+	golden, err := os.ReadFile(filepath.Join("testdata", "plist", "info.bplist"))
+	require.NoError(t, err)
+	var x Any
+	for _, n := range []int{8, 40, len(golden) - 1} {
+		assert.Error(t, UnmarshalPlist(golden[:n], &x), "truncated to %d bytes", n)
+	}
+
+	// an array which contains itself: a1 00, offset table, trailer
+	cycle := []byte("bplist00\xa1\x00\x08")
+	cycle = append(cycle, 0, 0, 0, 0, 0, 0, 1, 1)
+	cycle = append(cycle, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 10)
+	assert.ErrorContains(t, UnmarshalPlist(cycle, &x), "cycle")
+
+	// a dict with a non-string key: d1 01 01 10 05
+	intKey := []byte("bplist00\xd1\x01\x01\x10\x05\x08\x0b")
+	intKey = append(intKey, 0, 0, 0, 0, 0, 0, 1, 1)
+	intKey = append(intKey, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 13)
+	assert.ErrorContains(t, UnmarshalPlist(intKey, &x), "dict key")
+}
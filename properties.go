@@ -0,0 +1,264 @@
+package ordmap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// UnmarshalProperties parses 'data' in the Java .properties format, and returns its entries in
+// the order of the file. With repeated keys, the last value wins, at the position of the first
+// occurrence.
+//
+// The syntax is the one of `java.util.Properties.load()`: comment lines start with '#' or '!',
+// the key ends at the first unescaped '=', ':' or whitespace, a line ending with an odd number
+// of backslashes continues on the next line, and \t, \n, \r, \f and \uXXXX escapes are
+// decoded. 'data' is read as UTF-8.
+func UnmarshalProperties(data []byte) (*Map[string, string], error) {
+	d, err := ParsePropertiesDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	return d.Map(), nil
+}
+
+// MarshalProperties writes the entries of 'm' in the Java .properties format, one "key=value"
+// line per entry. Characters which are not printable ASCII are written as \uXXXX escapes, so that
+// the output can be read both as ISO-8859-1 and as UTF-8.
+func MarshalProperties(m *Map[string, string]) []byte {
+	var sb strings.Builder
+	for _, k := range m.Keys() {
+		v, _ := renderPropertiesValue(m.Get(k), 0)
+		sb.WriteString(renderPropertiesKey(k))
+		sb.WriteByte('=')
+		sb.WriteString(v)
+		sb.WriteByte('\n')
+	}
+	return []byte(sb.String())
+}
+
+// PropertiesDocument is an editable Java .properties file.
+//
+// It keeps the original text of the file: `Bytes()` returns the original file when it has not
+// been edited, and otherwise only rewrites the lines of the edited entries. Comment and blank
+// lines stay in place, new entries are appended at the end of the file.
+type PropertiesDocument struct {
+	doc lineDocument
+}
+
+// ParsePropertiesDocument parses 'data' into an editable PropertiesDocument, see
+// `UnmarshalProperties()` for the syntax.
+func ParsePropertiesDocument(data []byte) (*PropertiesDocument, error) {
+	src := string(data)
+	d := &PropertiesDocument{doc: lineDocument{
+		eol:         detectEOL(src),
+		renderKey:   renderPropertiesKey,
+		renderValue: renderPropertiesValue,
+	}}
+
+	lines := splitLines(src)
+	var before []string
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		content, eol := cutEOL(line)
+		trimmed := strings.TrimLeft(content, " \t\f")
+		if trimmed == "" || trimmed[0] == '#' || trimmed[0] == '!' {
+			before = append(before, line)
+			continue
+		}
+
+		e := &lineEntry{before: before, prefix: content[:len(content)-len(trimmed)]}
+		before = nil
+		lineno := i + 1
+		raw := line
+		for endsWithEscape(trimmed) {
+			trimmed = trimmed[:len(trimmed)-1]
+			if i+1 == len(lines) {
+				break
+			}
+			i++
+			raw += lines[i]
+			var next string
+			next, eol = cutEOL(lines[i])
+			trimmed += strings.TrimLeft(next, " \t\f")
+		}
+		e.raw, e.eol = raw, eol
+
+		// key, then whitespace with at most one '=' or ':'
+		k := 0
+		for k < len(trimmed) && !strings.ContainsRune("=: \t\f", rune(trimmed[k])) {
+			if trimmed[k] == '\\' {
+				k++
+			}
+			k++
+		}
+		if k > len(trimmed) {
+			k = len(trimmed)
+		}
+		s := k
+		for s < len(trimmed) && strings.ContainsRune(" \t\f", rune(trimmed[s])) {
+			s++
+		}
+		if s < len(trimmed) && (trimmed[s] == '=' || trimmed[s] == ':') {
+			s++
+		}
+		for s < len(trimmed) && strings.ContainsRune(" \t\f", rune(trimmed[s])) {
+			s++
+		}
+		e.rawKey, e.sep, e.rawValue = trimmed[:k], trimmed[k:s], trimmed[s:]
+
+		var err error
+		e.key, err = unescapeProperties(e.rawKey)
+		if err == nil {
+			e.value, err = unescapeProperties(e.rawValue)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error when parsing properties line %d: %w", lineno, err)
+		}
+		d.doc.entries = append(d.doc.entries, e)
+	}
+	d.doc.trailer = before
+	return d, nil
+}
+
+// Get returns the value of the key 'key', the last one if the key is repeated.
+func (d *PropertiesDocument) Get(key string) (string, bool) {
+	return d.doc.get(key)
+}
+
+// Set sets the value of the key 'key'. An existing entry is rewritten in place, keeping the
+// text of its key and its separator, the last one if the key is repeated. A new entry is
+// appended at the end of the document.
+func (d *PropertiesDocument) Set(key, value string) {
+	d.doc.set(key, value)
+}
+
+// Delete removes all the entries with the key 'key', along with the comment lines right above
+// them, and returns false if there was none.
+func (d *PropertiesDocument) Delete(key string) bool {
+	return d.doc.delete(key)
+}
+
+// Keys returns the keys of the document in the order of their first occurrence.
+func (d *PropertiesDocument) Keys() []string {
+	return d.doc.keys()
+}
+
+// Map returns the entries of the document, see `UnmarshalProperties()`.
+func (d *PropertiesDocument) Map() *Map[string, string] {
+	return d.doc.toMap()
+}
+
+// Bytes returns the text of the document.
+func (d *PropertiesDocument) Bytes() []byte {
+	return d.doc.bytes()
+}
+
+// endsWithEscape tells if 's' ends with an odd number of backslashes.
+func endsWithEscape(s string) bool {
+	n := 0
+	for n < len(s) && s[len(s)-1-n] == '\\' {
+		n++
+	}
+	return n%2 == 1
+}
+
+func unescapeProperties(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i+1 == len(s) {
+			sb.WriteByte(c)
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			sb.WriteByte('\t')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'u':
+			r, err := parseUnicodeEscape(s, i+1)
+			if err != nil {
+				return "", err
+			}
+			i += 4
+			// surrogate pairs are written as two escapes
+			if utf16.IsSurrogate(r) && strings.HasPrefix(s[i+1:], `\u`) {
+				if r2, err := parseUnicodeEscape(s, i+3); err == nil {
+					if pair := utf16.DecodeRune(r, r2); pair != utf8.RuneError {
+						r = pair
+						i += 6
+					}
+				}
+			}
+			sb.WriteRune(r)
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String(), nil
+}
+
+func parseUnicodeEscape(s string, i int) (rune, error) {
+	if i+4 > len(s) {
+		return 0, fmt.Errorf("malformed \\uXXXX escape %q", s[i-2:])
+	}
+	n, err := strconv.ParseUint(s[i:i+4], 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("malformed \\uXXXX escape %q", s[i-2:i+4])
+	}
+	return rune(n), nil
+}
+
+func renderPropertiesKey(key string) string {
+	return escapeProperties(key, true)
+}
+
+func renderPropertiesValue(value string, _ byte) (string, byte) {
+	return escapeProperties(value, false), 0
+}
+
+// escapeProperties escapes 's' the same way as `java.util.Properties.store()`.
+func escapeProperties(s string, isKey bool) string {
+	var sb strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			sb.WriteString(`\\`)
+		case r == '\t':
+			sb.WriteString(`\t`)
+		case r == '\n':
+			sb.WriteString(`\n`)
+		case r == '\r':
+			sb.WriteString(`\r`)
+		case r == '\f':
+			sb.WriteString(`\f`)
+		case r == ' ' && (isKey || i == 0):
+			sb.WriteString(`\ `)
+		case (r == '=' || r == ':') && isKey:
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case (r == '#' || r == '!') && i == 0:
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			for _, u := range utf16.Encode([]rune{r}) {
+				fmt.Fprintf(&sb, `\u%04X`, u)
+			}
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
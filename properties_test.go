@@ -0,0 +1,101 @@
+package ordmap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnmarshalProperties(t *testing.T) {
+	input := `# comment
+! other comment
+zeta = 1
+alpha:2
+  beta   3
+key\ with\=sep=a\tb\u00e9\uD83D\uDE00
+multi = one, \
+        two, \
+        three
+empty
+path=C:\\temp\\
+zeta=last
+`
+	m, err := UnmarshalProperties([]byte(input))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"zeta", "alpha", "beta", "key with=sep", "multi", "empty", "path"}, m.Keys())
+	assert.Equal(t, "last", m.Get("zeta"))
+	assert.Equal(t, "2", m.Get("alpha"))
+	assert.Equal(t, "3", m.Get("beta"))
+	assert.Equal(t, "a\tbé😀", m.Get("key with=sep"))
+	assert.Equal(t, "one, two, three", m.Get("multi"))
+	assert.Equal(t, "", m.Get("empty"))
+	assert.Equal(t, `C:\temp\`, m.Get("path"))
+
+	_, err = UnmarshalProperties([]byte("a=1\nb=\\u12\n"))
+	assert.ErrorContains(t, err, "line 2")
+}
+
+func TestMarshalProperties(t *testing.T) {
+	m := &Map[string, string]{}
+	m.Set("z key", "v")
+	m.Set("a:b", " lead = x")
+	m.Set("#c", "é😀\n")
+	m.Set("path", `C:\temp`)
+
+	out := MarshalProperties(m)
+	assert.Equal(t, `z\ key=v
+a\:b=\ lead = x
+\#c=\u00E9\uD83D\uDE00\n
+path=C:\\temp
+`, string(out))
+
+	back, err := UnmarshalProperties(out)
+	require.NoError(t, err)
+	assert.Equal(t, m, back)
+}
+
+func TestPropertiesDocument(t *testing.T) {
+	input := "# app settings\r\n" +
+		"name = demo\r\n" +
+		"\r\n" +
+		"# the port\r\n" +
+		"port: 8080\r\n" +
+		"list = a, \\\r\n" +
+		"       b\r\n" +
+		"# trailing"
+
+	d, err := ParsePropertiesDocument([]byte(input))
+	require.NoError(t, err)
+	assert.Equal(t, input, string(d.Bytes()), "unedited documents are kept as is")
+	assert.Equal(t, []string{"name", "port", "list"}, d.Keys())
+
+	v, ok := d.Get("list")
+	assert.True(t, ok)
+	assert.Equal(t, "a, b", v)
+
+	d.Set("name", "démo")
+	d.Set("list", "c")
+	d.Set("added", "yes")
+	assert.Equal(t, "# app settings\r\n"+
+		"name = d\\u00E9mo\r\n"+
+		"\r\n"+
+		"# the port\r\n"+
+		"port: 8080\r\n"+
+		"list = c\r\n"+
+		"# trailing\r\n"+
+		"added=yes\r\n", string(d.Bytes()))
+
+	assert.True(t, d.Delete("port"))
+	assert.False(t, d.Delete("port"))
+	assert.Equal(t, "# app settings\r\n"+
+		"name = d\\u00E9mo\r\n"+
+		"\r\n"+
+		"list = c\r\n"+
+		"# trailing\r\n"+
+		"added=yes\r\n", string(d.Bytes()))
+
+	m := d.Map()
+	assert.Equal(t, []string{"name", "list", "added"}, m.Keys())
+	assert.Equal(t, "démo", m.Get("name"))
+}
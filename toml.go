@@ -0,0 +1,140 @@
+package ordmap
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TOMLUnmarshaler is implemented by types which can decode themselves from a TOML value.
+//
+// 'v' is the decoded value: a `*Map[string, any]` for tables, `[]any` for arrays, and string,
+// int64, float64, bool, time.Time, TOMLLocalDateTime, TOMLLocalDate or TOMLLocalTime for scalars.
+type TOMLUnmarshaler interface {
+	UnmarshalTOML(v any) error
+}
+
+// TOMLMarshaler is implemented by types which can convert themselves into a value which
+// can be encoded to TOML (see `TOMLUnmarshaler` for the list of types).
+type TOMLMarshaler interface {
+	MarshalTOML() (any, error)
+}
+
+// UnmarshalTOML parses the TOML document 'data' and stores the result in 'v'.
+//
+// Tables are decoded as `*Map[string, any]` values, which keep the order in which the keys
+// appear in the document, including keys defined through dotted keys and sub-tables.
+//
+// If 'v' implements `TOMLUnmarshaler`, its UnmarshalTOML method is called with the decoded
+// document, otherwise the document is decoded into 'v' with `Any.DecodeInto()`.
+func UnmarshalTOML(data []byte, v any) error {
+	doc, err := parseTOML(data)
+	if err != nil {
+		return err
+	}
+	if u, ok := v.(TOMLUnmarshaler); ok {
+		return u.UnmarshalTOML(doc)
+	}
+	return Any{v: doc}.DecodeInto(v)
+}
+
+// MarshalTOML returns the TOML encoding of 'v', which must represent a table:
+// an Any holding an object, a Map, a struct or a go map.
+//
+// Keys are written in the order of the Map. Since TOML requires the key/value pairs of a table
+// to precede its sub-tables, a sub-table which appears before a key/value pair is written using
+// dotted keys ("server.port = 80"), other sub-tables are written as `[table]` sections, and arrays
+// of tables as `[[array]]` sections.
+func MarshalTOML(v any) ([]byte, error) {
+	e := &tomlEncoder{}
+	err := e.encodeDocument(v)
+	if err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+func (x *Any) UnmarshalTOML(v any) error {
+	x.v = v
+	return nil
+}
+
+func (x Any) MarshalTOML() (any, error) {
+	return x.v, nil
+}
+
+// UnmarshalTOML replaces the content of 'm' with the decoded table.
+func (m *Map[K, V]) UnmarshalTOML(v any) error {
+	return Any{v: v}.DecodeInto(m)
+}
+
+// MarshalTOML returns a `*Map[string, any]` with the entries of 'm', where keys are formatted
+// as strings the same way `encoding/json` formats the keys of go maps.
+func (m Map[K, V]) MarshalTOML() (any, error) {
+	res := &Map[string, any]{}
+	for _, k := range m.keys {
+		key, err := mapKeyString(reflect.ValueOf(k))
+		if err != nil {
+			return nil, fmt.Errorf("error when encoding toml key %v: %w", k, err)
+		}
+		res.Set(key, m.m[k])
+	}
+	return res, nil
+}
+
+// TOMLLocalDate is a TOML local date, such as 1979-05-27.
+type TOMLLocalDate struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// TOMLLocalTime is a TOML local time, such as 07:32:00.999.
+type TOMLLocalTime struct {
+	Hour       int
+	Minute     int
+	Second     int
+	Nanosecond int
+}
+
+// TOMLLocalDateTime is a TOML local date-time, such as 1979-05-27T07:32:00.
+type TOMLLocalDateTime struct {
+	Date TOMLLocalDate
+	Time TOMLLocalTime
+}
+
+func (d TOMLLocalDate) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, int(d.Month), d.Day)
+}
+
+func (d TOMLLocalDate) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (t TOMLLocalTime) String() string {
+	s := fmt.Sprintf("%02d:%02d:%02d", t.Hour, t.Minute, t.Second)
+	if t.Nanosecond != 0 {
+		frac := strconv.Itoa(1000000000 + t.Nanosecond)[1:]
+		s += "." + strings.TrimRight(frac, "0")
+	}
+	return s
+}
+
+func (t TOMLLocalTime) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (dt TOMLLocalDateTime) String() string {
+	return dt.Date.String() + "T" + dt.Time.String()
+}
+
+func (dt TOMLLocalDateTime) MarshalText() ([]byte, error) {
+	return []byte(dt.String()), nil
+}
+
+// In returns the time.Time for 'dt' in the location 'loc'.
+func (dt TOMLLocalDateTime) In(loc *time.Location) time.Time {
+	return time.Date(dt.Date.Year, dt.Date.Month, dt.Date.Day, dt.Time.Hour, dt.Time.Minute, dt.Time.Second, dt.Time.Nanosecond, loc)
+}
@@ -0,0 +1,910 @@
+package ordmap
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// tomlTableKind tells how a table was created, which determines how it can be extended.
+type tomlTableKind int
+
+const (
+	// tomlImplicit tables are created as parents of a [table] header: they can be defined later by their own header.
+	tomlImplicit tomlTableKind = iota
+	// tomlExplicit tables are defined by a [table] or [[array]] header.
+	tomlExplicit
+	// tomlDotted tables are created by dotted keys: they can be extended by dotted keys of the same section,
+	// and hold sub-tables defined by headers.
+	tomlDotted
+	// tomlInline tables are inline tables, which can not be extended.
+	tomlInline
+)
+
+// tomlArrayRef designates an array of tables: the entry 'key' of the table 'table'.
+type tomlArrayRef struct {
+	table *Map[string, any]
+	key   string
+}
+
+type tomlParser struct {
+	src  string
+	pos  int
+	line int
+
+	kinds  map[*Map[string, any]]tomlTableKind
+	arrays map[tomlArrayRef]bool
+}
+
+func parseTOML(data []byte) (*Map[string, any], error) {
+	if !utf8.Valid(data) {
+		return nil, errors.New("error when decoding toml: document is not valid UTF-8")
+	}
+	p := &tomlParser{
+		src:    string(data),
+		line:   1,
+		kinds:  make(map[*Map[string, any]]tomlTableKind),
+		arrays: make(map[tomlArrayRef]bool),
+	}
+	root, err := p.parse()
+	if err != nil {
+		return nil, fmt.Errorf("error when decoding toml at line %d: %w", p.line, err)
+	}
+	return root, nil
+}
+
+func (p *tomlParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *tomlParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *tomlParser) hasPrefix(s string) bool {
+	return strings.HasPrefix(p.src[p.pos:], s)
+}
+
+func (p *tomlParser) parse() (*Map[string, any], error) {
+	root := &Map[string, any]{}
+	p.kinds[root] = tomlExplicit
+	current := root
+
+	for {
+		err := p.skipBlank(true)
+		if err != nil {
+			return nil, err
+		}
+		if p.eof() {
+			return root, nil
+		}
+
+		if p.peek() == '[' {
+			if p.hasPrefix("[[") {
+				current, err = p.parseArrayTableHeader(root)
+			} else {
+				current, err = p.parseTableHeader(root)
+			}
+		} else {
+			err = p.parseKeyValue(current)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		err = p.expectLineEnd()
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// skipSpaces
+//
+// skips spaces and tabs.
+func (p *tomlParser) skipSpaces() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+// skipComment
+//
+// skips a comment, up to the end of the line.
+func (p *tomlParser) skipComment() error {
+	if p.peek() != '#' {
+		return nil
+	}
+	for !p.eof() && p.peek() != '\n' {
+		c := p.peek()
+		if c == '\r' && p.hasPrefix("\r\n") {
+			return nil
+		}
+		if isTOMLControl(c) && c != '\t' {
+			return fmt.Errorf("invalid control character %q in comment", c)
+		}
+		p.pos++
+	}
+	return nil
+}
+
+// skipNewline
+//
+// skips a newline, and returns false if there is no newline at the current position.
+func (p *tomlParser) skipNewline() bool {
+	switch {
+	case p.hasPrefix("\n"):
+		p.pos++
+	case p.hasPrefix("\r\n"):
+		p.pos += 2
+	default:
+		return false
+	}
+	p.line++
+	return true
+}
+
+// skipBlank
+//
+// skips spaces, comments and, if 'newlines' is true, newlines.
+func (p *tomlParser) skipBlank(newlines bool) error {
+	for {
+		p.skipSpaces()
+		err := p.skipComment()
+		if err != nil {
+			return err
+		}
+		if !newlines || !p.skipNewline() {
+			return nil
+		}
+	}
+}
+
+func (p *tomlParser) expectLineEnd() error {
+	err := p.skipBlank(false)
+	if err != nil {
+		return err
+	}
+	if p.eof() || p.skipNewline() {
+		return nil
+	}
+	return fmt.Errorf("expected a newline, got %q", p.peek())
+}
+
+func isTOMLControl(c byte) bool {
+	return c < 0x20 || c == 0x7f
+}
+
+func isTOMLBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// parseKey
+//
+// parses a simple or dotted key.
+func (p *tomlParser) parseKey() ([]string, error) {
+	var keys []string
+	for {
+		p.skipSpaces()
+		key, err := p.parseSimpleKey()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+
+		p.skipSpaces()
+		if p.peek() != '.' {
+			return keys, nil
+		}
+		p.pos++
+	}
+}
+
+func (p *tomlParser) parseSimpleKey() (string, error) {
+	switch p.peek() {
+	case '"':
+		if p.hasPrefix(`"""`) {
+			return "", errors.New("multi-line strings can not be used as keys")
+		}
+		return p.parseBasicString()
+	case '\'':
+		if p.hasPrefix("'''") {
+			return "", errors.New("multi-line strings can not be used as keys")
+		}
+		return p.parseLiteralString()
+	}
+
+	start := p.pos
+	for !p.eof() && isTOMLBareKeyChar(p.peek()) {
+		p.pos++
+	}
+	if p.pos == start {
+		if p.eof() {
+			return "", errors.New("expected a key, got end of document")
+		}
+		return "", fmt.Errorf("expected a key, got %q", p.peek())
+	}
+	return p.src[start:p.pos], nil
+}
+
+func (p *tomlParser) parseTableHeader(root *Map[string, any]) (*Map[string, any], error) {
+	p.pos++
+	keys, err := p.parseKey()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != ']' {
+		return nil, fmt.Errorf("expected ']' at the end of table header, got %q", p.peek())
+	}
+	p.pos++
+
+	t := root
+	for i, key := range keys {
+		last := i == len(keys)-1
+		v, ok := t.Get2(key)
+		if !ok {
+			child := &Map[string, any]{}
+			p.kinds[child] = tomlImplicit
+			if last {
+				p.kinds[child] = tomlExplicit
+			}
+			t.Set(key, child)
+			t = child
+			continue
+		}
+
+		switch x := v.(type) {
+		case *Map[string, any]:
+			kind := p.kinds[x]
+			if last {
+				if kind != tomlImplicit {
+					return nil, fmt.Errorf("table %s is already defined", formatTOMLKey(keys))
+				}
+				p.kinds[x] = tomlExplicit
+			} else if kind == tomlInline {
+				return nil, fmt.Errorf("cannot extend inline table %s", formatTOMLKey(keys[:i+1]))
+			}
+			t = x
+
+		case []any:
+			if last || !p.arrays[tomlArrayRef{table: t, key: key}] {
+				return nil, fmt.Errorf("key %s is already defined as an array", formatTOMLKey(keys[:i+1]))
+			}
+			t = x[len(x)-1].(*Map[string, any])
+
+		default:
+			return nil, fmt.Errorf("key %s is already defined", formatTOMLKey(keys[:i+1]))
+		}
+	}
+	return t, nil
+}
+
+func (p *tomlParser) parseArrayTableHeader(root *Map[string, any]) (*Map[string, any], error) {
+	p.pos += 2
+	keys, err := p.parseKey()
+	if err != nil {
+		return nil, err
+	}
+	if !p.hasPrefix("]]") {
+		return nil, fmt.Errorf("expected ']]' at the end of array of tables header, got %q", p.peek())
+	}
+	p.pos += 2
+
+	parent := root
+	if len(keys) > 1 {
+		// the parent follows the same rules as the table of a [table] header,
+		// but is not defined by this header:
+		parent, err = p.lookupHeaderParent(root, keys[:len(keys)-1])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	key := keys[len(keys)-1]
+	ref := tomlArrayRef{table: parent, key: key}
+	elt := &Map[string, any]{}
+	p.kinds[elt] = tomlExplicit
+
+	v, ok := parent.Get2(key)
+	switch {
+	case !ok:
+		parent.Set(key, []any{elt})
+		p.arrays[ref] = true
+	case p.arrays[ref]:
+		parent.Set(key, append(v.([]any), elt))
+	default:
+		return nil, fmt.Errorf("key %s is already defined", formatTOMLKey(keys))
+	}
+	return elt, nil
+}
+
+// lookupHeaderParent
+//
+// returns the table designated by 'keys', creating implicit tables if needed.
+func (p *tomlParser) lookupHeaderParent(root *Map[string, any], keys []string) (*Map[string, any], error) {
+	t := root
+	for i, key := range keys {
+		v, ok := t.Get2(key)
+		if !ok {
+			child := &Map[string, any]{}
+			p.kinds[child] = tomlImplicit
+			t.Set(key, child)
+			t = child
+			continue
+		}
+
+		switch x := v.(type) {
+		case *Map[string, any]:
+			if p.kinds[x] == tomlInline {
+				return nil, fmt.Errorf("cannot extend inline table %s", formatTOMLKey(keys[:i+1]))
+			}
+			t = x
+		case []any:
+			if !p.arrays[tomlArrayRef{table: t, key: key}] {
+				return nil, fmt.Errorf("key %s is already defined as an array", formatTOMLKey(keys[:i+1]))
+			}
+			t = x[len(x)-1].(*Map[string, any])
+		default:
+			return nil, fmt.Errorf("key %s is already defined", formatTOMLKey(keys[:i+1]))
+		}
+	}
+	return t, nil
+}
+
+// parseKeyValue
+//
+// parses a "key = value" pair, and stores it in 't'.
+func (p *tomlParser) parseKeyValue(t *Map[string, any]) error {
+	keys, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	if p.peek() != '=' {
+		return fmt.Errorf("expected '=' after key %s, got %q", formatTOMLKey(keys), p.peek())
+	}
+	p.pos++
+	p.skipSpaces()
+
+	value, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+
+	for i, key := range keys[:len(keys)-1] {
+		v, ok := t.Get2(key)
+		if !ok {
+			child := &Map[string, any]{}
+			p.kinds[child] = tomlDotted
+			t.Set(key, child)
+			t = child
+			continue
+		}
+		child, isTable := v.(*Map[string, any])
+		if !isTable || p.kinds[child] != tomlDotted {
+			return fmt.Errorf("cannot define key %s: %s is already defined", formatTOMLKey(keys), formatTOMLKey(keys[:i+1]))
+		}
+		t = child
+	}
+
+	key := keys[len(keys)-1]
+	if _, ok := t.Get2(key); ok {
+		return fmt.Errorf("key %s is already defined", formatTOMLKey(keys))
+	}
+	t.Set(key, value)
+	return nil
+}
+
+func (p *tomlParser) parseValue() (any, error) {
+	if p.eof() {
+		return nil, errors.New("expected a value, got end of document")
+	}
+
+	switch c := p.peek(); {
+	case c == '"':
+		if p.hasPrefix(`"""`) {
+			return p.parseMultilineString('"')
+		}
+		return p.parseBasicString()
+	case c == '\'':
+		if p.hasPrefix("'''") {
+			return p.parseMultilineString('\'')
+		}
+		return p.parseLiteralString()
+	case c == '[':
+		return p.parseArray()
+	case c == '{':
+		return p.parseInlineTable()
+	case c == 't' || c == 'f':
+		tok := p.token()
+		switch tok {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		return nil, fmt.Errorf("invalid value %q", tok)
+	case c >= '0' && c <= '9' && p.isDateTime():
+		return p.parseDateTime()
+	}
+
+	return p.parseNumber()
+}
+
+// token
+//
+// reads the characters up to the next delimiter.
+func (p *tomlParser) token() string {
+	start := p.pos
+	for !p.eof() {
+		switch p.peek() {
+		case ' ', '\t', '\r', '\n', ',', ']', '}', '#':
+			return p.src[start:p.pos]
+		}
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *tomlParser) parseBasicString() (string, error) {
+	p.pos++
+	var sb strings.Builder
+	for {
+		if p.eof() || p.peek() == '\n' || p.peek() == '\r' {
+			return "", errors.New("unterminated string")
+		}
+		c := p.peek()
+		switch {
+		case c == '"':
+			p.pos++
+			return sb.String(), nil
+		case c == '\\':
+			err := p.parseEscape(&sb)
+			if err != nil {
+				return "", err
+			}
+		case isTOMLControl(c) && c != '\t':
+			return "", fmt.Errorf("invalid control character %q in string", c)
+		default:
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+func (p *tomlParser) parseEscape(sb *strings.Builder) error {
+	p.pos++
+	if p.eof() {
+		return errors.New("unterminated string")
+	}
+	c := p.peek()
+	p.pos++
+	switch c {
+	case 'b':
+		sb.WriteByte('\b')
+	case 't':
+		sb.WriteByte('\t')
+	case 'n':
+		sb.WriteByte('\n')
+	case 'f':
+		sb.WriteByte('\f')
+	case 'r':
+		sb.WriteByte('\r')
+	case '"':
+		sb.WriteByte('"')
+	case '\\':
+		sb.WriteByte('\\')
+	case 'u', 'U':
+		n := 4
+		if c == 'U' {
+			n = 8
+		}
+		if p.pos+n > len(p.src) {
+			return errors.New("invalid unicode escape")
+		}
+		code, err := strconv.ParseUint(p.src[p.pos:p.pos+n], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return fmt.Errorf("invalid unicode escape \\%c%s", c, p.src[p.pos:p.pos+n])
+		}
+		sb.WriteRune(rune(code))
+		p.pos += n
+	default:
+		return fmt.Errorf("invalid escape sequence \\%c", c)
+	}
+	return nil
+}
+
+func (p *tomlParser) parseLiteralString() (string, error) {
+	p.pos++
+	start := p.pos
+	for {
+		if p.eof() || p.peek() == '\n' || p.peek() == '\r' {
+			return "", errors.New("unterminated string")
+		}
+		c := p.peek()
+		if c == '\'' {
+			s := p.src[start:p.pos]
+			p.pos++
+			return s, nil
+		}
+		if isTOMLControl(c) && c != '\t' {
+			return "", fmt.Errorf("invalid control character %q in string", c)
+		}
+		p.pos++
+	}
+}
+
+// parseMultilineString
+//
+// parses a multi-line basic or literal string, 'quote' is the character of its delimiter.
+func (p *tomlParser) parseMultilineString(quote byte) (string, error) {
+	p.pos += 3
+	// a newline immediately following the opening delimiter is trimmed:
+	p.skipNewline()
+
+	var sb strings.Builder
+	for {
+		if p.eof() {
+			return "", errors.New("unterminated multi-line string")
+		}
+		c := p.peek()
+		switch {
+		case c == quote:
+			n := 0
+			for p.pos+n < len(p.src) && p.src[p.pos+n] == quote && n < 6 {
+				n++
+			}
+			if n < 3 {
+				sb.WriteString(p.src[p.pos : p.pos+n])
+				p.pos += n
+				continue
+			}
+			if n > 5 {
+				return "", errors.New("too many quotes at the end of a multi-line string")
+			}
+			// up to two quotes can precede the closing delimiter:
+			sb.WriteString(p.src[p.pos : p.pos+n-3])
+			p.pos += n
+			return sb.String(), nil
+
+		case c == '\\' && quote == '"':
+			if p.skipLineEndingBackslash() {
+				continue
+			}
+			err := p.parseEscape(&sb)
+			if err != nil {
+				return "", err
+			}
+
+		case c == '\n' || c == '\r':
+			start := p.pos
+			if !p.skipNewline() {
+				return "", fmt.Errorf("invalid control character %q in string", c)
+			}
+			sb.WriteString(p.src[start:p.pos])
+
+		case isTOMLControl(c) && c != '\t':
+			return "", fmt.Errorf("invalid control character %q in string", c)
+
+		default:
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+// skipLineEndingBackslash
+//
+// skips a backslash at the end of a line in a multi-line basic string, along with all the
+// whitespace and newlines which follow it.
+func (p *tomlParser) skipLineEndingBackslash() bool {
+	i := p.pos + 1
+	for i < len(p.src) && (p.src[i] == ' ' || p.src[i] == '\t') {
+		i++
+	}
+	if i >= len(p.src) || (p.src[i] != '\n' && !strings.HasPrefix(p.src[i:], "\r\n")) {
+		return false
+	}
+
+	p.pos = i
+	for {
+		p.skipSpaces()
+		if !p.skipNewline() {
+			return true
+		}
+	}
+}
+
+func (p *tomlParser) parseArray() ([]any, error) {
+	p.pos++
+	res := []any{}
+	for {
+		err := p.skipBlank(true)
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() == ']' {
+			p.pos++
+			return res, nil
+		}
+
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, v)
+
+		err = p.skipBlank(true)
+		if err != nil {
+			return nil, err
+		}
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+			p.pos++
+			return res, nil
+		default:
+			if p.eof() {
+				return nil, errors.New("unterminated array")
+			}
+			return nil, fmt.Errorf("expected ',' or ']' in array, got %q", p.peek())
+		}
+	}
+}
+
+func (p *tomlParser) parseInlineTable() (*Map[string, any], error) {
+	p.pos++
+	res := &Map[string, any]{}
+	p.kinds[res] = tomlDotted
+
+	p.skipSpaces()
+	if p.peek() == '}' {
+		p.pos++
+		p.freeze(res)
+		return res, nil
+	}
+
+	for {
+		err := p.parseKeyValue(res)
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		switch p.peek() {
+		case ',':
+			p.pos++
+			p.skipSpaces()
+			if p.peek() == '}' {
+				return nil, errors.New("trailing comma in inline table")
+			}
+		case '}':
+			p.pos++
+			p.freeze(res)
+			return res, nil
+		default:
+			if p.eof() {
+				return nil, errors.New("unterminated inline table")
+			}
+			return nil, fmt.Errorf("expected ',' or '}' in inline table, got %q", p.peek())
+		}
+	}
+}
+
+// freeze
+//
+// marks the inline table 't' and the tables defined in it with dotted keys as inline tables,
+// so that they can not be extended.
+func (p *tomlParser) freeze(t *Map[string, any]) {
+	p.kinds[t] = tomlInline
+	for _, v := range t.m {
+		if child, ok := v.(*Map[string, any]); ok && p.kinds[child] == tomlDotted {
+			p.freeze(child)
+		}
+	}
+}
+
+var (
+	tomlDecInt = regexp.MustCompile(`^[+-]?(0|[1-9](_?[0-9])*)$`)
+	tomlHexInt = regexp.MustCompile(`^0x[0-9A-Fa-f](_?[0-9A-Fa-f])*$`)
+	tomlOctInt = regexp.MustCompile(`^0o[0-7](_?[0-7])*$`)
+	tomlBinInt = regexp.MustCompile(`^0b[01](_?[01])*$`)
+	tomlFloat  = regexp.MustCompile(`^[+-]?(0|[1-9](_?[0-9])*)(\.[0-9](_?[0-9])*)?([eE][+-]?[0-9](_?[0-9])*)?$`)
+)
+
+func (p *tomlParser) parseNumber() (any, error) {
+	tok := p.token()
+	digits := strings.ReplaceAll(tok, "_", "")
+
+	var base int
+	switch {
+	case tomlDecInt.MatchString(tok):
+		base = 10
+	case tomlHexInt.MatchString(tok):
+		base, digits = 16, digits[2:]
+	case tomlOctInt.MatchString(tok):
+		base, digits = 8, digits[2:]
+	case tomlBinInt.MatchString(tok):
+		base, digits = 2, digits[2:]
+	}
+	if base != 0 {
+		i, err := strconv.ParseInt(digits, base, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %q: %w", tok, err)
+		}
+		return i, nil
+	}
+
+	switch strings.TrimLeft(tok, "+-") {
+	case "inf":
+		if tok[0] == '-' {
+			return math.Inf(-1), nil
+		}
+		return math.Inf(1), nil
+	case "nan":
+		return math.NaN(), nil
+	}
+
+	if tomlFloat.MatchString(tok) {
+		f, err := strconv.ParseFloat(digits, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid float %q: %w", tok, err)
+		}
+		return f, nil
+	}
+
+	if tok == "" {
+		if p.eof() {
+			return nil, errors.New("expected a value, got end of document")
+		}
+		return nil, fmt.Errorf("expected a value, got %q", p.peek())
+	}
+	return nil, fmt.Errorf("invalid value %q", tok)
+}
+
+// isDateTime
+//
+// returns true if the current position holds a date ("1979-05-27") or a time ("07:32:00").
+func (p *tomlParser) isDateTime() bool {
+	s := p.src[p.pos:]
+	isDigits := func(s string) bool {
+		for i := 0; i < len(s); i++ {
+			if s[i] < '0' || s[i] > '9' {
+				return false
+			}
+		}
+		return true
+	}
+	return len(s) >= 5 && isDigits(s[:4]) && s[4] == '-' ||
+		len(s) >= 3 && isDigits(s[:2]) && s[2] == ':'
+}
+
+func (p *tomlParser) parseDateTime() (any, error) {
+	if p.src[p.pos+2] == ':' {
+		return p.parseLocalTime()
+	}
+
+	date, err := p.parseLocalDate()
+	if err != nil {
+		return nil, err
+	}
+
+	// the time part is separated by 'T', 't' or a space:
+	s := p.src[p.pos:]
+	if len(s) < 3 || (s[0] != 'T' && s[0] != 't' && s[0] != ' ') || s[1] < '0' || s[1] > '9' {
+		return date, nil
+	}
+	if s[0] == ' ' && (len(s) < 4 || s[3] != ':') {
+		return date, nil
+	}
+	p.pos++
+
+	tm, err := p.parseLocalTime()
+	if err != nil {
+		return nil, err
+	}
+	local := TOMLLocalDateTime{Date: date, Time: tm}
+
+	switch c := p.peek(); c {
+	case 'Z', 'z':
+		p.pos++
+		return local.In(time.UTC), nil
+	case '+', '-':
+		s := p.src[p.pos:]
+		if len(s) < 6 || s[3] != ':' {
+			return nil, fmt.Errorf("invalid time offset %q", p.token())
+		}
+		h, err1 := strconv.Atoi(s[1:3])
+		m, err2 := strconv.Atoi(s[4:6])
+		if err1 != nil || err2 != nil || h > 23 || m > 59 {
+			return nil, fmt.Errorf("invalid time offset %q", s[:6])
+		}
+		offset := h*3600 + m*60
+		if c == '-' {
+			offset = -offset
+		}
+		p.pos += 6
+		return local.In(time.FixedZone("", offset)), nil
+	}
+	return local, nil
+}
+
+func (p *tomlParser) parseLocalDate() (TOMLLocalDate, error) {
+	s := p.src[p.pos:]
+	if len(s) < 10 || s[4] != '-' || s[7] != '-' {
+		return TOMLLocalDate{}, fmt.Errorf("invalid date %q", p.token())
+	}
+	y, err1 := strconv.Atoi(s[:4])
+	m, err2 := strconv.Atoi(s[5:7])
+	d, err3 := strconv.Atoi(s[8:10])
+	if err1 != nil || err2 != nil || err3 != nil || m < 1 || m > 12 || d < 1 {
+		return TOMLLocalDate{}, fmt.Errorf("invalid date %q", s[:10])
+	}
+	// time.Date normalizes out of range days, so that the 30th of February becomes a date in March:
+	if time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC).Day() != d {
+		return TOMLLocalDate{}, fmt.Errorf("invalid date %q", s[:10])
+	}
+	p.pos += 10
+	return TOMLLocalDate{Year: y, Month: time.Month(m), Day: d}, nil
+}
+
+func (p *tomlParser) parseLocalTime() (TOMLLocalTime, error) {
+	s := p.src[p.pos:]
+	if len(s) < 8 || s[2] != ':' || s[5] != ':' {
+		return TOMLLocalTime{}, fmt.Errorf("invalid time %q", p.token())
+	}
+	h, err1 := strconv.Atoi(s[:2])
+	m, err2 := strconv.Atoi(s[3:5])
+	sec, err3 := strconv.Atoi(s[6:8])
+	if err1 != nil || err2 != nil || err3 != nil || h > 23 || m > 59 || sec > 59 {
+		return TOMLLocalTime{}, fmt.Errorf("invalid time %q", s[:8])
+	}
+	p.pos += 8
+
+	nsec := 0
+	if p.peek() == '.' {
+		p.pos++
+		start := p.pos
+		for !p.eof() && p.peek() >= '0' && p.peek() <= '9' {
+			p.pos++
+		}
+		frac := p.src[start:p.pos]
+		if frac == "" {
+			return TOMLLocalTime{}, errors.New("invalid time: missing fractional seconds")
+		}
+		// precision beyond nanoseconds is truncated:
+		frac = (frac + "000000000")[:9]
+		nsec, _ = strconv.Atoi(frac)
+	}
+	return TOMLLocalTime{Hour: h, Minute: m, Second: sec, Nanosecond: nsec}, nil
+}
+
+// formatTOMLKey
+//
+// formats a dotted key, quoting the parts which are not bare keys.
+func formatTOMLKey(keys []string) string {
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = formatTOMLSimpleKey(k)
+	}
+	return strings.Join(parts, ".")
+}
+
+func formatTOMLSimpleKey(k string) string {
+	if k == "" {
+		return `""`
+	}
+	for i := 0; i < len(k); i++ {
+		if !isTOMLBareKeyChar(k[i]) {
+			return quoteTOMLString(k)
+		}
+	}
+	return k
+}
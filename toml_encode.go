@@ -0,0 +1,415 @@
+package ordmap
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type tomlEncoder struct {
+	buf bytes.Buffer
+}
+
+// tomlEntryKind tells how an entry of a table is written.
+type tomlEntryKind int
+
+const (
+	tomlValueEntry tomlEntryKind = iota
+	tomlTableEntry
+	tomlArrayTableEntry
+)
+
+type tomlEntry struct {
+	key   string
+	value any
+	kind  tomlEntryKind
+}
+
+func (e *tomlEncoder) encodeDocument(v any) error {
+	root, err := resolveTOMLValue("", v)
+	if err != nil {
+		return err
+	}
+	m, ok := root.(*Map[string, any])
+	if !ok {
+		return fmt.Errorf("error when encoding toml: a document must be a table, got %T", root)
+	}
+	return e.writeTableBody(nil, m)
+}
+
+// resolveTOMLValue
+//
+// converts 'v' into one of the values handled by the encoder: `*Map[string, any]`, `[]any`,
+// or a scalar of a TOML type.
+func resolveTOMLValue(where string, v any) (any, error) {
+	for {
+		switch x := v.(type) {
+		case nil:
+			return nil, fmt.Errorf("error when encoding toml %s: null values can not be represented in TOML", where)
+
+		case Any:
+			v = x.v
+			continue
+		case *Any:
+			if x == nil {
+				v = nil
+				continue
+			}
+			v = x.v
+			continue
+		case *YAMLAnchor:
+			v = x.Value
+			continue
+		case *YAMLScalar:
+			v = x.Value
+			continue
+
+		case *Map[string, any]:
+			if x == nil {
+				v = nil
+				continue
+			}
+			return x, nil
+		case TOMLMarshaler:
+			if rv := reflect.ValueOf(x); rv.Kind() == reflect.Pointer && rv.IsNil() {
+				v = nil
+				continue
+			}
+			res, err := x.MarshalTOML()
+			if err != nil {
+				return nil, fmt.Errorf("error when encoding toml %s: %w", where, err)
+			}
+			if reflect.TypeOf(res) == reflect.TypeOf(v) {
+				return nil, fmt.Errorf("error when encoding toml %s: MarshalTOML of %T returned the same type", where, v)
+			}
+			v = res
+			continue
+
+		case []any, string, bool, int64, float64, time.Time, TOMLLocalDate, TOMLLocalTime, TOMLLocalDateTime:
+			return x, nil
+		case int:
+			return int64(x), nil
+		case int8:
+			return int64(x), nil
+		case int16:
+			return int64(x), nil
+		case int32:
+			return int64(x), nil
+		case uint:
+			return resolveTOMLUint(where, uint64(x))
+		case uint8:
+			return int64(x), nil
+		case uint16:
+			return int64(x), nil
+		case uint32:
+			return int64(x), nil
+		case uint64:
+			return resolveTOMLUint(where, x)
+		case float32:
+			return float64(x), nil
+		case json.Number:
+			if i, err := x.Int64(); err == nil {
+				return i, nil
+			}
+			f, err := x.Float64()
+			if err != nil {
+				return nil, fmt.Errorf("error when encoding toml %s: %w", where, err)
+			}
+			return f, nil
+		case []byte:
+			return string(x), nil
+		case encoding.TextMarshaler:
+			bs, err := x.MarshalText()
+			if err != nil {
+				return nil, fmt.Errorf("error when encoding toml %s: %w", where, err)
+			}
+			return string(bs), nil
+		}
+
+		// other go values: named scalar types, structs, go maps and slices
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.String:
+			return rv.String(), nil
+		case reflect.Bool:
+			return rv.Bool(), nil
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return rv.Int(), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return resolveTOMLUint(where, rv.Uint())
+		case reflect.Float32, reflect.Float64:
+			return rv.Float(), nil
+		}
+
+		res, err := toAnyValue(where, rv)
+		if err != nil {
+			return nil, err
+		}
+		if reflect.TypeOf(res) == reflect.TypeOf(v) {
+			return nil, fmt.Errorf("error when encoding toml %s: unsupported type %T", where, v)
+		}
+		v = res
+	}
+}
+
+func resolveTOMLUint(where string, u uint64) (any, error) {
+	if u > math.MaxInt64 {
+		return nil, fmt.Errorf("error when encoding toml %s: %d overflows a TOML integer", where, u)
+	}
+	return int64(u), nil
+}
+
+// tableEntries
+//
+// resolves the values of 'm', and returns its entries along with the index of the last
+// entry which is neither a table nor an array of tables (-1 if there is none).
+func tableEntries(path []string, m *Map[string, any]) ([]tomlEntry, int, error) {
+	entries := make([]tomlEntry, 0, m.Len())
+	lastValue := -1
+	for _, k := range m.keys {
+		v, err := resolveTOMLValue(formatTOMLKey(append(path[:len(path):len(path)], k)), m.m[k])
+		if err != nil {
+			return nil, 0, err
+		}
+
+		kind := tomlValueEntry
+		switch x := v.(type) {
+		case *Map[string, any]:
+			kind = tomlTableEntry
+		case []any:
+			if isTOMLArrayOfTables(x) {
+				kind = tomlArrayTableEntry
+			}
+		}
+		if kind == tomlValueEntry {
+			lastValue = len(entries)
+		}
+		entries = append(entries, tomlEntry{key: k, value: v, kind: kind})
+	}
+	return entries, lastValue, nil
+}
+
+func isTOMLArrayOfTables(a []any) bool {
+	if len(a) == 0 {
+		return false
+	}
+	for _, elt := range a {
+		v, err := resolveTOMLValue("", elt)
+		if err != nil {
+			return false
+		}
+		if _, ok := v.(*Map[string, any]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// writeTableBody
+//
+// writes the entries of the table 'm' located at 'path', after its header.
+func (e *tomlEncoder) writeTableBody(path []string, m *Map[string, any]) error {
+	entries, lastValue, err := tableEntries(path, m)
+	if err != nil {
+		return err
+	}
+
+	// key/value pairs, and the tables which precede them as dotted keys or inline values:
+	for _, entry := range entries[:lastValue+1] {
+		err := e.writeDottedEntry(nil, entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	// sub-tables as sections:
+	for _, entry := range entries[lastValue+1:] {
+		entryPath := append(path[:len(path):len(path)], entry.key)
+
+		switch entry.kind {
+		case tomlTableEntry:
+			t := entry.value.(*Map[string, any])
+			_, childLastValue, err := tableEntries(entryPath, t)
+			if err != nil {
+				return err
+			}
+			// a table which only holds sub-tables is created implicitly by their headers:
+			if childLastValue >= 0 || t.Len() == 0 {
+				e.writeHeader("[", entryPath, "]")
+			}
+			err = e.writeTableBody(entryPath, t)
+			if err != nil {
+				return err
+			}
+
+		case tomlArrayTableEntry:
+			for _, elt := range entry.value.([]any) {
+				t, _ := resolveTOMLValue("", elt)
+				e.writeHeader("[[", entryPath, "]]")
+				err := e.writeTableBody(entryPath, t.(*Map[string, any]))
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (e *tomlEncoder) writeHeader(open string, path []string, close string) {
+	if e.buf.Len() > 0 {
+		e.buf.WriteByte('\n')
+	}
+	e.buf.WriteString(open)
+	e.buf.WriteString(formatTOMLKey(path))
+	e.buf.WriteString(close)
+	e.buf.WriteByte('\n')
+}
+
+// writeDottedEntry
+//
+// writes 'entry' as "key = value" lines, where tables are flattened into dotted keys.
+func (e *tomlEncoder) writeDottedEntry(prefix []string, entry tomlEntry) error {
+	keys := append(prefix[:len(prefix):len(prefix)], entry.key)
+
+	if t, ok := entry.value.(*Map[string, any]); ok && t.Len() > 0 {
+		entries, _, err := tableEntries(keys, t)
+		if err != nil {
+			return err
+		}
+		for _, child := range entries {
+			err := e.writeDottedEntry(keys, child)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	e.buf.WriteString(formatTOMLKey(keys))
+	e.buf.WriteString(" = ")
+	err := e.writeInline(formatTOMLKey(keys), entry.value)
+	if err != nil {
+		return err
+	}
+	e.buf.WriteByte('\n')
+	return nil
+}
+
+func (e *tomlEncoder) writeInline(where string, v any) error {
+	v, err := resolveTOMLValue(where, v)
+	if err != nil {
+		return err
+	}
+
+	switch x := v.(type) {
+	case *Map[string, any]:
+		if x.Len() == 0 {
+			e.buf.WriteString("{}")
+			return nil
+		}
+		e.buf.WriteString("{ ")
+		for i, k := range x.keys {
+			if i > 0 {
+				e.buf.WriteString(", ")
+			}
+			e.buf.WriteString(formatTOMLSimpleKey(k))
+			e.buf.WriteString(" = ")
+			err := e.writeInline(where+"."+formatTOMLSimpleKey(k), x.m[k])
+			if err != nil {
+				return err
+			}
+		}
+		e.buf.WriteString(" }")
+
+	case []any:
+		e.buf.WriteByte('[')
+		for i, elt := range x {
+			if i > 0 {
+				e.buf.WriteString(", ")
+			}
+			err := e.writeInline(fmt.Sprintf("%s[%d]", where, i), elt)
+			if err != nil {
+				return err
+			}
+		}
+		e.buf.WriteByte(']')
+
+	case string:
+		e.buf.WriteString(quoteTOMLString(x))
+	case bool:
+		e.buf.WriteString(strconv.FormatBool(x))
+	case int64:
+		e.buf.WriteString(strconv.FormatInt(x, 10))
+	case float64:
+		e.buf.WriteString(formatTOMLFloat(x))
+	case time.Time:
+		e.buf.WriteString(x.Format(time.RFC3339Nano))
+	case TOMLLocalDate:
+		e.buf.WriteString(x.String())
+	case TOMLLocalTime:
+		e.buf.WriteString(x.String())
+	case TOMLLocalDateTime:
+		e.buf.WriteString(x.String())
+
+	default:
+		return fmt.Errorf("error when encoding toml %s: unexpected value of type %T", where, v)
+	}
+	return nil
+}
+
+func formatTOMLFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "nan"
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eE") {
+		s += ".0"
+	}
+	return s
+}
+
+// quoteTOMLString
+//
+// formats 's' as a TOML basic string.
+func quoteTOMLString(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\f':
+			sb.WriteString(`\f`)
+		case '\r':
+			sb.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&sb, `\u%04X`, r)
+				continue
+			}
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
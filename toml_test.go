@@ -0,0 +1,363 @@
+package ordmap
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTOMLDocument = `# service configuration
+title = "TOML \"example\""
+version = 2
+site."google.com" = true
+
+[owner]
+name = 'Tom Preston-Werner'
+dob = 1979-05-27T07:32:00-08:00
+
+[database]
+ports = [ 8000, 8001, 8002 ]
+data = [ ["delta", "phi"], [3.14] ]
+temp_targets = { cpu = 79.5, case = 72.0 }
+enabled = true
+
+[servers.beta]
+ip = "10.0.0.2"
+
+[servers.alpha]
+ip = "10.0.0.1"
+role.name = "frontend"
+role.level = 1
+
+[[products]]
+name = "Hammer"
+sku = 738594937
+
+[[products]]
+
+[[products]]
+name = "Nail"
+color = "gray"
+`
+
+func TestUnmarshalTOML(t *testing.T) {
+	var x Any
+	err := UnmarshalTOML([]byte(testTOMLDocument), &x)
+	require.NoError(t, err)
+
+	root := x.V().(*Map[string, any])
+	assert.Equal(t, []string{"title", "version", "site", "owner", "database", "servers", "products"}, root.Keys())
+	assert.Equal(t, `TOML "example"`, root.Get("title"))
+	assert.Equal(t, int64(2), root.Get("version"))
+	assert.Equal(t, true, root.Get("site").(*Map[string, any]).Get("google.com"))
+
+	owner := root.Get("owner").(*Map[string, any])
+	dob := owner.Get("dob").(time.Time)
+	assert.True(t, dob.Equal(time.Date(1979, 5, 27, 15, 32, 0, 0, time.UTC)))
+
+	db := root.Get("database").(*Map[string, any])
+	assert.Equal(t, []string{"ports", "data", "temp_targets", "enabled"}, db.Keys())
+	assert.Equal(t, []any{int64(8000), int64(8001), int64(8002)}, db.Get("ports"))
+	assert.Equal(t, []string{"cpu", "case"}, db.Get("temp_targets").(*Map[string, any]).Keys())
+
+	servers := root.Get("servers").(*Map[string, any])
+	assert.Equal(t, []string{"beta", "alpha"}, servers.Keys())
+	alpha := servers.Get("alpha").(*Map[string, any])
+	assert.Equal(t, []string{"ip", "role"}, alpha.Keys())
+	assert.Equal(t, []string{"name", "level"}, alpha.Get("role").(*Map[string, any]).Keys())
+
+	products := root.Get("products").([]any)
+	require.Len(t, products, 3)
+	assert.Equal(t, 0, products[1].(*Map[string, any]).Len())
+	assert.Equal(t, []string{"name", "color"}, products[2].(*Map[string, any]).Keys())
+}
+
+func TestMarshalTOML_RoundTrip(t *testing.T) {
+	var x Any
+	err := UnmarshalTOML([]byte(testTOMLDocument), &x)
+	require.NoError(t, err)
+
+	out, err := MarshalTOML(x)
+	require.NoError(t, err)
+
+	expected := `title = "TOML \"example\""
+version = 2
+
+[site]
+"google.com" = true
+
+[owner]
+name = "Tom Preston-Werner"
+dob = 1979-05-27T07:32:00-08:00
+
+[database]
+ports = [8000, 8001, 8002]
+data = [["delta", "phi"], [3.14]]
+temp_targets.cpu = 79.5
+temp_targets.case = 72.0
+enabled = true
+
+[servers.beta]
+ip = "10.0.0.2"
+
+[servers.alpha]
+ip = "10.0.0.1"
+
+[servers.alpha.role]
+name = "frontend"
+level = 1
+
+[[products]]
+name = "Hammer"
+sku = 738594937
+
+[[products]]
+
+[[products]]
+name = "Nail"
+color = "gray"
+`
+	assert.Equal(t, expected, string(out))
+
+	// decoding the output gives back the same document:
+	var back Any
+	err = UnmarshalTOML(out, &back)
+	require.NoError(t, err)
+	assert.Equal(t, jsonMarshalString(t, x), jsonMarshalString(t, back))
+}
+
+func TestMarshalTOML_Order(t *testing.T) {
+	// tables which come before key/value pairs are written with dotted keys:
+	x := mustAny(t, `{"server":{"host":"h","tls":{"cert":"c"},"port":80},"name":"n","empty":{},"list":[{"a":1},{"b":[]}],"tail":true,"last":{"k":"v","sub":{}}}`)
+
+	out, err := MarshalTOML(x)
+	require.NoError(t, err)
+
+	expected := `server.host = "h"
+server.tls.cert = "c"
+server.port = 80.0
+name = "n"
+empty = {}
+list = [{ a = 1.0 }, { b = [] }]
+tail = true
+
+[last]
+k = "v"
+
+[last.sub]
+`
+	assert.Equal(t, expected, string(out))
+
+	var back Any
+	require.NoError(t, UnmarshalTOML(out, &back))
+	assert.Equal(t, jsonMarshalString(t, x), jsonMarshalString(t, back))
+}
+
+func TestTOMLValues(t *testing.T) {
+	input := `int1 = +99
+int2 = -17
+int3 = 1_000
+hex = 0xDEAD_beef
+oct = 0o755
+bin = 0b1101
+flt1 = 6.626e-34
+flt2 = -0.01
+flt3 = 224_617.445_991
+inf = -inf
+nan = nan
+str1 = "tab\there \u00E9 \U0001F600"
+str2 = """
+Roses are red \
+   Violets are blue
+"quoted" ""."""
+str3 = '''
+C:\Users\'' '''
+ldt = 1979-05-27T07:32:00.999999
+ld = 1979-05-27
+lt = 00:32:00.5
+odt = 1979-05-27 07:32:00Z
+arr = [
+  1,  # comment
+  "two",
+]
+`
+	var m Map[string, any]
+	err := UnmarshalTOML([]byte(input), &m)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(99), m.Get("int1"))
+	assert.Equal(t, int64(-17), m.Get("int2"))
+	assert.Equal(t, int64(1000), m.Get("int3"))
+	assert.Equal(t, int64(0xdeadbeef), m.Get("hex"))
+	assert.Equal(t, int64(0755), m.Get("oct"))
+	assert.Equal(t, int64(13), m.Get("bin"))
+	assert.Equal(t, 6.626e-34, m.Get("flt1"))
+	assert.Equal(t, -0.01, m.Get("flt2"))
+	assert.Equal(t, 224617.445991, m.Get("flt3"))
+	assert.Equal(t, math.Inf(-1), m.Get("inf"))
+	assert.True(t, math.IsNaN(m.Get("nan").(float64)))
+	assert.Equal(t, "tab\there é 😀", m.Get("str1"))
+	assert.Equal(t, "Roses are red Violets are blue\n\"quoted\" \"\".", m.Get("str2"))
+	assert.Equal(t, "C:\\Users\\'' ", m.Get("str3"))
+	assert.Equal(t, TOMLLocalDateTime{Date: TOMLLocalDate{1979, 5, 27}, Time: TOMLLocalTime{7, 32, 0, 999999000}}, m.Get("ldt"))
+	assert.Equal(t, TOMLLocalDate{1979, 5, 27}, m.Get("ld"))
+	assert.Equal(t, TOMLLocalTime{0, 32, 0, 500000000}, m.Get("lt"))
+	assert.Equal(t, time.Date(1979, 5, 27, 7, 32, 0, 0, time.UTC), m.Get("odt"))
+	assert.Equal(t, []any{int64(1), "two"}, m.Get("arr"))
+
+	out, err := MarshalTOML(&m)
+	require.NoError(t, err)
+	expected := `int1 = 99
+int2 = -17
+int3 = 1000
+hex = 3735928559
+oct = 493
+bin = 13
+flt1 = 6.626e-34
+flt2 = -0.01
+flt3 = 224617.445991
+inf = -inf
+nan = nan
+str1 = "tab\there é 😀"
+str2 = "Roses are red Violets are blue\n\"quoted\" \"\"."
+str3 = "C:\\Users\\'' "
+ldt = 1979-05-27T07:32:00.999999
+ld = 1979-05-27
+lt = 00:32:00.5
+odt = 1979-05-27T07:32:00Z
+arr = [1, "two"]
+`
+	assert.Equal(t, expected, string(out))
+}
+
+func TestUnmarshalTOML_Errors(t *testing.T) {
+	table := []string{
+		"a = 1\na = 2",
+		"[a]\n[a]",
+		"a.b = 1\n[a]",
+		"[a]\nb.c = 1\n[a.b]",
+		"[a.b.c]\nz = 9\n[a]\nb.c.t = 1",
+		"a = {b = 1}\n[a.c]",
+		"a = {b = 1}\na.c = 2",
+		"a = [1]\n[[a]]",
+		"[[a]]\n[a]",
+		"a = {b = 1,}",
+		"a = {b = 1\n}",
+		"a = 01",
+		"a = 1__0",
+		"a = _1",
+		"a = 0x",
+		"a = 1.",
+		"a = .5",
+		"a = 1e",
+		"a = 9223372036854775808",
+		"a = \"unterminated",
+		"a = \"bad \\q escape\"",
+		"a = 'no\nnewline'",
+		"a = 1979-02-30",
+		"a = 25:00:00",
+		"a = 1 b = 2",
+		"a = tru",
+		"= 1",
+		"a",
+		"[a",
+		"a = \"\"\"\"\"\"\"\"\"",
+		"a = [1 2]",
+		"a = \"\x01\"",
+		"a = 1 # \x01",
+		"a = \"\\uD800\"",
+	}
+	for _, input := range table {
+		var x Any
+		err := UnmarshalTOML([]byte(input), &x)
+		assert.Error(t, err, "input: %q", input)
+	}
+
+	var x Any
+	err := UnmarshalTOML([]byte("a = 1\nb = \"x\n"), &x)
+	assert.ErrorContains(t, err, "line 2")
+}
+
+func TestTOML_MapAndStruct(t *testing.T) {
+	type Server struct {
+		Host  string   `json:"host"`
+		Port  int      `json:"port"`
+		Tags  []string `json:"tags,omitempty"`
+		Debug bool     `json:"-"`
+	}
+	type Config struct {
+		Name    string                      `json:"name"`
+		Servers Map[string, Server]         `json:"servers"`
+		Limits  *Map[int, float64]          `json:"limits"`
+		Meta    map[string]TOMLLocalDate    `json:"meta"`
+		Extra   Map[string, map[string]int] `json:"extra"`
+	}
+
+	input := `name = "prod"
+limits = { 10 = 0.5, 2 = 1.5 }
+
+[servers.web]
+host = "w"
+port = 80
+tags = ["a"]
+
+[servers.api]
+host = "a"
+port = 81
+
+[meta]
+since = 2024-01-31
+
+[extra.x]
+b = 2
+a = 1
+`
+	var cfg Config
+	err := UnmarshalTOML([]byte(input), &cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "prod", cfg.Name)
+	assert.Equal(t, []string{"web", "api"}, cfg.Servers.Keys())
+	assert.Equal(t, Server{Host: "w", Port: 80, Tags: []string{"a"}}, cfg.Servers.Get("web"))
+	assert.Equal(t, []int{10, 2}, cfg.Limits.Keys())
+	assert.Equal(t, TOMLLocalDate{2024, 1, 31}, cfg.Meta["since"])
+
+	out, err := MarshalTOML(cfg)
+	require.NoError(t, err)
+	expected := `name = "prod"
+
+[servers.web]
+host = "w"
+port = 80
+tags = ["a"]
+
+[servers.api]
+host = "a"
+port = 81
+
+[limits]
+10 = 0.5
+2 = 1.5
+
+[meta]
+since = 2024-01-31
+
+[extra.x]
+a = 1
+b = 2
+`
+	assert.Equal(t, expected, string(out))
+
+	// Map implements the TOML hooks:
+	var servers Map[string, Server]
+	err = UnmarshalTOML([]byte("[b]\nhost = \"b\"\n[a]\nhost = \"a\"\n"), &servers)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", "a"}, servers.Keys())
+
+	_, err = MarshalTOML([]any{1})
+	assert.Error(t, err)
+	_, err = MarshalTOML(mustAny(t, `{"a":null}`))
+	assert.Error(t, err)
+}